@@ -0,0 +1,277 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/instance"
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+)
+
+const (
+	// primaryTTL is the amount of time a discovered primary instance is
+	// cached before the AlloyDB Admin API is queried again.
+	primaryTTL = time.Minute
+
+	// readPoolTTL is the amount of time discovered read pool instances are
+	// cached before the AlloyDB Admin API is queried again.
+	readPoolTTL = time.Minute
+
+	// readPoolFailureBackoff is the amount of time a read pool instance that
+	// failed to connect is removed from rotation.
+	readPoolFailureBackoff = 30 * time.Second
+)
+
+// cachedPrimary is the primary instance of a cluster as discovered through the
+// AlloyDB Admin API.
+type cachedPrimary struct {
+	inst    instance.URI
+	expires time.Time
+}
+
+// readPool holds the read pool instances of a cluster as discovered through
+// the AlloyDB Admin API along with the state used to rotate between them.
+type readPool struct {
+	insts   []instance.URI
+	expires time.Time
+	// next is the index of the instance to dial first on the next call to
+	// DialReadPool.
+	next int
+	// failed holds instances that recently failed to connect along with the
+	// time they return to rotation.
+	failed map[instance.URI]time.Time
+}
+
+// DialCluster returns a net.Conn connected to the primary instance of the
+// specified AlloyDB cluster. The uri argument must be the cluster's URI, which
+// is in the format projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>
+//
+// The primary instance is discovered using the AlloyDB Admin API and cached
+// for a short period. If dialing the primary fails, the cached primary is
+// discarded and the next call to DialCluster will discover it again.
+func (d *Dialer) DialCluster(ctx context.Context, uri string, opts ...DialOption) (net.Conn, error) {
+	select {
+	case <-d.closed:
+		return nil, ErrDialerClosed
+	default:
+	}
+	c, err := instance.ParseClusterURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	inst, err := d.primaryInstance(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.Dial(ctx, inst.URI(), opts...)
+	if err != nil {
+		d.logger.Debugf(
+			ctx, "[%v] Dialing primary %v failed, removing it from cache",
+			c.String(), inst.String(),
+		)
+		d.primaryLock.Lock()
+		delete(d.primaries, c)
+		d.primaryLock.Unlock()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// primaryInstance returns the primary instance of a cluster, querying the
+// AlloyDB Admin API if the cached primary is missing or stale. Concurrent
+// callers for the same cluster share a single query, which is limited to the
+// refresh timeout and made without holding primaryLock.
+func (d *Dialer) primaryInstance(
+	ctx context.Context, c instance.ClusterURI,
+) (instance.URI, error) {
+	d.primaryLock.Lock()
+	p, ok := d.primaries[c]
+	d.primaryLock.Unlock()
+	if ok && time.Now().Before(p.expires) {
+		return p.inst, nil
+	}
+	ch := d.primaryGroup.DoChan(c.String(), func() (any, error) {
+		ctx, cancel := context.WithTimeout(
+			context.WithoutCancel(ctx), d.refreshTimeout,
+		)
+		defer cancel()
+		d.logger.Debugf(ctx, "[%v] Discovering primary instance", c.String())
+		client, err := d.adminClient(ctx, c.Region())
+		if err != nil {
+			return nil, err
+		}
+		inst, err := alloydb.FetchPrimaryInstance(ctx, client, c)
+		d.primaryLock.Lock()
+		defer d.primaryLock.Unlock()
+		if err != nil {
+			delete(d.primaries, c)
+			return nil, err
+		}
+		d.primaries[c] = cachedPrimary{
+			inst:    inst,
+			expires: time.Now().Add(primaryTTL),
+		}
+		return inst, nil
+	})
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			return instance.URI{}, r.Err
+		}
+		return r.Val.(instance.URI), nil
+	case <-ctx.Done():
+		return instance.URI{}, ctx.Err()
+	}
+}
+
+// DialReadPool returns a net.Conn connected to one of the read pool instances
+// of the specified AlloyDB cluster. The uri argument must be the cluster's URI,
+// which is in the format projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>
+//
+// Successive calls rotate through the cluster's read pool instances. If
+// dialing an instance fails, the instance is removed from rotation for a short
+// period and the next instance is dialed instead. If every instance has
+// recently failed, all instances are tried again.
+//
+// Read pool instances are discovered using the AlloyDB Admin API and cached
+// for a short period, after which they are discovered again to pick up added
+// or removed instances.
+func (d *Dialer) DialReadPool(ctx context.Context, uri string, opts ...DialOption) (net.Conn, error) {
+	select {
+	case <-d.closed:
+		return nil, ErrDialerClosed
+	default:
+	}
+	c, err := instance.ParseClusterURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	insts, err := d.readPoolRotation(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	for _, inst := range insts {
+		var conn net.Conn
+		conn, err = d.Dial(ctx, inst.URI(), opts...)
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			// The failure is the caller's, not the instance's.
+			break
+		}
+		d.logger.Debugf(
+			ctx, "[%v] Dialing read pool instance %v failed, removing it from rotation",
+			c.String(), inst.String(),
+		)
+		d.readPoolLock.Lock()
+		if p, ok := d.readPools[c]; ok {
+			p.failed[inst] = time.Now().Add(readPoolFailureBackoff)
+		}
+		d.readPoolLock.Unlock()
+	}
+	return nil, err
+}
+
+// readPoolRotation returns the read pool instances of a cluster in the order
+// they should be dialed, querying the AlloyDB Admin API if the cached
+// instances are missing or stale. Instances that recently failed are placed
+// last.
+func (d *Dialer) readPoolRotation(
+	ctx context.Context, c instance.ClusterURI,
+) ([]instance.URI, error) {
+	d.readPoolLock.Lock()
+	p, ok := d.readPools[c]
+	d.readPoolLock.Unlock()
+	if !ok || !time.Now().Before(p.expires) {
+		var err error
+		p, err = d.discoverReadPool(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d.readPoolLock.Lock()
+	defer d.readPoolLock.Unlock()
+	now := time.Now()
+	var healthy, unhealthy []instance.URI
+	for i := range p.insts {
+		inst := p.insts[(p.next+i)%len(p.insts)]
+		if until, ok := p.failed[inst]; ok {
+			if now.Before(until) {
+				unhealthy = append(unhealthy, inst)
+				continue
+			}
+			delete(p.failed, inst)
+		}
+		healthy = append(healthy, inst)
+	}
+	p.next = (p.next + 1) % len(p.insts)
+	return append(healthy, unhealthy...), nil
+}
+
+// discoverReadPool queries the AlloyDB Admin API for the read pool instances
+// of a cluster and caches them, keeping the rotation state of instances that
+// were already cached. Concurrent callers for the same cluster share a single
+// query, which is limited to the refresh timeout and made without holding
+// readPoolLock.
+func (d *Dialer) discoverReadPool(
+	ctx context.Context, c instance.ClusterURI,
+) (*readPool, error) {
+	ch := d.readPoolGroup.DoChan(c.String(), func() (any, error) {
+		ctx, cancel := context.WithTimeout(
+			context.WithoutCancel(ctx), d.refreshTimeout,
+		)
+		defer cancel()
+		d.logger.Debugf(ctx, "[%v] Discovering read pool instances", c.String())
+		client, err := d.adminClient(ctx, c.Region())
+		if err != nil {
+			return nil, err
+		}
+		insts, err := alloydb.FetchReadPoolInstances(ctx, client, c)
+		d.readPoolLock.Lock()
+		defer d.readPoolLock.Unlock()
+		if err != nil {
+			delete(d.readPools, c)
+			return nil, err
+		}
+		failed := make(map[instance.URI]time.Time)
+		var next int
+		if old, ok := d.readPools[c]; ok {
+			failed = old.failed
+			next = old.next
+		}
+		p := &readPool{
+			insts:   insts,
+			expires: time.Now().Add(readPoolTTL),
+			next:    next,
+			failed:  failed,
+		}
+		d.readPools[c] = p
+		return p, nil
+	})
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return r.Val.(*readPool), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,443 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+const testClusterURI = "projects/my-project/locations/my-region/clusters/my-cluster"
+
+func TestDialerDialCluster(t *testing.T) {
+	ctx := context.Background()
+	primary := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	pool := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-pool",
+		mock.WithInstanceType("READ_POOL"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		// The primary is only discovered once and then cached.
+		mock.ListInstancesSuccess([]mock.FakeAlloyDBInstance{pool, primary}, 1),
+		mock.InstanceGetSuccess(primary, 1),
+		mock.CreateEphemeralSuccess(primary, 1),
+	)
+	stop := mock.StartServerProxy(t, primary)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	for i := 0; i < 2; i++ {
+		conn, err := d.DialCluster(ctx, testClusterURI)
+		if err != nil {
+			t.Fatalf("expected DialCluster to succeed, but got error: %v", err)
+		}
+		data, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil {
+			t.Fatalf("expected ReadAll to succeed, got error %v", err)
+		}
+		if string(data) != "my-instance" {
+			t.Fatalf("expected known response from the server, but got %v", string(data))
+		}
+	}
+}
+
+func TestDialerDialClusterErrors(t *testing.T) {
+	ctx := context.Background()
+	pool := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-pool",
+		mock.WithInstanceType("READ_POOL"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.ListInstancesSuccess([]mock.FakeAlloyDBInstance{pool}, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	_, err = d.DialCluster(ctx, testInstanceURI)
+	var wantErr1 *errtype.ConfigError
+	if !errors.As(err, &wantErr1) {
+		t.Fatalf("when cluster URI is invalid, want = %T, got = %v", wantErr1, err)
+	}
+
+	_, err = d.DialCluster(ctx, testClusterURI)
+	var wantErr2 *errtype.ConfigError
+	if !errors.As(err, &wantErr2) {
+		t.Fatalf("when cluster has no primary, want = %T, got = %v", wantErr2, err)
+	}
+}
+
+// gatedTransport is an http.RoundTripper that counts requests and holds them
+// until release is closed.
+type gatedTransport struct {
+	requests atomic.Int32
+	started  chan struct{}
+	release  chan struct{}
+	base     http.RoundTripper
+}
+
+func (g *gatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if g.requests.Add(1) == 1 {
+		close(g.started)
+	}
+	<-g.release
+	return g.base.RoundTrip(req)
+}
+
+// newGatedDialer returns a Dialer whose Admin API requests are held by the
+// returned gatedTransport.
+func newGatedDialer(t *testing.T, requests ...*mock.Request) (*Dialer, *gatedTransport) {
+	t.Helper()
+	ctx := context.Background()
+	mc, url, cleanup := mock.HTTPClient(requests...)
+	t.Cleanup(func() {
+		if err := cleanup(); err != nil {
+			t.Errorf("%v", err)
+		}
+	})
+	g := &gatedTransport{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		base:    mc.Transport,
+	}
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx,
+		option.WithHTTPClient(&http.Client{Transport: g}),
+		option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	t.Cleanup(func() { d.Close() })
+	return d, g
+}
+
+func TestDialerPrimaryInstanceDiscoversOnceWithoutBlocking(t *testing.T) {
+	ctx := context.Background()
+	primary := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	d, g := newGatedDialer(t,
+		mock.ListInstancesSuccess([]mock.FakeAlloyDBInstance{primary}, 1),
+	)
+	c, err := instance.ParseClusterURI(testClusterURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := instance.ParseClusterURI(
+		"projects/my-project/locations/my-region/clusters/other-cluster",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPrimary, err := instance.ParseURI(
+		"projects/my-project/locations/my-region/clusters/other-cluster/instances/other-instance",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.primaries[other] = cachedPrimary{
+		inst:    otherPrimary,
+		expires: time.Now().Add(primaryTTL),
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := d.primaryInstance(ctx, c)
+			if err == nil && got.Name() != "my-instance" {
+				err = errors.New("unexpected primary " + got.String())
+			}
+			errs <- err
+		}()
+	}
+	<-g.started
+
+	// A cached cluster is not blocked by the discovery in progress.
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.primaryInstance(ctx, other)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected primaryInstance to succeed, but got error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("primaryInstance for a cached cluster blocked on another discovery")
+	}
+
+	close(g.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("expected primaryInstance to succeed, but got error: %v", err)
+		}
+	}
+	if got := g.requests.Load(); got != 1 {
+		t.Fatalf("want 1 Admin API request, got = %v", got)
+	}
+}
+
+func TestDialerReadPoolRotationDiscoversOnceWithoutBlocking(t *testing.T) {
+	ctx := context.Background()
+	pool := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-pool",
+		mock.WithInstanceType("READ_POOL"),
+	)
+	d, g := newGatedDialer(t,
+		mock.ListInstancesSuccess([]mock.FakeAlloyDBInstance{pool}, 1),
+	)
+	c, err := instance.ParseClusterURI(testClusterURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := instance.ParseClusterURI(
+		"projects/my-project/locations/my-region/clusters/other-cluster",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPool, err := instance.ParseURI(
+		"projects/my-project/locations/my-region/clusters/other-cluster/instances/other-pool",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.readPools[other] = &readPool{
+		insts:   []instance.URI{otherPool},
+		expires: time.Now().Add(readPoolTTL),
+		failed:  make(map[instance.URI]time.Time),
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := d.readPoolRotation(ctx, c)
+			if err == nil && (len(got) != 1 || got[0].Name() != "my-pool") {
+				err = errors.New("unexpected read pool instances")
+			}
+			errs <- err
+		}()
+	}
+	<-g.started
+
+	// A cached cluster is not blocked by the discovery in progress.
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.readPoolRotation(ctx, other)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected readPoolRotation to succeed, but got error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("readPoolRotation for a cached cluster blocked on another discovery")
+	}
+
+	close(g.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("expected readPoolRotation to succeed, but got error: %v", err)
+		}
+	}
+	if got := g.requests.Load(); got != 1 {
+		t.Fatalf("want 1 Admin API request, got = %v", got)
+	}
+}
+
+func TestDialerDialReadPool(t *testing.T) {
+	ctx := context.Background()
+	primary := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	healthy := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-pool-1",
+		mock.WithInstanceType("READ_POOL"),
+	)
+	// The second read pool instance is never reachable in this test.
+	broken := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-pool-2",
+		mock.WithInstanceType("READ_POOL"),
+		mock.WithPrivateIP("10.0.0.2"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		// The read pool is only discovered once and then cached.
+		mock.ListInstancesSuccess(
+			[]mock.FakeAlloyDBInstance{primary, healthy, broken}, 1,
+		),
+		mock.InstanceGetSuccess(healthy, 1),
+		mock.InstanceGetSuccess(broken, 1),
+		mock.CreateEphemeralSuccess(healthy, 2),
+	)
+	stop := mock.StartServerProxy(t, healthy)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	var brokenDials int
+	var dialer net.Dialer
+	f := WithOneOffDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if strings.HasPrefix(addr, "10.0.0.2") {
+			brokenDials++
+			return nil, errors.New("connection refused")
+		}
+		return dialer.DialContext(ctx, network, addr)
+	})
+
+	// The first dial goes to the healthy instance, the second tries the
+	// broken instance and then falls back, and the third skips the broken
+	// instance as it has been removed from rotation.
+	for i := 0; i < 3; i++ {
+		conn, err := d.DialReadPool(ctx, testClusterURI, f)
+		if err != nil {
+			t.Fatalf("expected DialReadPool to succeed, but got error: %v", err)
+		}
+		data, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil {
+			t.Fatalf("expected ReadAll to succeed, got error %v", err)
+		}
+		if string(data) != "my-pool-1" {
+			t.Fatalf("expected known response from the server, but got %v", string(data))
+		}
+	}
+	if brokenDials != 1 {
+		t.Fatalf("want broken instance dialed once, got = %v", brokenDials)
+	}
+}
+
+func TestDialerDialReadPoolErrors(t *testing.T) {
+	ctx := context.Background()
+	primary := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.ListInstancesSuccess([]mock.FakeAlloyDBInstance{primary}, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	_, err = d.DialReadPool(ctx, testInstanceURI)
+	var wantErr1 *errtype.ConfigError
+	if !errors.As(err, &wantErr1) {
+		t.Fatalf("when cluster URI is invalid, want = %T, got = %v", wantErr1, err)
+	}
+
+	_, err = d.DialReadPool(ctx, testClusterURI)
+	var wantErr2 *errtype.ConfigError
+	if !errors.As(err, &wantErr2) {
+		t.Fatalf("when cluster has no read pool, want = %T, got = %v", wantErr2, err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+var (
+	defaultDialerMu sync.Mutex
+	// defaultDialer is the package-level Dialer used by DialFunc. It is
+	// created on first use.
+	defaultDialer *Dialer
+)
+
+// getDefaultDialer returns the package-level Dialer, creating it with
+// Application Default Credentials if necessary. A failure to create the
+// Dialer is not cached, so later calls will try again.
+func getDefaultDialer() (*Dialer, error) {
+	defaultDialerMu.Lock()
+	defer defaultDialerMu.Unlock()
+	if defaultDialer != nil {
+		return defaultDialer, nil
+	}
+	d, err := NewDialer(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defaultDialer = d
+	return d, nil
+}
+
+// DialFunc returns a dial function that connects to the AlloyDB instance
+// specified by instanceURI, which is in the format
+// projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>
+// The returned function ignores its network and address arguments and may be
+// used directly as a driver's dial function, e.g.,
+//
+//	config.ConnConfig.DialFunc = alloydbconn.DialFunc(instanceURI)
+//
+// Connections are made with a package-level Dialer that is shared by all
+// callers of DialFunc and that is created with Application Default
+// Credentials on first use. Because the Dialer is shared, it cannot be
+// configured with any Options. Applications that need to customize the Dialer
+// or that need to control its lifetime should create one with NewDialer
+// instead.
+//
+// To stop the package-level Dialer's background refresh operations, call
+// CloseDefaultDialer.
+func DialFunc(instanceURI string, opts ...DialOption) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		d, err := getDefaultDialer()
+		if err != nil {
+			return nil, err
+		}
+		return d.Dial(ctx, instanceURI, opts...)
+	}
+}
+
+// CloseDefaultDialer closes the package-level Dialer used by DialFunc, if one
+// has been created. A later call to a function returned by DialFunc creates a
+// new Dialer.
+func CloseDefaultDialer() error {
+	defaultDialerMu.Lock()
+	defer defaultDialerMu.Unlock()
+	if defaultDialer == nil {
+		return nil
+	}
+	err := defaultDialer.Close()
+	defaultDialer = nil
+	return err
+}
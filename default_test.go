@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+func TestDialFunc(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	// Replace the default dialer with one that uses the mock API.
+	defaultDialerMu.Lock()
+	defaultDialer = d
+	defaultDialerMu.Unlock()
+
+	conn, err := DialFunc(testInstanceURI)(ctx, "tcp", "ignored")
+	if err != nil {
+		t.Fatalf("expected DialFunc to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+
+	if err := CloseDefaultDialer(); err != nil {
+		t.Fatalf("expected CloseDefaultDialer to succeed, but got error: %v", err)
+	}
+	if _, err := d.Dial(ctx, testInstanceURI); err != ErrDialerClosed {
+		t.Fatalf("want = %v, got = %v", ErrDialerClosed, err)
+	}
+	defaultDialerMu.Lock()
+	defer defaultDialerMu.Unlock()
+	if defaultDialer != nil {
+		t.Fatal("want default dialer to be reset after close")
+	}
+}
@@ -15,16 +15,23 @@
 package alloydbconn
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
 	_ "embed"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
+	"os"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -34,31 +41,59 @@ import (
 	"cloud.google.com/go/alloydb/connectors/apiv1alpha/connectorspb"
 	"cloud.google.com/go/alloydbconn/debug"
 	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
 	"cloud.google.com/go/alloydbconn/internal/alloydb"
 	"cloud.google.com/go/alloydbconn/internal/tel"
 	"github.com/google/uuid"
 	"golang.org/x/net/proxy"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 	"google.golang.org/protobuf/proto"
 )
 
 const (
-	// defaultTCPKeepAlive is the default keep alive value used on connections
-	// to a AlloyDB instance
-	defaultTCPKeepAlive = 30 * time.Second
-	// serverProxyPort is the port the server-side proxy receives connections on.
-	serverProxyPort = "5433"
-	// ioTimeout is the maximum amount of time to wait before aborting a
-	// metadata exhange
+	// DefaultServerProxyPort is the port the server-side proxy of an AlloyDB
+	// instance receives connections on.
+	DefaultServerProxyPort = "5433"
+	// DefaultTCPKeepAlive is the keep alive period used on connections to an
+	// AlloyDB instance unless configured with WithTCPKeepAlive.
+	DefaultTCPKeepAlive = 30 * time.Second
+	// DefaultRefreshTimeout is the timeout of refresh operations unless
+	// configured with WithRefreshTimeout.
+	DefaultRefreshTimeout = 60 * time.Second
+)
+
+const (
+	// connErrRefreshInterval is the minimum time between refreshes forced by
+	// errors on established connections to the same instance.
+	connErrRefreshInterval = 30 * time.Second
+	// ioTimeout is the default maximum amount of time to wait before
+	// aborting a metadata exhange
 	ioTimeout = 30 * time.Second
+	// warmupConcurrency is the maximum number of instances WarmupAll warms
+	// up at once.
+	warmupConcurrency = 10
+	// maxDialRetryBackoff is the longest wait between dial retries, unless
+	// the backoff configured with WithDialRetries is longer.
+	maxDialRetryBackoff = 30 * time.Second
 )
 
 var (
 	// ErrDialerClosed is used when a caller invokes Dial after closing the
 	// Dialer.
 	ErrDialerClosed = errors.New("alloydbconn: dialer is closed")
+	// ErrInstanceNotFound matches errors returned by Dial when the AlloyDB
+	// Admin API reports that the instance does not exist. Use errors.Is to
+	// check for it.
+	ErrInstanceNotFound = errtype.ErrInstanceNotFound
+	// ErrPermissionDenied matches errors returned by Dial when the AlloyDB
+	// Admin API reports that the caller is not permitted to access the
+	// instance. Use errors.Is to check for it.
+	ErrPermissionDenied = errtype.ErrPermissionDenied
 	// versionString indicates the version of this library.
 	//go:embed version.txt
 	versionString string
@@ -66,12 +101,41 @@ var (
 )
 
 // keyGenerator encapsulates the details of RSA key generation to provide lazy
-// generation, custom keys, or a default RSA generator.
+// generation, custom keys, a pool of keys, or a default RSA generator.
 type keyGenerator struct {
 	once    sync.Once
 	key     *rsa.PrivateKey
 	err     error
 	genFunc func() (*rsa.PrivateKey, error)
+
+	// pool holds keys generated in the background. When it is not empty,
+	// rsaKey returns the pool's keys in turn instead of a single key.
+	pool []*pooledKey
+	// next is the index of the pool's next key.
+	next atomic.Uint64
+}
+
+// pooledKey is an RSA key that is generated in the background.
+type pooledKey struct {
+	// ready is closed once key and err are set.
+	ready chan struct{}
+	key   *rsa.PrivateKey
+	err   error
+}
+
+// newKeyPool initializes a keyGenerator that generates n RSA keys, each in its
+// own goroutine, and returns them in turn from rsaKey.
+func newKeyPool(n int, genFunc func() (*rsa.PrivateKey, error)) *keyGenerator {
+	g := &keyGenerator{genFunc: genFunc}
+	for i := 0; i < n; i++ {
+		k := &pooledKey{ready: make(chan struct{})}
+		g.pool = append(g.pool, k)
+		go func() {
+			defer close(k.ready)
+			k.key, k.err = genFunc()
+		}()
+	}
+	return g
 }
 
 // newKeyGenerator initializes a keyGenerator that will (in order):
@@ -99,8 +163,14 @@ func newKeyGenerator(
 }
 
 // rsaKey will generate an RSA key if one is not already cached. Otherwise, it
-// will return the cached key.
+// will return the cached key. With a key pool, rsaKey returns the pool's keys
+// in turn, waiting for a key that is still being generated.
 func (g *keyGenerator) rsaKey() (*rsa.PrivateKey, error) {
+	if n := uint64(len(g.pool)); n > 0 {
+		k := g.pool[(g.next.Add(1)-1)%n]
+		<-k.ready
+		return k.key, k.err
+	}
 	g.once.Do(func() { g.key, g.err = g.genFunc() })
 
 	return g.key, g.err
@@ -109,6 +179,7 @@ func (g *keyGenerator) rsaKey() (*rsa.PrivateKey, error) {
 type connectionInfoCache interface {
 	ConnectionInfo(context.Context) (alloydb.ConnectionInfo, error)
 	ForceRefresh()
+	RefreshStatus() alloydb.RefreshStatus
 	io.Closer
 }
 
@@ -116,36 +187,236 @@ type connectionInfoCache interface {
 // number of connections to the associated instance.
 type monitoredCache struct {
 	openConns *uint64
+	// sessions caches TLS sessions for resumption. It is nil when session
+	// resumption is disabled.
+	sessions tls.ClientSessionCache
+	// connErrLimiter limits refreshes forced by errors on established
+	// connections. It is nil when such errors should not force a refresh.
+	connErrLimiter *rate.Limiter
+	// lastDial is the time of the most recent dial of the instance, in Unix
+	// nanoseconds. It is nil when the cache is never evicted.
+	lastDial *int64
+	// expiration is the expiration of the connection info most recently
+	// returned by the cache, in Unix nanoseconds, or zero if the cache failed.
+	// It is nil when calls to the cache are always shared.
+	expiration *int64
 	connectionInfoCache
 }
 
+// markDialed records that the instance was dialed now.
+func (c monitoredCache) markDialed() {
+	if c.lastDial != nil {
+		atomic.StoreInt64(c.lastDial, time.Now().UnixNano())
+	}
+}
+
+// lastDialed returns the time of the most recent dial of the instance in Unix
+// nanoseconds, or zero if it is unknown.
+func (c monitoredCache) lastDialed() int64 {
+	if c.lastDial == nil {
+		return 0
+	}
+	return atomic.LoadInt64(c.lastDial)
+}
+
+// hasValidInfo reports whether the connection info most recently returned by
+// the cache has not expired.
+func (c monitoredCache) hasValidInfo() bool {
+	return c.expiration != nil &&
+		time.Now().UnixNano() < atomic.LoadInt64(c.expiration)
+}
+
+// setExpiration records the expiration of the connection info returned by
+// the cache, or that the cache failed if err is not nil.
+func (c monitoredCache) setExpiration(ci alloydb.ConnectionInfo, err error) {
+	if c.expiration == nil {
+		return
+	}
+	var exp int64
+	if err == nil {
+		exp = ci.Expiration.UnixNano()
+	}
+	atomic.StoreInt64(c.expiration, exp)
+}
+
+// refreshOnConnErr forces a refresh when err, returned by a read or write on
+// an established connection, shows that the server rejected the client
+// certificate, e.g., after the instance rotated its CA or moved. Ordinary
+// network errors are ignored, and forced refreshes are rate limited so that
+// many connections failing at once result in a single refresh.
+func (c monitoredCache) refreshOnConnErr(err error) {
+	if c.connErrLimiter == nil || !isCertRejectedError(err) {
+		return
+	}
+	if c.connErrLimiter.Allow() {
+		c.ForceRefresh()
+	}
+}
+
+// certRejectedAlerts are the descriptions of the TLS alerts a server sends
+// when it rejects the client certificate (RFC 8446, Section 6.2). A client
+// sends no certificate, which the server answers with "certificate required",
+// when its certificate is not signed by any of the CAs the server accepts.
+var certRejectedAlerts = map[string]bool{
+	"tls: bad certificate":               true,
+	"tls: revoked certificate":           true,
+	"tls: expired certificate":           true,
+	"tls: unknown certificate":           true,
+	"tls: unknown certificate authority": true,
+	"tls: certificate required":          true,
+}
+
+// isCertRejectedError reports whether err is a TLS alert received from the
+// server rejecting the client certificate. With TLS 1.3, the server verifies
+// the client certificate after the client has completed the handshake, so the
+// alert surfaces on the first read or write of the connection.
+func isCertRejectedError(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "remote error" {
+		return false
+	}
+	return certRejectedAlerts[opErr.Err.Error()]
+}
+
+// transientError wraps the cause of a dial failure that may succeed when
+// retried, i.e., a failure to connect to the instance or to complete the TLS
+// handshake.
+type transientError struct{ error }
+
+func (e transientError) Unwrap() error { return e.error }
+
+// isTransientError reports whether err is a dial failure that may succeed
+// when retried. Other failures, e.g., a closed cache or an unknown IP type,
+// fail the same way on every attempt.
+func isTransientError(err error) bool {
+	var tErr transientError
+	return errors.As(err, &tErr)
+}
+
+// certSessionCache stores TLS sessions under keys scoped to a client
+// certificate, so that a session is only resumed with the certificate it was
+// established with. Sessions established with a previous certificate are
+// never resumed and are eventually evicted.
+type certSessionCache struct {
+	tls.ClientSessionCache
+	prefix string
+}
+
+func newCertSessionCache(
+	c tls.ClientSessionCache, cert tls.Certificate,
+) tls.ClientSessionCache {
+	if c == nil || len(cert.Certificate) == 0 {
+		return nil
+	}
+	sum := sha256.Sum256(cert.Certificate[0])
+	return certSessionCache{
+		ClientSessionCache: c,
+		prefix:             hex.EncodeToString(sum[:]) + "/",
+	}
+}
+
+func (c certSessionCache) Get(key string) (*tls.ClientSessionState, bool) {
+	return c.ClientSessionCache.Get(c.prefix + key)
+}
+
+func (c certSessionCache) Put(key string, cs *tls.ClientSessionState) {
+	c.ClientSessionCache.Put(c.prefix+key, cs)
+}
+
 // A Dialer is used to create connections to AlloyDB instance.
 //
 // Use NewDialer to initialize a Dialer.
 type Dialer struct {
 	lock           sync.RWMutex
-	cache          map[alloydb.InstanceURI]monitoredCache
+	cache          map[instance.URI]monitoredCache
 	keyGenerator   *keyGenerator
 	refreshTimeout time.Duration
 	// closed reports if the dialer has been closed.
 	closed chan struct{}
 
+	// failures holds instances whose refresh recently failed with a
+	// permanent error. It is guarded by lock.
+	failures map[instance.URI]cachedFailure
+	// negativeCacheTTL is how long a permanent refresh failure is cached. A
+	// zero value disables the negative cache.
+	negativeCacheTTL time.Duration
+
+	// primaryLock guards primaries.
+	primaryLock sync.Mutex
+	// primaries holds the primary instance of clusters dialed with
+	// DialCluster.
+	primaries map[instance.ClusterURI]cachedPrimary
+	// primaryGroup deduplicates concurrent discoveries of a cluster's
+	// primary instance.
+	primaryGroup singleflight.Group
+
+	// readPoolLock guards readPools.
+	readPoolLock sync.Mutex
+	// readPools holds the read pool instances of clusters dialed with
+	// DialReadPool.
+	readPools map[instance.ClusterURI]*readPool
+	// readPoolGroup deduplicates concurrent discoveries of a cluster's read
+	// pool instances.
+	readPoolGroup singleflight.Group
+
+	// infoGroup deduplicates concurrent requests for an instance's
+	// connection info, such that a burst of dials to an instance that is not
+	// yet cached results in a single refresh.
+	infoGroup singleflight.Group
+
 	// lazyRefresh determines what kind of caching is used for ephemeral
 	// certificates. When lazyRefresh is true, the dialer will use a lazy
 	// cache, refresh certificates only when a connection attempt needs a fresh
 	// certificate. Otherwise, a refresh ahead cache will be used. The refresh
 	// ahead cache assumes a background goroutine may run consistently.
 	lazyRefresh bool
+	// backgroundDisabled reports whether the Dialer avoids all work outside
+	// of calls to its methods. See WithBackgroundRefreshDisabled.
+	backgroundDisabled bool
+	// refreshOnDialFailureDisabled reports whether the Dialer skips forcing a
+	// refresh when a connection attempt or TLS handshake fails.
+	refreshOnDialFailureDisabled bool
 
 	// disableMetadataExchange is a temporary addition to help clients who
 	// cannot use the metadata exchange yet. In future versions, this field
 	// should be removed.
 	disableMetadataExchange bool
 
-	staticConnInfo io.Reader
+	// staticConnInfo is the static connection info read from the reader
+	// passed to WithStaticConnectionInfo, if any.
+	staticConnInfo []byte
+	// cacheFactory, if set, creates the connection info caches in place of
+	// the built-in caches.
+	cacheFactory ConnectionInfoCacheFactory
+	// maxRefreshFailures is the number of consecutive failed background
+	// refreshes after which an instance's cache is removed. When zero,
+	// caches retry indefinitely.
+	maxRefreshFailures int
+	// maxCachedInstances is the number of instances whose connection info is
+	// cached before the least recently dialed instance is evicted. When zero,
+	// the number is unbounded.
+	maxCachedInstances int
+	// refreshNotifier receives an event after each refresh. See
+	// WithRefreshNotifier.
+	refreshNotifier chan<- RefreshEvent
 
 	client *alloydbadmin.AlloyDBAdminClient
 	logger debug.ContextLogger
+	// ownedClients holds the Admin API clients created by NewDialer, which
+	// are closed with the Dialer. A client supplied with WithAdminClient is
+	// left for the caller to close.
+	ownedClients []*alloydbadmin.AlloyDBAdminClient
+
+	// adminOpts configure the regional Admin API clients. They are only set
+	// with WithRegionalAdminEndpoint.
+	adminOpts []option.ClientOption
+	// regionalLock protects regionalClients, which holds an Admin API client
+	// per region when using WithRegionalAdminEndpoint.
+	regionalLock    sync.Mutex
+	regionalClients map[string]*alloydbadmin.AlloyDBAdminClient
+
+	// refreshOpts configures the connection info caches.
+	refreshOpts []alloydb.Option
 
 	// defaultDialCfg holds the constructor level DialOptions, so that it can
 	// be copied and mutated by the Dial function.
@@ -159,11 +430,52 @@ type Dialer struct {
 	// network. By default it is golang.org/x/net/proxy#Dial.
 	dialFunc func(cxt context.Context, network, addr string) (net.Conn, error)
 
-	useIAMAuthN    bool
-	iamTokenSource oauth2.TokenSource
-	userAgent      string
+	authType       AuthType
+	iamTokenSource *swappableTokenSource
+	// scopes are the OAuth2 scopes requested by the default token source.
+	scopes    []string
+	userAgent string
+
+	// taggedConns counts the open connections with metric tags, keyed by
+	// connTags.
+	taggedConns sync.Map
+
+	// onDial is called after each call to Dial.
+	onDial func(DialEvent)
+	// spanInstanceAttrs reports whether the parts of the instance URI are
+	// added to spans.
+	spanInstanceAttrs bool
+	// validateInstance, if set, rejects instances before they are used. See
+	// WithInstanceValidator.
+	validateInstance func(instance.URI) error
+	// beforeDial, if set, adjusts the configuration of each Dial. See
+	// WithBeforeDial.
+	beforeDial BeforeDialFunc
+	// additionalRootCAs, if set, are trusted to verify the server's
+	// certificate in addition to the instance's CA.
+	additionalRootCAs *x509.CertPool
+
+	// pscResolver returns the host to dial for PSC connections. When nil,
+	// the PSC DNS name is dialed.
+	pscResolver func(instance string) (string, error)
+
+	// dnsResolver looks up the instance URI of a domain name passed to Dial.
+	// When nil, Dial only accepts instance URIs.
+	dnsResolver txtResolver
 
 	buffer *buffer
+	// metadataExchangeTimeout bounds each read and write of the metadata
+	// exchange.
+	metadataExchangeTimeout time.Duration
+	// tlsSessionCacheSize is the number of TLS sessions cached per instance
+	// for resumption. Zero disables session resumption.
+	tlsSessionCacheSize int
+}
+
+// txtResolver looks up the DNS TXT records of a domain name. It is
+// implemented by *net.Resolver.
+type txtResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
 }
 
 type nullLogger struct{}
@@ -174,221 +486,1543 @@ func (nullLogger) Debugf(context.Context, string, ...interface{}) {}
 //
 // Initial calls to NewDialer make take longer than normal because generation of an
 // RSA keypair is performed. Calls with a WithRSAKeyPair DialOption or after a default
-// RSA keypair is generated will be faster.
+// RSA keypair is generated will be faster. Nil options are ignored.
 func NewDialer(ctx context.Context, opts ...Option) (*Dialer, error) {
 	cfg := &dialerConfig{
-		refreshTimeout: alloydb.RefreshTimeout,
-		dialFunc:       proxy.Dial,
-		logger:         nullLogger{},
-		userAgents:     []string{userAgent},
+		refreshTimeout:          DefaultRefreshTimeout,
+		dialFunc:                proxy.Dial,
+		logger:                  nullLogger{},
+		userAgents:              []string{userAgent},
+		bufferSize:              maxMessageSize,
+		metadataExchangeTimeout: ioTimeout,
 	}
 	for _, opt := range opts {
+		// Skip nil options, which are easily introduced when building a list
+		// of options conditionally.
+		if opt == nil {
+			continue
+		}
 		opt(cfg)
 		if cfg.err != nil {
 			return nil, cfg.err
 		}
 	}
-	if cfg.disableMetadataExchange && cfg.useIAMAuthN {
+	if cfg.disableMetadataExchange && cfg.authType != AuthTypeDBNative {
 		return nil, errors.New("incompatible options: WithOptOutOfAdvancedConnection " +
-			"check cannot be used with WithIAMAuthN")
+			"check cannot be used with WithIAMAuthN or WithAuthType")
+	}
+	if cfg.keyPoolSize > 0 && cfg.rsaKey != nil {
+		return nil, errors.New("incompatible options: WithKeyPoolSize " +
+			"cannot be used with WithRSAKey")
+	}
+	if cfg.regionalEndpoint && cfg.adminClient != nil {
+		return nil, errors.New("incompatible options: WithRegionalAdminEndpoint " +
+			"cannot be used with WithAdminClient")
+	}
+	// The refresh ahead window is checked against the certificate lifetime
+	// once both options are applied, in whichever order they were passed.
+	certDuration := alloydb.ClientCertDuration
+	if cfg.certDuration > 0 {
+		certDuration = cfg.certDuration
+		cfg.refreshOpts = append(cfg.refreshOpts, alloydb.WithCertDuration(certDuration))
+	}
+	if cfg.refreshAheadWindow > 0 {
+		if cfg.refreshAheadWindow >= certDuration {
+			return nil, errtype.NewConfigError(
+				fmt.Sprintf(
+					"invalid refresh ahead window %v, must be less than the cert duration %v",
+					cfg.refreshAheadWindow, certDuration,
+				),
+				"n/a",
+			)
+		}
+		cfg.refreshOpts = append(cfg.refreshOpts,
+			alloydb.WithRefreshAheadWindow(cfg.refreshAheadWindow),
+		)
+	}
+	// Read the static connection info once, so that each instance's cache
+	// reads the same data.
+	var staticConnInfo []byte
+	if cfg.staticConnInfo != nil {
+		var err error
+		staticConnInfo, err = io.ReadAll(cfg.staticConnInfo)
+		if err != nil {
+			return nil, errtype.NewConfigError(
+				fmt.Sprintf("failed to read static connection info: %v", err), "n/a",
+			)
+		}
 	}
 	userAgent := strings.Join(cfg.userAgents, " ")
 	// Add this to the end to make sure it's not overridden
 	cfg.adminOpts = append(cfg.adminOpts, option.WithUserAgent(userAgent))
 
+	scopes := []string{CloudPlatformScope}
+	if len(cfg.scopes) > 0 {
+		scopes = cfg.scopes
+		// Reload any credentials with the custom scopes.
+		if cfg.credentialsJSON != nil {
+			c, err := google.CredentialsFromJSON(ctx, cfg.credentialsJSON, scopes...)
+			if err != nil {
+				return nil, errtype.NewConfigError(err.Error(), "n/a")
+			}
+			cfg.tokenSource = c.TokenSource
+		}
+	}
 	// If no token source is configured, use ADC's token source.
 	ts := cfg.tokenSource
 	if ts == nil {
 		var err error
-		ts, err = google.DefaultTokenSource(ctx, CloudPlatformScope)
+		ts, err = google.DefaultTokenSource(ctx, scopes...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Route all token requests through a token source that may be replaced
+	// with SetTokenSource.
+	tokenSource := &swappableTokenSource{ts: ts}
+	if cfg.credentials != nil {
+		// The Admin API client prefers credentials over a token source, so
+		// update the credentials loaded by WithCredentialsJSON instead.
+		cfg.credentials.TokenSource = tokenSource
+	} else {
+		cfg.adminOpts = append(cfg.adminOpts, option.WithTokenSource(tokenSource))
+	}
+
+	var (
+		ownedClients []*alloydbadmin.AlloyDBAdminClient
+		created      bool
+	)
+	defer func() {
+		// Close the clients created below if NewDialer fails.
+		if created {
+			return
+		}
+		for _, c := range ownedClients {
+			closeAdminClient(c)
+		}
+	}()
+	client := cfg.adminClient
+	if client == nil {
+		// NewAlloyDBAdminRESTClient enables the new auth library, whose
+		// transport doesn't apply option.WithUserAgent, and uses an HTTP
+		// client passed with option.WithHTTPClient as is. Without this
+		// wrapper, requests carry Go's default User-Agent (see
+		// TestDialerSendsUserAgentToAdminAPI), so set the header on every
+		// request with a shared HTTP client instead.
+		hc, _, err := htransport.NewClient(ctx, cfg.adminOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AlloyDB Admin API HTTP client: %v", err)
+		}
+		uaClient := *hc
+		uaClient.Transport = &userAgentTransport{userAgent: userAgent, base: hc.Transport}
+		cfg.adminOpts = append(cfg.adminOpts, option.WithHTTPClient(&uaClient))
+		client, err = alloydbadmin.NewAlloyDBAdminRESTClient(ctx, cfg.adminOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AlloyDB Admin API client: %v", err)
+		}
+		ownedClients = append(ownedClients, client)
+	}
+	refreshOpts := cfg.refreshOpts
+	if cfg.connectionInfoEndpoint != "" {
+		c, err := newAdminClientWithEndpoint(ctx, cfg.adminOpts, cfg.connectionInfoEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		ownedClients = append(ownedClients, c)
+		refreshOpts = append(refreshOpts, alloydb.WithConnectionInfoClient(c))
+	}
+	if cfg.certificateEndpoint != "" {
+		c, err := newAdminClientWithEndpoint(ctx, cfg.adminOpts, cfg.certificateEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		ownedClients = append(ownedClients, c)
+		refreshOpts = append(refreshOpts, alloydb.WithCertificateClient(c))
+	}
+
+	dialCfg := dialCfg{
+		ipType:       alloydb.PrivateIP,
+		tcpKeepAlive: DefaultTCPKeepAlive,
+		network:      "tcp",
+	}
+	dialCfg.apply(cfg.dialOpts)
+	if dialCfg.err != nil {
+		return nil, errtype.NewConfigError(dialCfg.err.Error(), "n/a")
+	}
+
+	dialerID := cfg.dialerID
+	if dialerID == "" {
+		dialerID = uuid.New().String()
+	}
+
+	if cfg.backgroundDisabled {
+		cfg.lazyRefresh = true
+		refreshOpts = append(refreshOpts, alloydb.WithSynchronousTelemetry())
+	}
+	if err := tel.InitMetrics(); err != nil {
+		return nil, err
+	}
+	genFunc := func() (*rsa.PrivateKey, error) {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+	var g *keyGenerator
+	if cfg.keyPoolSize > 0 {
+		g = newKeyPool(cfg.keyPoolSize, genFunc)
+	} else {
+		var err error
+		g, err = newKeyGenerator(cfg.rsaKey, cfg.lazyRefresh, genFunc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	d := &Dialer{
+		closed:                       make(chan struct{}),
+		cache:                        make(map[instance.URI]monitoredCache),
+		failures:                     make(map[instance.URI]cachedFailure),
+		negativeCacheTTL:             cfg.negativeCacheTTL,
+		primaries:                    make(map[instance.ClusterURI]cachedPrimary),
+		readPools:                    make(map[instance.ClusterURI]*readPool),
+		lazyRefresh:                  cfg.lazyRefresh,
+		backgroundDisabled:           cfg.backgroundDisabled,
+		refreshOnDialFailureDisabled: cfg.refreshOnDialFailureDisabled,
+		disableMetadataExchange:      cfg.disableMetadataExchange,
+		staticConnInfo:               staticConnInfo,
+		cacheFactory:                 cfg.cacheFactory,
+		maxRefreshFailures:           cfg.maxRefreshFailures,
+		maxCachedInstances:           cfg.maxCachedInstances,
+		refreshNotifier:              cfg.refreshNotifier,
+		keyGenerator:                 g,
+		refreshTimeout:               cfg.refreshTimeout,
+		client:                       client,
+		ownedClients:                 ownedClients,
+		refreshOpts:                  refreshOpts,
+		logger:                       cfg.logger,
+		defaultDialCfg:               dialCfg,
+		dialerID:                     dialerID,
+		dialFunc:                     cfg.dialFunc,
+		authType:                     cfg.authType,
+		iamTokenSource:               tokenSource,
+		scopes:                       scopes,
+		userAgent:                    userAgent,
+		buffer:                       newBuffer(cfg.bufferSize),
+		metadataExchangeTimeout:      cfg.metadataExchangeTimeout,
+		tlsSessionCacheSize:          cfg.tlsSessionCacheSize,
+		onDial:                       cfg.onDial,
+		spanInstanceAttrs:            cfg.spanInstanceAttrs,
+		validateInstance:             cfg.validateInstance,
+		beforeDial:                   cfg.beforeDial,
+		additionalRootCAs:            cfg.additionalRootCAs,
+		pscResolver:                  cfg.pscResolver,
+		dnsResolver:                  cfg.dnsResolver,
+	}
+	if cfg.regionalEndpoint {
+		d.adminOpts = cfg.adminOpts
+		d.regionalClients = make(map[string]*alloydbadmin.AlloyDBAdminClient)
+	}
+	created = true
+	return d, nil
+}
+
+// regionalEndpoint returns the AlloyDB Admin API endpoint of region.
+func regionalEndpoint(region string) string {
+	return fmt.Sprintf("https://%s-alloydb.googleapis.com", region)
+}
+
+// adminClient returns the AlloyDB Admin API client to use for resources in
+// region. Unless configured with WithRegionalAdminEndpoint, this is the
+// Dialer's only client.
+func (d *Dialer) adminClient(
+	ctx context.Context, region string,
+) (*alloydbadmin.AlloyDBAdminClient, error) {
+	if d.regionalClients == nil {
+		return d.client, nil
+	}
+	d.regionalLock.Lock()
+	defer d.regionalLock.Unlock()
+	if c, ok := d.regionalClients[region]; ok {
+		return c, nil
+	}
+	// Close closes the regional clients, so don't create one after it.
+	select {
+	case <-d.closed:
+		return nil, ErrDialerClosed
+	default:
+	}
+	c, err := newAdminClientWithEndpoint(ctx, d.adminOpts, regionalEndpoint(region))
+	if err != nil {
+		return nil, err
+	}
+	d.regionalClients[region] = c
+	return c, nil
+}
+
+// userAgentTransport is an http.RoundTripper that sets the User-Agent header
+// of each request.
+type userAgentTransport struct {
+	userAgent string
+	base      http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return base.RoundTrip(req)
+}
+
+// closeAdminClient closes an Admin API client created by the Dialer. It is a
+// variable so that tests can observe which clients are closed.
+var closeAdminClient = (*alloydbadmin.AlloyDBAdminClient).Close
+
+// newAdminClientWithEndpoint creates an AlloyDB Admin API client that uses
+// the provided endpoint in place of any previously configured endpoint.
+func newAdminClientWithEndpoint(
+	ctx context.Context, opts []option.ClientOption, url string,
+) (*alloydbadmin.AlloyDBAdminClient, error) {
+	opts = append(append([]option.ClientOption{}, opts...), option.WithEndpoint(url))
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AlloyDB Admin API client: %v", err)
+	}
+	return c, nil
+}
+
+// SetTokenSource replaces the token source used to authenticate requests to
+// the AlloyDB Admin API and, when using WithIAMAuthN, to log in to the
+// database. SetTokenSource is safe to call concurrently with other methods
+// and allows credentials to be rotated without recreating the Dialer or
+// discarding its caches. Operations already in progress, such as a background
+// refresh, may briefly continue to use the previous token source. The Admin
+// API client does not use the token source when configured with
+// WithHTTPClient.
+func (d *Dialer) SetTokenSource(ts oauth2.TokenSource) {
+	d.iamTokenSource.set(ts)
+}
+
+// VerifyCredentials checks that the Dialer's credentials can mint the OAuth2
+// token used to call the AlloyDB Admin API and, with WithIAMAuthN, to log in
+// to the database. VerifyCredentials does not connect to any instance, so it
+// may be called at startup to detect misconfigured credentials, e.g., a
+// service account without a key or an external account that cannot be
+// exchanged, before the first Dial.
+func (d *Dialer) VerifyCredentials(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	use := "the AlloyDB Admin API"
+	if d.authType == AuthTypeAutoIAM {
+		use += " and IAM database authentication"
+	}
+	if _, err := d.iamTokenSource.Token(); err != nil {
+		return errtype.NewConfigError(
+			fmt.Sprintf(
+				"failed to get an OAuth2 token with scopes %v for %v: %v",
+				d.scopes, use, err,
+			),
+			"n/a",
+		)
+	}
+	return nil
+}
+
+// WarnIfPasswordIgnored logs a warning with the Dialer's debug logger when
+// the Dialer uses automatic IAM database authentication and password is not
+// empty. With WithIAMAuthN, the server authenticates the IAM principal with
+// an OAuth2 token and ignores any password, which can be confusing when
+// debugging authentication failures. Database driver integrations, such as
+// the pgxv4 and pgxv5 packages, call WarnIfPasswordIgnored with the password
+// from the connection string.
+func (d *Dialer) WarnIfPasswordIgnored(ctx context.Context, uri, password string) {
+	if d.authType != AuthTypeAutoIAM || password == "" {
+		return
+	}
+	d.logger.Debugf(
+		ctx,
+		"[%v] WARNING: IAM authentication is enabled, so the password in "+
+			"the connection configuration is ignored. Remove the password to "+
+			"avoid confusion.",
+		uri,
+	)
+}
+
+// swappableTokenSource is an oauth2.TokenSource whose underlying token source
+// may be replaced at runtime.
+type swappableTokenSource struct {
+	mu sync.RWMutex
+	ts oauth2.TokenSource
+}
+
+// Token returns a token from the current underlying token source.
+func (s *swappableTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.RLock()
+	ts := s.ts
+	s.mu.RUnlock()
+	return ts.Token()
+}
+
+func (s *swappableTokenSource) set(ts oauth2.TokenSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ts = ts
+}
+
+// Dial returns a net.Conn connected to the specified AlloyDB instance. The
+// uri argument must be the instance's URI, which is in the format
+// projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>,
+// or, when the Dialer is configured with WithDNSResolver, a domain name whose
+// TXT record holds the instance's URI.
+//
+// The returned net.Conn reports the host:port it is connected to through a
+// RemoteInstanceAddr method, which may be accessed with a type assertion:
+//
+//	if c, ok := conn.(interface{ RemoteInstanceAddr() string }); ok {
+//		log.Printf("connected to %v", c.RemoteInstanceAddr())
+//	}
+//
+// Nil options are ignored.
+func (d *Dialer) Dial(ctx context.Context, uri string, opts ...DialOption) (net.Conn, error) {
+	conn, _, err := d.DialWithResult(ctx, uri, opts...)
+	return conn, err
+}
+
+// Ping checks that the specified instance is reachable on the data plane. It
+// dials the instance as Dial does, including the TLS handshake and the
+// metadata exchange, and then closes the connection. Unlike a database ping,
+// Ping needs no database or user, so it is suited to smoke tests. The uri
+// argument and options are the same as for Dial. Ping returns the error of
+// the dial, if any, or of closing the connection.
+func (d *Dialer) Ping(ctx context.Context, uri string, opts ...DialOption) error {
+	conn, err := d.Dial(ctx, uri, opts...)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// DialResult describes how DialWithResult connected to an instance.
+type DialResult struct {
+	// IPType is the IP type (PRIVATE, PUBLIC, or PSC) used to connect. With
+	// WithFastFailover, it may differ from the requested IP type.
+	IPType string
+	// Addr is the host:port that was dialed to reach the instance.
+	Addr string
+	// CacheHit reports whether the instance's connection info was already
+	// cached when DialWithResult was called.
+	CacheHit bool
+	// Latency is the time spent in DialWithResult.
+	Latency time.Duration
+	// ForcedRefresh reports whether the dial forced a refresh of the
+	// instance's connection info, e.g., because the client certificate had
+	// expired or the connection attempt failed.
+	ForcedRefresh bool
+}
+
+// DialWithResult is like Dial, but also reports how the connection was
+// established. The result is populated as far as the dial progressed, even
+// when DialWithResult returns an error.
+func (d *Dialer) DialWithResult(
+	ctx context.Context, uri string, opts ...DialOption,
+) (conn net.Conn, res DialResult, err error) {
+	select {
+	case <-d.closed:
+		return nil, res, ErrDialerClosed
+	default:
+	}
+	startTime := time.Now()
+	var endDial tel.EndSpanFunc
+	ctx, endDial = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn.Dial",
+		tel.AddInstanceName(uri),
+		tel.AddDialerID(d.dialerID),
+	)
+	cfg := d.defaultDialCfg
+	cfg.apply(dialOptionsFromContext(ctx))
+	cfg.apply(opts)
+	res.IPType = cfg.ipType
+	defer func() {
+		res.Latency = time.Since(startTime)
+		d.record(func() {
+			tel.RecordDialError(
+				tel.WithConnectionTags(context.Background(), cfg.metricDatabase, cfg.metricUser),
+				uri, d.dialerID, err,
+			)
+		})
+		if d.onDial != nil {
+			e := DialEvent{
+				Instance: uri,
+				IPType:   res.IPType,
+				CacheHit: res.CacheHit,
+				Latency:  res.Latency,
+				Err:      err,
+			}
+			d.record(func() { d.onDial(e) })
+		}
+		endDial(err)
+	}()
+	inst, domainName, err := d.resolveURI(ctx, uri)
+	if err != nil {
+		return nil, res, err
+	}
+	d.addSpanAttributes(ctx, inst)
+	cfg.domainName = domainName
+	if err := d.validate(inst); err != nil {
+		return nil, res, err
+	}
+	if cfg.err != nil {
+		return nil, res, errtype.NewConfigError(cfg.err.Error(), inst.String())
+	}
+	if err := d.runBeforeDial(ctx, inst, &cfg); err != nil {
+		return nil, res, err
+	}
+	res.IPType = cfg.ipType
+	switch cfg.network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, res, errtype.NewConfigError(
+			fmt.Sprintf("unsupported network type %q", cfg.network),
+			inst.String(),
+		)
+	}
+	if err := d.cachedFailure(inst); err != nil {
+		return nil, res, err
+	}
+
+	var certRetried bool
+	for attempt := 0; ; attempt++ {
+		var r DialResult
+		conn, r, err = d.dial(ctx, uri, inst, cfg, startTime)
+		if attempt == 0 {
+			res.CacheHit = r.CacheHit
+		}
+		res.ForcedRefresh = res.ForcedRefresh || r.ForcedRefresh
+		if r.Addr != "" {
+			res.IPType, res.Addr = r.IPType, r.Addr
+		}
+		if err != nil && cfg.certProbeTimeout > 0 && !certRetried && isCertRejectedError(err) {
+			// The connection info has been refreshed, so retry immediately.
+			certRetried = true
+			d.logger.Debugf(
+				ctx, "[%v] Server rejected the client certificate, retrying: %v",
+				inst.String(), err,
+			)
+			continue
+		}
+		if err == nil || attempt >= cfg.dialRetries || !isTransientError(err) {
+			return conn, res, err
+		}
+		backoff := dialRetryBackoff(cfg.dialRetryBackoff, attempt)
+		d.logger.Debugf(
+			ctx, "[%v] Dial attempt %d failed, retrying in %v: %v",
+			inst.String(), attempt+1, backoff, err,
+		)
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, res, err
+		case <-t.C:
+		}
+	}
+}
+
+// dialRetryBackoff returns the wait before the retry following attempt. The
+// wait doubles with each attempt up to maxDialRetryBackoff, or base if it is
+// longer.
+func dialRetryBackoff(base time.Duration, attempt int) time.Duration {
+	limit := max(base, maxDialRetryBackoff)
+	// Shifting base past limit would overflow for large attempts.
+	if base > limit>>attempt {
+		return limit
+	}
+	return base << attempt
+}
+
+// addSpanAttributes adds the parts of the instance URI to the span in ctx
+// when configured with WithSpanInstanceAttributes.
+func (d *Dialer) addSpanAttributes(ctx context.Context, inst instance.URI) {
+	if !d.spanInstanceAttrs {
+		return
+	}
+	tel.AddSpanAttributes(ctx,
+		tel.AddProject(inst.Project()),
+		tel.AddRegion(inst.Region()),
+		tel.AddCluster(inst.Cluster()),
+		tel.AddInstanceID(inst.Name()),
+	)
+}
+
+// validate checks inst with the validator configured with
+// WithInstanceValidator, if any.
+func (d *Dialer) validate(inst instance.URI) error {
+	if d.validateInstance == nil {
+		return nil
+	}
+	if err := d.validateInstance(inst); err != nil {
+		return errtype.NewConfigError(
+			fmt.Sprintf("instance rejected by validator: %v", err), inst.String(),
+		)
+	}
+	return nil
+}
+
+// runBeforeDial passes the IP type and dial function of cfg to the hook
+// configured with WithBeforeDial, if any, and applies the hook's changes.
+func (d *Dialer) runBeforeDial(
+	ctx context.Context, inst instance.URI, cfg *dialCfg,
+) error {
+	if d.beforeDial == nil {
+		return nil
+	}
+	pc := PublicDialConfig{IPType: cfg.ipType, DialFunc: cfg.dialFunc}
+	if err := d.beforeDial(ctx, inst, &pc); err != nil {
+		return errtype.NewConfigError(
+			fmt.Sprintf("before dial hook failed: %v", err), inst.String(),
+		)
+	}
+	switch pc.IPType {
+	case alloydb.PublicIP, alloydb.PrivateIP, alloydb.PSC:
+	default:
+		return errtype.NewConfigError(
+			fmt.Sprintf("before dial hook set unsupported IP type %q", pc.IPType),
+			inst.String(),
+		)
+	}
+	cfg.ipType, cfg.dialFunc = pc.IPType, pc.DialFunc
+	return nil
+}
+
+// resolveURI parses uri as an instance URI. When uri is not an instance URI
+// and a DNS resolver is configured, uri is treated as a domain name and the
+// instance URI is read from its TXT records. resolveURI returns the domain
+// name, if any.
+func (d *Dialer) resolveURI(
+	ctx context.Context, uri string,
+) (instance.URI, string, error) {
+	inst, err := instance.ParseURI(uri)
+	if err == nil || d.dnsResolver == nil {
+		return inst, "", err
+	}
+	records, err := d.dnsResolver.LookupTXT(ctx, uri)
+	if err != nil {
+		return instance.URI{}, "", errtype.NewConfigError(
+			fmt.Sprintf("failed to look up TXT record: %v", err), uri,
+		)
+	}
+	for _, r := range records {
+		if inst, err := instance.ParseURI(r); err == nil {
+			d.logger.Debugf(ctx, "[%v] Resolved domain name %v", inst.String(), uri)
+			return inst, uri, nil
+		}
+	}
+	return instance.URI{}, "", errtype.NewConfigError(
+		"domain name has no TXT record with an instance URI", uri,
+	)
+}
+
+// dial makes a single attempt to connect to the instance. It reports how the
+// attempt connected, as far as it progressed.
+func (d *Dialer) dial(
+	ctx context.Context, uri string, inst instance.URI, cfg dialCfg, startTime time.Time,
+) (conn net.Conn, res DialResult, err error) {
+	cache, ci, addr, cacheHit, err := d.instanceInfo(ctx, inst, cfg, &res.ForcedRefresh)
+	res.CacheHit = cacheHit
+	if err != nil {
+		return nil, res, err
+	}
+
+	var connectEnd tel.EndSpanFunc
+	ctx, connectEnd = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.Connect")
+	defer func() { connectEnd(err) }()
+	// host is the host dialed to reach the instance. It differs from addr,
+	// which verifies the server's certificate, only when dialing a domain
+	// name or when a PSC endpoint resolver is configured.
+	host := addr
+	switch {
+	case cfg.domainName != "":
+		host = cfg.domainName
+	case cfg.ipType == alloydb.PSC && d.pscResolver != nil:
+		host, err = d.pscResolver(uri)
 		if err != nil {
+			return nil, res, errtype.NewDialError(
+				"failed to resolve PSC endpoint", inst.String(), err,
+			)
+		}
+	}
+	hostPort := net.JoinHostPort(host, DefaultServerProxyPort)
+	f := d.dialFunc
+	if cfg.dialFunc != nil {
+		f = cfg.dialFunc
+	}
+	ipType := cfg.ipType
+	secondaryType, ok := secondaryIPType(cfg.ipType)
+	secondaryAddr, hasSecondary := ci.IPAddrs[secondaryType]
+	if cfg.failoverDelay > 0 && ok && hasSecondary && cfg.domainName == "" {
+		d.logger.Debugf(
+			ctx, "[%v] Dialing %v with fast failover to %v",
+			inst.String(), hostPort, secondaryAddr,
+		)
+		conn, addr, err = dialFastFailover(
+			ctx, f, cfg.network, addr, secondaryAddr, cfg.failoverDelay,
+		)
+		host = addr
+		if addr == secondaryAddr {
+			ipType = secondaryType
+		}
+	} else {
+		d.logger.Debugf(ctx, "[%v] Dialing %v", inst.String(), hostPort)
+		conn, err = f(ctx, cfg.network, hostPort)
+	}
+	if err != nil {
+		d.logger.Debugf(ctx, "[%v] Dialing %v failed: %v", inst.String(), hostPort, err)
+		// refresh the instance info in case it caused the connection failure
+		if !d.refreshOnDialFailureDisabled {
+			cache.ForceRefresh()
+			res.ForcedRefresh = true
+		}
+		return nil, res, errtype.NewDialError(
+			"failed to dial", inst.String(), transientError{err},
+		)
+	}
+	if c, ok := conn.(*net.TCPConn); ok {
+		if err := c.SetKeepAlive(true); err != nil {
+			_ = conn.Close() // best effort close attempt
+			return nil, res, errtype.NewDialError("failed to set keep-alive", inst.String(), err)
+		}
+		if err := c.SetKeepAlivePeriod(cfg.tcpKeepAlive); err != nil {
+			_ = conn.Close() // best effort close attempt
+			return nil, res, errtype.NewDialError("failed to set keep-alive period", inst.String(), err)
+		}
+		if cfg.tcpLinger != nil {
+			if err := c.SetLinger(*cfg.tcpLinger); err != nil {
+				_ = conn.Close() // best effort close attempt
+				return nil, res, errtype.NewDialError("failed to set linger", inst.String(), err)
+			}
+		}
+	}
+
+	tlsConn, err := d.secureConn(
+		ctx, inst, cache, ci, serverName(cfg, addr), conn, d.loginTokenSource(cfg),
+		&res.ForcedRefresh,
+	)
+	if err != nil {
+		if isCertRejectedError(err) {
+			// refresh the instance info as the server rejected the client
+			// certificate during the metadata exchange
+			cache.ForceRefresh()
+			res.ForcedRefresh = true
+		}
+		return nil, res, err
+	}
+	var secured net.Conn = tlsConn
+	if cfg.certProbeTimeout > 0 {
+		secured, err = probeConn(tlsConn, cfg.certProbeTimeout)
+		if err != nil {
+			d.logger.Debugf(ctx, "[%v] Probe read failed: %v", inst.String(), err)
+			if isCertRejectedError(err) {
+				cache.ForceRefresh()
+				res.ForcedRefresh = true
+			}
+			_ = tlsConn.Close() // best effort close attempt
+			return nil, res, errtype.NewDialError("probe read failed", inst.String(), err)
+		}
+	}
+	res.IPType, res.Addr = ipType, net.JoinHostPort(host, DefaultServerProxyPort)
+	return d.newConn(
+		ctx, uri, inst, cfg, cache, ci, secured, res.Addr, startTime, res.CacheHit,
+	), res, nil
+}
+
+// probeConn reads from conn for up to timeout to surface the server's
+// rejection of the client certificate, which with TLS 1.3 arrives only after
+// the client has completed the handshake. Reaching the timeout means the
+// certificate was accepted. Any data read is returned by the first read of
+// the returned connection.
+func probeConn(conn *tls.Conn, timeout time.Duration) (net.Conn, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	b := make([]byte, 1)
+	n, err := conn.Read(b)
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil, err
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return conn, nil
+	}
+	return &prefixConn{Conn: conn, prefix: b[:n]}, nil
+}
+
+// prefixConn is a net.Conn whose reads return prefix before reading from the
+// underlying connection.
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+}
+
+func (c *prefixConn) Read(b []byte) (int, error) {
+	if len(c.prefix) == 0 {
+		return c.Conn.Read(b)
+	}
+	n := copy(b, c.prefix)
+	c.prefix = c.prefix[n:]
+	return n, nil
+}
+
+// instanceInfo returns the cache and connection info used to connect to inst
+// and the instance's address for the configured IP type. It reports whether
+// the instance's connection info was already cached and, if forced is not
+// nil, sets forced when it forces a refresh.
+func (d *Dialer) instanceInfo(
+	ctx context.Context, inst instance.URI, cfg dialCfg, forced *bool,
+) (cache monitoredCache, ci alloydb.ConnectionInfo, addr string, cacheHit bool, err error) {
+	// One-off connection info bypasses the Dialer's cache entirely, so there
+	// is nothing to refresh or evict.
+	oneOff := cfg.connInfo != nil
+	if oneOff {
+		ci = oneOffConnectionInfo(inst, *cfg.connInfo)
+		cache = monitoredCache{
+			openConns:           new(uint64),
+			connectionInfoCache: alloydb.NewFixedConnectionInfoCache(d.logger, ci),
+		}
+	} else {
+		var endInfo tel.EndSpanFunc
+		ctx, endInfo = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.InstanceInfo")
+		cache, cacheHit, err = d.connectionInfoCache(ctx, inst)
+		if err != nil {
+			endInfo(err)
+			return cache, ci, "", cacheHit, err
+		}
+		cache.markDialed()
+		ci, err = d.connectionInfo(ctx, inst, cache)
+		if err != nil {
+			// Close closes the caches, so a dial racing with it sees a closed
+			// cache.
+			if errors.Is(err, alloydb.ErrCacheClosed) && d.isClosed() {
+				err = ErrDialerClosed
+			}
+			d.removeCached(ctx, inst, cache, err)
+			endInfo(err)
+			return cache, ci, "", cacheHit, err
+		}
+		endInfo(err)
+	}
+
+	// If the client certificate has expired (as when the computer goes to
+	// sleep, and the refresh cycle cannot run), force a refresh immediately.
+	// The TLS handshake will not fail on an expired client certificate. It's
+	// not until the first read where the client cert error will be surfaced.
+	// So check that the certificate is valid before proceeding.
+	if invalidClientCert(ctx, inst, d.logger, ci.Expiration) {
+		if oneOff {
+			return cache, ci, "", cacheHit, errtype.NewConfigError(
+				"one-off connection info has expired", inst.String(),
+			)
+		}
+		d.logger.Debugf(ctx, "[%v] Refreshing certificate now", inst.String())
+		cache.ForceRefresh()
+		if forced != nil {
+			*forced = true
+		}
+		// Block on refreshed connection info
+		ci, err = cache.ConnectionInfo(ctx)
+		if err != nil {
+			d.removeCached(ctx, inst, cache, err)
+			return cache, ci, "", cacheHit, err
+		}
+	}
+	addr, ok := ci.IPAddrs[cfg.ipType]
+	if !ok {
+		// The connection info is valid, only the requested IP type is
+		// missing, so keep it cached for dials with other IP types.
+		err := errtype.NewConfigError(
+			fmt.Sprintf("instance does not have IP of type %q", cfg.ipType),
+			inst.String(),
+		)
+		d.logger.Debugf(ctx, "[%v] %v", inst.String(), err)
+		return cache, ci, "", cacheHit, err
+	}
+	return cache, ci, addr, cacheHit, nil
+}
+
+// secureConn performs the TLS handshake and the metadata exchange over conn,
+// verifying that the server's certificate is valid for serverName and
+// logging in with a token from login. It closes conn if either fails. If
+// forced is not nil, secureConn sets forced when it forces a refresh.
+func (d *Dialer) secureConn(
+	ctx context.Context, inst instance.URI, cache monitoredCache,
+	ci alloydb.ConnectionInfo, serverName string, conn net.Conn,
+	login oauth2.TokenSource, forced *bool,
+) (*tls.Conn, error) {
+	c := &tls.Config{
+		Certificates: []tls.Certificate{ci.ClientCert},
+		RootCAs:      ci.RootCAs,
+		// The PSC, private, and public IP all appear in the certificate as
+		// SAN. Use the server name that corresponds to the requested
+		// connection path.
+		ServerName:         serverName,
+		MinVersion:         tls.VersionTLS13,
+		ClientSessionCache: newCertSessionCache(cache.sessions, ci.ClientCert),
+	}
+	if d.additionalRootCAs != nil {
+		// A CertPool cannot be merged into another, so verify the server's
+		// certificate against each pool in VerifyConnection instead. It
+		// performs the same checks as the default verification: the chain
+		// and that the certificate is valid for serverName.
+		c.InsecureSkipVerify = true
+		c.VerifyConnection = verifyServerCert(serverName, ci.RootCAs, d.additionalRootCAs)
+	}
+	tlsConn := tls.Client(conn, c)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		d.logger.Debugf(ctx, "[%v] TLS handshake failed: %v", inst.String(), err)
+		// refresh the instance info in case it caused the handshake failure
+		if !d.refreshOnDialFailureDisabled {
+			cache.ForceRefresh()
+			if forced != nil {
+				*forced = true
+			}
+		}
+		_ = tlsConn.Close() // best effort close attempt
+		return nil, errtype.NewDialError(
+			"handshake failed", inst.String(), transientError{err},
+		)
+	}
+
+	if !d.disableMetadataExchange {
+		// The metadata exchange must occur after the TLS connection is established
+		// to avoid leaking sensitive information.
+		if d.authType == AuthTypeAutoIAM {
+			d.logger.Debugf(
+				ctx, "[%v] Using IAM authentication, any database password is ignored",
+				inst.String(),
+			)
+		}
+		if err := d.metadataExchange(tlsConn, inst, login); err != nil {
+			_ = tlsConn.Close() // best effort close attempt
 			return nil, err
 		}
 	}
 
-	client, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, cfg.adminOpts...)
+	return tlsConn, nil
+}
+
+// serverName returns the name used to verify the server's certificate: the
+// one configured with WithServerNameOverride, if any, or addr.
+func serverName(cfg dialCfg, addr string) string {
+	if cfg.serverName != "" {
+		return cfg.serverName
+	}
+	return addr
+}
+
+// loginTokenSource returns the token source used to log in to the database
+// in the metadata exchange: the one configured with WithDialCredentials, if
+// any, or the Dialer's.
+func (d *Dialer) loginTokenSource(cfg dialCfg) oauth2.TokenSource {
+	if cfg.loginTokenSource != nil {
+		return cfg.loginTokenSource
+	}
+	return d.iamTokenSource
+}
+
+// verifyServerCert returns a function that verifies that the server's
+// certificate chains to a root in any of the pools and is valid for
+// serverName.
+func verifyServerCert(
+	serverName string, pools ...*x509.CertPool,
+) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("server presented no certificate")
+		}
+		intermediates := x509.NewCertPool()
+		for _, c := range cs.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+		var err error
+		for _, roots := range pools {
+			_, err = cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+				Roots:         roots,
+				Intermediates: intermediates,
+				DNSName:       serverName,
+			})
+			if err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// newConn records metrics for a new connection to inst and wraps it to update
+// the count of open connections when it is closed. cacheHit reports whether
+// the instance's connection info was already cached.
+func (d *Dialer) newConn(
+	ctx context.Context, uri string, inst instance.URI, cfg dialCfg,
+	cache monitoredCache, ci alloydb.ConnectionInfo, tlsConn net.Conn,
+	hostPort string, startTime time.Time, cacheHit bool,
+) net.Conn {
+	latency := time.Since(startTime).Milliseconds()
+	// Connections with metric tags are counted separately, as each
+	// combination of tags is a separate time series.
+	openConns := cache.openConns
+	if cfg.metricDatabase != "" || cfg.metricUser != "" {
+		openConns = d.taggedOpenConns(connTags{
+			instance: inst, database: cfg.metricDatabase, user: cfg.metricUser,
+		})
+	}
+	d.record(func() {
+		tagged := tel.WithConnectionTags(ctx, cfg.metricDatabase, cfg.metricUser)
+		n := atomic.AddUint64(openConns, 1)
+		tel.RecordOpenConnections(tagged, int64(n), d.dialerID, inst.String())
+		tel.RecordDialLatency(tagged, uri, d.dialerID, latency, cacheHit)
+		tel.RecordCertExpiry(tagged, inst.String(), d.dialerID, time.Until(ci.Expiration))
+	})
+
+	conn := newInstrumentedConn(tlsConn, func() {
+		n := atomic.AddUint64(openConns, ^uint64(0))
+		tel.RecordOpenConnections(
+			tel.WithConnectionTags(context.Background(), cfg.metricDatabase, cfg.metricUser),
+			int64(n), d.dialerID, inst.String(),
+		)
+	}, d.dialerID, inst.String(), hostPort)
+	conn.errFunc = cache.refreshOnConnErr
+	conn.record = d.record
+	conn.startTimers(cfg.maxConnLifetime, cfg.idleTimeout)
+	return conn
+}
+
+// connTags identifies the connections to an instance that share metric tags.
+type connTags struct {
+	instance       instance.URI
+	database, user string
+}
+
+// taggedOpenConns returns the counter of open connections with tags t.
+func (d *Dialer) taggedOpenConns(t connTags) *uint64 {
+	n, _ := d.taggedConns.LoadOrStore(t, new(uint64))
+	return n.(*uint64)
+}
+
+// record runs f, which records metrics or invokes a callback, in its own
+// goroutine unless background work is disabled.
+func (d *Dialer) record(f func()) {
+	if d.backgroundDisabled {
+		f()
+		return
+	}
+	go f()
+}
+
+// Secure returns a secure connection to the AlloyDB instance specified by the
+// instance URI over rawConn, an established connection to the instance's
+// server-side proxy (port 5433). Secure decouples establishing the transport,
+// e.g., through a bespoke tunnel, from securing it: it performs only the TLS
+// handshake and the metadata exchange, using the instance's connection info
+// as Dial does. The IP type options determine the server name used to verify
+// the instance's certificate. Options that configure the transport, such as
+// WithDialFunc or WithTCPKeepAlive, have no effect.
+//
+// If Secure fails, rawConn is closed.
+func (d *Dialer) Secure(
+	ctx context.Context, uri string, rawConn net.Conn, opts ...DialOption,
+) (conn net.Conn, err error) {
+	defer func() {
+		if err != nil {
+			_ = rawConn.Close() // best effort close attempt
+		}
+	}()
+	select {
+	case <-d.closed:
+		return nil, ErrDialerClosed
+	default:
+	}
+	startTime := time.Now()
+	var endSecure tel.EndSpanFunc
+	ctx, endSecure = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn.Secure",
+		tel.AddInstanceName(uri),
+		tel.AddDialerID(d.dialerID),
+	)
+	defer func() { endSecure(err) }()
+	cfg := d.defaultDialCfg
+	cfg.apply(dialOptionsFromContext(ctx))
+	cfg.apply(opts)
+	inst, err := instance.ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	d.addSpanAttributes(ctx, inst)
+	if err := d.validate(inst); err != nil {
+		return nil, err
+	}
+	if cfg.err != nil {
+		return nil, errtype.NewConfigError(cfg.err.Error(), inst.String())
+	}
+	if err := d.cachedFailure(inst); err != nil {
+		return nil, err
+	}
+	cache, ci, addr, cacheHit, err := d.instanceInfo(ctx, inst, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn, err := d.secureConn(
+		ctx, inst, cache, ci, serverName(cfg, addr), rawConn, d.loginTokenSource(cfg), nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return d.newConn(
+		ctx, uri, inst, cfg, cache, ci, tlsConn,
+		rawConn.RemoteAddr().String(), startTime, cacheHit,
+	), nil
+}
+
+// DialEvent describes the outcome of a call to Dial. See WithOnDial.
+type DialEvent struct {
+	// Instance is the instance URI passed to Dial.
+	Instance string
+	// IPType is the IP type (PRIVATE, PUBLIC, or PSC) used to connect.
+	IPType string
+	// CacheHit reports whether the instance's connection info was already
+	// cached when Dial was called.
+	CacheHit bool
+	// Latency is the time spent in Dial.
+	Latency time.Duration
+	// Err is the error returned by Dial, if any.
+	Err error
+}
+
+// RefreshStatus reports the refresh state of the connection info cached for
+// an instance.
+type RefreshStatus struct {
+	// LastRefresh is the time of the most recent successful refresh. It is
+	// zero if no refresh has succeeded yet.
+	LastRefresh time.Time
+	// LastFailure is the time of the most recent failed refresh. It is zero
+	// if no refresh has failed yet.
+	LastFailure time.Time
+	// Expiration is the expiration of the current client certificate. It is
+	// zero if no certificate has been retrieved yet.
+	Expiration time.Time
+	// NextRefresh is the time the next background refresh is scheduled to
+	// run. It is always zero when the dialer uses lazy refresh or static
+	// connection info, as neither refreshes in the background.
+	NextRefresh time.Time
+}
+
+// RefreshEvent describes a completed refresh of an instance's connection info.
+// See WithRefreshNotifier.
+type RefreshEvent struct {
+	// Instance is the URI of the refreshed instance.
+	Instance string
+	// Time is when the refresh completed.
+	Time time.Time
+	// Err is the error of a failed refresh. It is nil if the refresh
+	// succeeded.
+	Err error
+}
+
+// notifyRefresh sends a RefreshEvent for instance i to the configured
+// notifier, dropping the event if the notifier's channel is full.
+func (d *Dialer) notifyRefresh(i instance.URI, err error) {
+	e := RefreshEvent{Instance: i.URI(), Time: time.Now(), Err: err}
+	select {
+	case d.refreshNotifier <- e:
+	default:
+	}
+}
+
+// RefreshStatus reports the refresh state of the specified instance without
+// triggering a refresh. The uri argument must be the instance's URI and the
+// instance must have been dialed previously.
+func (d *Dialer) RefreshStatus(uri string) (RefreshStatus, error) {
+	inst, err := instance.ParseURI(uri)
+	if err != nil {
+		return RefreshStatus{}, err
+	}
+	d.lock.RLock()
+	c, ok := d.cache[inst]
+	d.lock.RUnlock()
+	if !ok {
+		return RefreshStatus{}, fmt.Errorf(
+			"alloydbconn: no connection info cached for %v", inst.String(),
+		)
+	}
+	return newRefreshStatus(c.RefreshStatus()), nil
+}
+
+// RefreshMode reports how the Dialer caches connection info: "lazy" when it
+// was configured with WithLazyRefresh or WithBackgroundRefreshDisabled, and
+// "refresh-ahead" otherwise. It does not reflect options that replace the
+// built-in caches, such as WithConnectionInfoCacheFactory.
+func (d *Dialer) RefreshMode() string {
+	if d.lazyRefresh {
+		return "lazy"
+	}
+	return "refresh-ahead"
+}
+
+// newRefreshStatus copies the internal refresh status.
+func newRefreshStatus(s alloydb.RefreshStatus) RefreshStatus {
+	return RefreshStatus{
+		LastRefresh: s.LastRefresh,
+		LastFailure: s.LastFailure,
+		Expiration:  s.Expiration,
+		NextRefresh: s.NextRefresh,
+	}
+}
+
+// DialerStats is a snapshot of the state of a Dialer.
+type DialerStats struct {
+	// CachedInstances is the number of instances whose connection info is
+	// cached.
+	CachedInstances int
+	// OpenConnections is the number of open connections across all cached
+	// instances.
+	OpenConnections uint64
+	// Instances reports the state of each cached instance, sorted by
+	// instance URI.
+	Instances []InstanceStats
+}
+
+// InstanceStats is a snapshot of the state of a single cached instance.
+type InstanceStats struct {
+	// Instance is the instance URI, e.g.,
+	// projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance.
+	Instance string
+	// OpenConnections is the number of open connections to the instance.
+	OpenConnections uint64
+	// RefreshStatus reports the refresh state of the instance's connection
+	// info.
+	RefreshStatus
+}
+
+// Stats returns a snapshot of the instances the Dialer has cached, their
+// open connections, and the state of their connection info, e.g., for a
+// debug HTTP handler. Calling Stats does not trigger a refresh and is safe
+// for concurrent use.
+func (d *Dialer) Stats() DialerStats {
+	d.lock.RLock()
+	s := DialerStats{
+		CachedInstances: len(d.cache),
+		Instances:       make([]InstanceStats, 0, len(d.cache)),
+	}
+	caches := make([]monitoredCache, 0, len(d.cache))
+	for uri, c := range d.cache {
+		s.Instances = append(s.Instances, InstanceStats{Instance: uri.URI()})
+		caches = append(caches, c)
+	}
+	d.lock.RUnlock()
+
+	// Read the refresh status outside of the lock, as it may wait on the
+	// cache's own lock.
+	for i, c := range caches {
+		open := atomic.LoadUint64(c.openConns)
+		s.Instances[i].OpenConnections = open
+		s.Instances[i].RefreshStatus = newRefreshStatus(c.RefreshStatus())
+		s.OpenConnections += open
+	}
+	sort.Slice(s.Instances, func(i, j int) bool {
+		return s.Instances[i].Instance < s.Instances[j].Instance
+	})
+	return s
+}
+
+// ConnectionInfo is the public view of the information a Dialer uses to
+// connect to an instance. It includes the instance's IP addresses and the
+// expiration of the client certificate. Dialer.ConnectionInfo deliberately
+// excludes the client certificate, its private key, and the instance's CA
+// certificates; those fields are only set by callers of
+// WithOneOffConnectionInfo and by a ConnectionInfoCache.
+type ConnectionInfo struct {
+	// Instance is the instance's URI.
+	Instance string
+	// IPAddrs maps an IP type (PRIVATE, PUBLIC, or PSC) to the instance's
+	// address of that type. Only the IP types enabled on the instance are
+	// present.
+	IPAddrs map[string]string
+	// Expiration is the expiration of the client certificate.
+	Expiration time.Time
+	// ClientCert is the client certificate chain and its private key. It is
+	// only used by WithOneOffConnectionInfo and a ConnectionInfoCache.
+	ClientCert tls.Certificate
+	// RootCAs holds the instance's CA certificates. It is only used by
+	// WithOneOffConnectionInfo and a ConnectionInfoCache.
+	RootCAs *x509.CertPool
+}
+
+// ConnectionInfoCache provides the connection info a Dialer uses to connect
+// to an instance. Implementations may, e.g., share connection info across
+// processes through an external store. See WithConnectionInfoCacheFactory.
+type ConnectionInfoCache interface {
+	// ConnectionInfo returns the instance's connection info, including the
+	// client certificate with its private key and the instance's CA
+	// certificates.
+	ConnectionInfo(ctx context.Context) (ConnectionInfo, error)
+	// ForceRefresh invalidates the current connection info, e.g., after a
+	// failed TLS handshake. The next call to ConnectionInfo should return
+	// new connection info.
+	ForceRefresh()
+	// Close releases any resources held by the cache. The Dialer closes the
+	// cache when the Dialer is closed or when it removes the instance from
+	// its cache.
+	io.Closer
+}
+
+// ConnectionInfoCacheFactory creates the ConnectionInfoCache for an instance.
+// The Dialer calls it once for each instance it connects to.
+type ConnectionInfoCacheFactory func(inst instance.URI) (ConnectionInfoCache, error)
+
+// factoryCache adapts a ConnectionInfoCache created by a
+// ConnectionInfoCacheFactory to a connectionInfoCache.
+type factoryCache struct {
+	inst  instance.URI
+	cache ConnectionInfoCache
+
+	mu sync.Mutex
+	// status describes the most recent connection info.
+	status alloydb.RefreshStatus
+}
+
+func (c *factoryCache) ConnectionInfo(ctx context.Context) (alloydb.ConnectionInfo, error) {
+	ci, err := c.cache.ConnectionInfo(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AlloyDB Admin API client: %v", err)
+		return alloydb.ConnectionInfo{}, err
 	}
+	c.mu.Lock()
+	c.status.Expiration = ci.Expiration
+	c.mu.Unlock()
+	return oneOffConnectionInfo(c.inst, ci), nil
+}
 
-	dialCfg := dialCfg{
-		ipType:       alloydb.PrivateIP,
-		tcpKeepAlive: defaultTCPKeepAlive,
-	}
-	for _, opt := range cfg.dialOpts {
-		opt(&dialCfg)
-	}
+func (c *factoryCache) ForceRefresh() {
+	c.cache.ForceRefresh()
+}
 
-	if err := tel.InitMetrics(); err != nil {
-		return nil, err
+// RefreshStatus reports the expiration of the most recent connection info.
+// The Dialer does not know when the cache refreshes, so LastRefresh and
+// NextRefresh are always zero.
+func (c *factoryCache) RefreshStatus() alloydb.RefreshStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+func (c *factoryCache) Close() error {
+	return c.cache.Close()
+}
+
+// newConnectionInfo copies the public fields of the internal connection info.
+func newConnectionInfo(ci alloydb.ConnectionInfo) ConnectionInfo {
+	addrs := make(map[string]string, len(ci.IPAddrs))
+	for k, v := range ci.IPAddrs {
+		addrs[k] = v
 	}
-	g, err := newKeyGenerator(cfg.rsaKey, cfg.lazyRefresh,
-		func() (*rsa.PrivateKey, error) {
-			return rsa.GenerateKey(rand.Reader, 2048)
-		})
-	if err != nil {
-		return nil, err
+	return ConnectionInfo{
+		Instance:   ci.Instance.URI(),
+		IPAddrs:    addrs,
+		Expiration: ci.Expiration,
 	}
-	d := &Dialer{
-		closed:                  make(chan struct{}),
-		cache:                   make(map[alloydb.InstanceURI]monitoredCache),
-		lazyRefresh:             cfg.lazyRefresh,
-		disableMetadataExchange: cfg.disableMetadataExchange,
-		staticConnInfo:          cfg.staticConnInfo,
-		keyGenerator:            g,
-		refreshTimeout:          cfg.refreshTimeout,
-		client:                  client,
-		logger:                  cfg.logger,
-		defaultDialCfg:          dialCfg,
-		dialerID:                uuid.New().String(),
-		dialFunc:                cfg.dialFunc,
-		useIAMAuthN:             cfg.useIAMAuthN,
-		iamTokenSource:          ts,
-		userAgent:               userAgent,
-		buffer:                  newBuffer(),
+}
+
+// oneOffConnectionInfo converts connection info passed to
+// WithOneOffConnectionInfo for use in a dial to inst.
+func oneOffConnectionInfo(inst instance.URI, ci ConnectionInfo) alloydb.ConnectionInfo {
+	return alloydb.ConnectionInfo{
+		Instance:   inst,
+		IPAddrs:    ci.IPAddrs,
+		ClientCert: ci.ClientCert,
+		RootCAs:    ci.RootCAs,
+		Expiration: ci.Expiration,
 	}
-	return d, nil
 }
 
-// Dial returns a net.Conn connected to the specified AlloyDB instance. The
-// instance argument must be the instance's URI, which is in the format
-// projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>
-func (d *Dialer) Dial(ctx context.Context, instance string, opts ...DialOption) (conn net.Conn, err error) {
+// ConnectionInfo returns the connection info for the specified instance. The
+// uri argument must be the instance's URI. If the instance has not been
+// dialed before, ConnectionInfo retrieves its connection info and caches it
+// for later calls to Dial.
+func (d *Dialer) ConnectionInfo(ctx context.Context, uri string) (ConnectionInfo, error) {
 	select {
 	case <-d.closed:
-		return nil, ErrDialerClosed
+		return ConnectionInfo{}, ErrDialerClosed
 	default:
 	}
-	startTime := time.Now()
-	var endDial tel.EndSpanFunc
-	ctx, endDial = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn.Dial",
-		tel.AddInstanceName(instance),
-		tel.AddDialerID(d.dialerID),
-	)
-	defer func() {
-		go tel.RecordDialError(context.Background(), instance, d.dialerID, err)
-		endDial(err)
-	}()
-	cfg := d.defaultDialCfg
-	for _, opt := range opts {
-		opt(&cfg)
-	}
-	inst, err := alloydb.ParseInstURI(instance)
+	inst, err := instance.ParseURI(uri)
 	if err != nil {
-		return nil, err
+		return ConnectionInfo{}, err
 	}
-
-	var endInfo tel.EndSpanFunc
-	ctx, endInfo = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.InstanceInfo")
-	cache, err := d.connectionInfoCache(ctx, inst)
+	if err := d.validate(inst); err != nil {
+		return ConnectionInfo{}, err
+	}
+	if err := d.cachedFailure(inst); err != nil {
+		return ConnectionInfo{}, err
+	}
+	cache, _, err := d.connectionInfoCache(ctx, inst)
 	if err != nil {
-		endInfo(err)
-		return nil, err
+		return ConnectionInfo{}, err
 	}
-	ci, err := cache.ConnectionInfo(ctx)
+	ci, err := d.connectionInfo(ctx, inst, cache)
 	if err != nil {
 		d.removeCached(ctx, inst, cache, err)
-		endInfo(err)
-		return nil, err
+		return ConnectionInfo{}, err
 	}
-	endInfo(err)
+	return newConnectionInfo(ci), nil
+}
 
-	// If the client certificate has expired (as when the computer goes to
-	// sleep, and the refresh cycle cannot run), force a refresh immediately.
-	// The TLS handshake will not fail on an expired client certificate. It's
-	// not until the first read where the client cert error will be surfaced.
-	// So check that the certificate is valid before proceeding.
-	if invalidClientCert(ctx, inst, d.logger, ci.Expiration) {
-		d.logger.Debugf(ctx, "[%v] Refreshing certificate now", inst.String())
-		cache.ForceRefresh()
-		// Block on refreshed connection info
-		ci, err = cache.ConnectionInfo(ctx)
-		if err != nil {
-			d.removeCached(ctx, inst, cache, err)
-			return nil, err
+// WarmupAll retrieves and caches the connection info of each of the
+// specified instances, so that later calls to Dial do not wait for it, e.g.,
+// at the startup of an application that connects to many instances. It warms
+// up to 10 instances at once and returns once all are done, with an entry in
+// the returned map for each instance: nil if the instance was warmed up, or
+// the error Dial would have returned before connecting. The uri arguments and
+// options are the same as for Dial; options such as WithPublicIP check that
+// each instance has the requested IP type.
+func (d *Dialer) WarmupAll(
+	ctx context.Context, uris []string, opts ...DialOption,
+) map[string]error {
+	cfg := d.defaultDialCfg
+	cfg.apply(dialOptionsFromContext(ctx))
+	cfg.apply(opts)
+	var (
+		mu   sync.Mutex
+		errs = make(map[string]error, len(uris))
+		seen = make(map[string]bool, len(uris))
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, warmupConcurrency)
+	)
+	for _, uri := range uris {
+		if seen[uri] {
+			continue
 		}
+		seen[uri] = true
+		wg.Add(1)
+		go func(uri string) {
+			defer wg.Done()
+			var err error
+			select {
+			case sem <- struct{}{}:
+				err = d.warmup(ctx, uri, cfg)
+				<-sem
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			errs[uri] = err
+		}(uri)
 	}
-	addr, ok := ci.IPAddrs[cfg.ipType]
-	if !ok {
-		d.removeCached(ctx, inst, cache, err)
-		err := errtype.NewConfigError(
-			fmt.Sprintf("instance does not have IP of type %q", cfg.ipType),
-			inst.String(),
-		)
-		return nil, err
-	}
+	wg.Wait()
+	return errs
+}
 
-	var connectEnd tel.EndSpanFunc
-	ctx, connectEnd = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.Connect")
-	defer func() { connectEnd(err) }()
-	hostPort := net.JoinHostPort(addr, serverProxyPort)
-	f := d.dialFunc
-	if cfg.dialFunc != nil {
-		f = cfg.dialFunc
+// warmup retrieves and caches the connection info of a single instance.
+func (d *Dialer) warmup(ctx context.Context, uri string, cfg dialCfg) error {
+	select {
+	case <-d.closed:
+		return ErrDialerClosed
+	default:
 	}
-	d.logger.Debugf(ctx, "[%v] Dialing %v", inst.String(), hostPort)
-	conn, err = f(ctx, "tcp", hostPort)
+	inst, _, err := d.resolveURI(ctx, uri)
 	if err != nil {
-		d.logger.Debugf(ctx, "[%v] Dialing %v failed: %v", inst.String(), hostPort, err)
-		// refresh the instance info in case it caused the connection failure
-		cache.ForceRefresh()
-		return nil, errtype.NewDialError("failed to dial", inst.String(), err)
+		return err
 	}
-	if c, ok := conn.(*net.TCPConn); ok {
-		if err := c.SetKeepAlive(true); err != nil {
-			return nil, errtype.NewDialError("failed to set keep-alive", inst.String(), err)
-		}
-		if err := c.SetKeepAlivePeriod(cfg.tcpKeepAlive); err != nil {
-			return nil, errtype.NewDialError("failed to set keep-alive period", inst.String(), err)
-		}
+	if err := d.validate(inst); err != nil {
+		return err
 	}
-
-	c := &tls.Config{
-		Certificates: []tls.Certificate{ci.ClientCert},
-		RootCAs:      ci.RootCAs,
-		// The PSC, private, and public IP all appear in the certificate as
-		// SAN. Use the server name that corresponds to the requested
-		// connection path.
-		ServerName: addr,
-		MinVersion: tls.VersionTLS13,
+	if cfg.err != nil {
+		return errtype.NewConfigError(cfg.err.Error(), inst.String())
 	}
-	tlsConn := tls.Client(conn, c)
-	if err := tlsConn.HandshakeContext(ctx); err != nil {
-		d.logger.Debugf(ctx, "[%v] TLS handshake failed: %v", inst.String(), err)
-		// refresh the instance info in case it caused the handshake failure
-		cache.ForceRefresh()
-		_ = tlsConn.Close() // best effort close attempt
-		return nil, errtype.NewDialError("handshake failed", inst.String(), err)
+	if err := d.cachedFailure(inst); err != nil {
+		return err
 	}
+	_, _, _, _, err = d.instanceInfo(ctx, inst, cfg, nil)
+	return err
+}
 
-	if !d.disableMetadataExchange {
-		// The metadata exchange must occur after the TLS connection is established
-		// to avoid leaking sensitive information.
-		err = d.metadataExchange(tlsConn)
-		if err != nil {
-			_ = tlsConn.Close() // best effort close attempt
-			return nil, err
+// connectionInfo returns the connection info from the cache. Until the cache
+// has returned unexpired connection info, concurrent callers for the same
+// cache share a single call to the cache. The shared call is not canceled
+// when any one caller's context is done, but each caller stops waiting when
+// its own context is done. The shared call itself is limited to the refresh
+// timeout, so a caller waits no longer than the sooner of its context's
+// deadline and the refresh timeout.
+func (d *Dialer) connectionInfo(
+	ctx context.Context, inst instance.URI, cache monitoredCache,
+) (alloydb.ConnectionInfo, error) {
+	// Most dials find unexpired connection info, which the cache returns
+	// without calling the Admin API, so call the cache directly.
+	if cache.hasValidInfo() {
+		ci, err := cache.ConnectionInfo(ctx)
+		cache.setExpiration(ci, err)
+		return ci, err
+	}
+	// Key the call by the cache, not only the instance, so that callers
+	// using a cache created after Invalidate or removal never join a call on
+	// the previous, closed cache. openConns identifies the cache.
+	key := fmt.Sprintf("%s/%p", inst.String(), cache.openConns)
+	ch := d.infoGroup.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(
+			context.WithoutCancel(ctx), d.refreshTimeout,
+		)
+		defer cancel()
+		return cache.ConnectionInfo(ctx)
+	})
+	select {
+	case r := <-ch:
+		if r.Err != nil {
+			cache.setExpiration(alloydb.ConnectionInfo{}, r.Err)
+			return alloydb.ConnectionInfo{}, r.Err
 		}
+		ci := r.Val.(alloydb.ConnectionInfo)
+		cache.setExpiration(ci, nil)
+		return ci, nil
+	case <-ctx.Done():
+		return alloydb.ConnectionInfo{}, ctx.Err()
 	}
+}
 
-	latency := time.Since(startTime).Milliseconds()
-	go func() {
-		n := atomic.AddUint64(cache.openConns, 1)
-		tel.RecordOpenConnections(ctx, int64(n), d.dialerID, inst.String())
-		tel.RecordDialLatency(ctx, instance, d.dialerID, latency)
-	}()
-
-	return newInstrumentedConn(tlsConn, func() {
-		n := atomic.AddUint64(cache.openConns, ^uint64(0))
-		tel.RecordOpenConnections(context.Background(), int64(n), d.dialerID, inst.String())
-	}, d.dialerID, inst.String()), nil
+// Invalidate closes and removes the cached connection info of the instance
+// with the provided URI, along with any cached refresh failure and the
+// count of its open connections, so that the next Dial retrieves fresh
+// connection info, e.g., after the instance was recreated. Connections that
+// are already open are not closed. Invalidate does nothing if no connection
+// info is cached for the instance.
+func (d *Dialer) Invalidate(uri string) error {
+	select {
+	case <-d.closed:
+		return ErrDialerClosed
+	default:
+	}
+	inst, err := instance.ParseURI(uri)
+	if err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.failures, inst)
+	c, ok := d.cache[inst]
+	if !ok {
+		return nil
+	}
+	d.logger.Debugf(
+		context.Background(),
+		"[%v] Removing connection info from cache on request",
+		inst.String(),
+	)
+	c.Close()
+	delete(d.cache, inst)
+	return nil
 }
 
 // removeCached stops all background refreshes and deletes the connection
 // info cache from the map of caches.
 func (d *Dialer) removeCached(
 	ctx context.Context,
-	i alloydb.InstanceURI, c connectionInfoCache, err error,
+	i instance.URI, c connectionInfoCache, err error,
 ) {
 	d.logger.Debugf(
 		ctx,
@@ -400,11 +2034,106 @@ func (d *Dialer) removeCached(
 	defer d.lock.Unlock()
 	c.Close()
 	delete(d.cache, i)
+	if d.negativeCacheTTL > 0 && isPermanentRefreshError(err) {
+		d.failures[i] = cachedFailure{
+			err:     err,
+			expires: time.Now().Add(d.negativeCacheTTL),
+		}
+	}
+}
+
+// removeStopped closes and removes the cache of instance i after the cache
+// stopped refreshing because of repeated failures, so that a later Dial
+// creates a new cache. openConns identifies the stopped cache, which may
+// already have been replaced.
+func (d *Dialer) removeStopped(i instance.URI, openConns *uint64, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c, ok := d.cache[i]
+	if !ok || c.openConns != openConns {
+		return
+	}
+	d.logger.Debugf(
+		context.Background(),
+		"[%v] Removing connection info from cache after repeated refresh failures: %v",
+		i.String(),
+		err,
+	)
+	c.Close()
+	delete(d.cache, i)
+}
+
+// evictLeastRecentlyDialed closes and removes the cache of the least recently
+// dialed instance without open connections when the cache is full, making
+// room for another instance. The caller must hold d.lock.
+func (d *Dialer) evictLeastRecentlyDialed(ctx context.Context) {
+	if len(d.cache) < d.maxCachedInstances {
+		return
+	}
+	var (
+		oldest instance.URI
+		found  bool
+		last   int64
+	)
+	for uri, c := range d.cache {
+		if atomic.LoadUint64(c.openConns) > 0 {
+			continue
+		}
+		if t := c.lastDialed(); !found || t < last {
+			oldest, last, found = uri, t, true
+		}
+	}
+	if !found {
+		return
+	}
+	d.logger.Debugf(
+		ctx,
+		"[%v] Evicting least recently dialed connection info from cache",
+		oldest.String(),
+	)
+	d.cache[oldest].Close()
+	delete(d.cache, oldest)
+}
+
+// cachedFailure is a permanent refresh error for an instance that is returned
+// by Dial until it expires.
+type cachedFailure struct {
+	err     error
+	expires time.Time
+}
+
+// cachedFailure returns the cached permanent refresh error for an instance,
+// if one exists and has not expired.
+func (d *Dialer) cachedFailure(i instance.URI) error {
+	d.lock.RLock()
+	f, ok := d.failures[i]
+	d.lock.RUnlock()
+	if !ok {
+		return nil
+	}
+	if time.Now().Before(f.expires) {
+		return f.err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	// Recheck to ensure the failure wasn't replaced between locks
+	if f, ok := d.failures[i]; ok && !time.Now().Before(f.expires) {
+		delete(d.failures, i)
+	}
+	return nil
+}
+
+// isPermanentRefreshError reports whether err is a refresh error that is not
+// expected to resolve by retrying, i.e., the instance does not exist or the
+// caller is not permitted to access it.
+func isPermanentRefreshError(err error) bool {
+	return errors.Is(err, ErrInstanceNotFound) ||
+		errors.Is(err, ErrPermissionDenied)
 }
 
 func invalidClientCert(
 	ctx context.Context,
-	inst alloydb.InstanceURI, l debug.ContextLogger, expiration time.Time,
+	inst instance.URI, l debug.ContextLogger, expiration time.Time,
 ) bool {
 	now := time.Now().UTC()
 	notAfter := expiration.UTC()
@@ -420,6 +2149,14 @@ func invalidClientCert(
 	return invalid
 }
 
+// metadataExchangeAuthTypes maps each supported AuthType to the auth type sent
+// in the metadata exchange. Supporting a new auth type only requires adding
+// it here.
+var metadataExchangeAuthTypes = map[AuthType]connectorspb.MetadataExchangeRequest_AuthType{
+	AuthTypeDBNative: connectorspb.MetadataExchangeRequest_DB_NATIVE,
+	AuthTypeAutoIAM:  connectorspb.MetadataExchangeRequest_AUTO_IAM,
+}
+
 // metadataExchange sends metadata about the connection prior to the database
 // protocol taking over. The exchange consists of four steps:
 //
@@ -435,22 +2172,21 @@ func invalidClientCert(
 //     four bytes.
 //
 //  4. Unmarshal the response using the message length in step 3. If the
-//     response is not OK, return the response's error. If there is no error, the
-//     metadata exchange has succeeded and the connection is complete.
+//     response is not OK, return a MetadataExchangeError carrying the
+//     response's code and error. If there is no error, the metadata exchange
+//     has succeeded and the connection is complete.
 //
 // Subsequent interactions with the server use the database protocol.
-func (d *Dialer) metadataExchange(conn net.Conn) error {
-	tok, err := d.iamTokenSource.Token()
+func (d *Dialer) metadataExchange(
+	conn net.Conn, inst instance.URI, login oauth2.TokenSource,
+) error {
+	tok, err := login.Token()
 	if err != nil {
 		return err
 	}
-	authType := connectorspb.MetadataExchangeRequest_DB_NATIVE
-	if d.useIAMAuthN {
-		authType = connectorspb.MetadataExchangeRequest_AUTO_IAM
-	}
 	req := &connectorspb.MetadataExchangeRequest{
 		UserAgent:   d.userAgent,
-		AuthType:    authType,
+		AuthType:    metadataExchangeAuthTypes[d.authType],
 		Oauth2Token: tok.AccessToken,
 	}
 	m, err := proto.Marshal(req)
@@ -466,7 +2202,7 @@ func (d *Dialer) metadataExchange(conn net.Conn) error {
 	buf = append(buf[:4], m...)
 
 	// Set IO deadline before write
-	err = conn.SetDeadline(time.Now().Add(ioTimeout))
+	err = conn.SetDeadline(time.Now().Add(d.metadataExchangeTimeout))
 	if err != nil {
 		return err
 	}
@@ -478,7 +2214,7 @@ func (d *Dialer) metadataExchange(conn net.Conn) error {
 	}
 
 	// Reset IO deadline before read
-	err = conn.SetDeadline(time.Now().Add(ioTimeout))
+	err = conn.SetDeadline(time.Now().Add(d.metadataExchangeTimeout))
 	if err != nil {
 		return err
 	}
@@ -491,6 +2227,12 @@ func (d *Dialer) metadataExchange(conn net.Conn) error {
 	}
 
 	respSize := binary.BigEndian.Uint32(buf)
+	if int64(respSize) > int64(cap(buf)) {
+		return fmt.Errorf(
+			"metadata exchange response size %d exceeds buffer size %d",
+			respSize, cap(buf),
+		)
+	}
 	resp := buf[:respSize]
 	_, err = conn.Read(resp)
 	if err != nil {
@@ -504,23 +2246,33 @@ func (d *Dialer) metadataExchange(conn net.Conn) error {
 	}
 
 	if mdxResp.GetResponseCode() != connectorspb.MetadataExchangeResponse_OK {
-		return errors.New(mdxResp.GetError())
+		return errtype.NewMetadataExchangeError(
+			inst.String(), mdxResp.GetResponseCode().String(), mdxResp.GetError(),
+		)
 	}
 
 	return nil
 }
 
-const maxMessageSize = 16 * 1024 // 16 kb
+const (
+	// maxMessageSize is the default and maximum size of the buffers used for
+	// the metadata exchange.
+	maxMessageSize = 16 * 1024 // 16 kb
+	// minMessageSize is the minimum size of the buffers used for the metadata
+	// exchange.
+	minMessageSize = 512
+)
 
 type buffer struct {
 	pool sync.Pool
 }
 
-func newBuffer() *buffer {
+// newBuffer initializes a pool of buffers of the provided size.
+func newBuffer(size int) *buffer {
 	return &buffer{
 		pool: sync.Pool{
 			New: func() any {
-				buf := make([]byte, maxMessageSize)
+				buf := make([]byte, size)
 				return &buf
 			},
 		},
@@ -537,12 +2289,30 @@ func (b *buffer) put(buf *[]byte) {
 
 // newInstrumentedConn initializes an instrumentedConn that on closing will
 // decrement the number of open connects and record the result.
-func newInstrumentedConn(conn net.Conn, closeFunc func(), dialerID, instance string) *instrumentedConn {
+func newInstrumentedConn(
+	conn net.Conn, closeFunc func(), dialerID, instance, instanceAddr string,
+) *instrumentedConn {
 	return &instrumentedConn{
-		Conn:      conn,
-		closeFunc: closeFunc,
-		dialerID:  dialerID,
-		instance:  instance,
+		Conn:         conn,
+		closeFunc:    closeFunc,
+		dialerID:     dialerID,
+		instance:     instance,
+		instanceAddr: instanceAddr,
+		record:       func(f func()) { go f() },
+	}
+}
+
+// startTimers starts the timers that close the connection once maxLifetime
+// has elapsed or once it has been idle for idleTimeout. A zero duration
+// disables the respective timer. startTimers must be called after the
+// connection is otherwise configured, as the timers may fire at any time.
+func (i *instrumentedConn) startTimers(maxLifetime, idleTimeout time.Duration) {
+	if maxLifetime > 0 {
+		i.lifetime = time.AfterFunc(maxLifetime, i.expire)
+	}
+	if idleTimeout > 0 {
+		i.idleTimeout = idleTimeout
+		i.idle = time.AfterFunc(idleTimeout, i.expire)
 	}
 }
 
@@ -551,16 +2321,45 @@ func newInstrumentedConn(conn net.Conn, closeFunc func(), dialerID, instance str
 type instrumentedConn struct {
 	net.Conn
 	closeFunc func()
-	dialerID  string
-	instance  string
+	// errFunc, if set, is called with any error returned by Read or Write.
+	errFunc func(error)
+	// record runs a function that records metrics.
+	record   func(func())
+	dialerID string
+	instance string
+	// instanceAddr is the host:port that was dialed to reach the instance.
+	instanceAddr string
+
+	// lifetime closes the connection when its maximum lifetime elapses. It is
+	// nil when the connection has no maximum lifetime.
+	lifetime *time.Timer
+	// idle closes the connection when no bytes have been read or written for
+	// idleTimeout. It is nil when the connection has no idle timeout.
+	idle        *time.Timer
+	idleTimeout time.Duration
+	// expired reports whether the connection was closed by lifetime or idle.
+	expired atomic.Bool
+}
+
+// RemoteInstanceAddr returns the host:port that Dial connected to, e.g., an IP
+// address or, for PSC, a DNS name followed by the server proxy port.
+func (i *instrumentedConn) RemoteInstanceAddr() string {
+	return i.instanceAddr
 }
 
 // Read delegates to the underlying net.Conn interface and records number of
 // bytes read.
 func (i *instrumentedConn) Read(b []byte) (int, error) {
 	bytesRead, err := i.Conn.Read(b)
+	if bytesRead > 0 {
+		i.resetIdle()
+	}
 	if err == nil {
-		go tel.RecordBytesReceived(context.Background(), int64(bytesRead), i.instance, i.dialerID)
+		i.record(func() {
+			tel.RecordBytesReceived(context.Background(), int64(bytesRead), i.instance, i.dialerID)
+		})
+	} else if i.errFunc != nil {
+		i.errFunc(err)
 	}
 	return bytesRead, err
 }
@@ -569,25 +2368,73 @@ func (i *instrumentedConn) Read(b []byte) (int, error) {
 // bytes written.
 func (i *instrumentedConn) Write(b []byte) (int, error) {
 	bytesWritten, err := i.Conn.Write(b)
+	if bytesWritten > 0 {
+		i.resetIdle()
+	}
 	if err == nil {
-		go tel.RecordBytesSent(context.Background(), int64(bytesWritten), i.instance, i.dialerID)
+		i.record(func() {
+			tel.RecordBytesSent(context.Background(), int64(bytesWritten), i.instance, i.dialerID)
+		})
+	} else if i.errFunc != nil {
+		i.errFunc(err)
 	}
 	return bytesWritten, err
 }
 
+// resetIdle restarts the idle timer after bytes were read or written.
+func (i *instrumentedConn) resetIdle() {
+	if i.idle != nil && !i.expired.Load() {
+		i.idle.Reset(i.idleTimeout)
+	}
+}
+
 // Close delegates to the underlying net.Conn interface and reports the close
-// to the provided closeFunc only when Close returns no error.
+// to the provided closeFunc only when Close returns no error. Closing a
+// connection that has already exceeded its maximum lifetime or idle timeout
+// is a no-op.
 func (i *instrumentedConn) Close() error {
+	if i.lifetime != nil {
+		i.lifetime.Stop()
+	}
+	if i.idle != nil {
+		i.idle.Stop()
+	}
+	if i.expired.Load() {
+		return nil
+	}
 	err := i.Conn.Close()
 	if err != nil {
 		return err
 	}
-	go i.closeFunc()
+	i.record(i.closeFunc)
 	return nil
 }
 
+// expire closes the connection once its maximum lifetime or idle timeout has
+// elapsed. Any pending or later reads and writes fail, which signals
+// connection pools to discard the connection.
+func (i *instrumentedConn) expire() {
+	if err := i.Conn.Close(); err != nil {
+		return
+	}
+	i.expired.Store(true)
+	i.record(i.closeFunc)
+}
+
+// isClosed reports whether Close has been called.
+func (d *Dialer) isClosed() bool {
+	select {
+	case <-d.closed:
+		return true
+	default:
+		return false
+	}
+}
+
 // Close closes the Dialer; it prevents the Dialer from refreshing the information
-// needed to connect.
+// needed to connect. Close also closes the Admin API clients created by the
+// Dialer, including those created per region with WithRegionalAdminEndpoint,
+// but not a client provided with WithAdminClient.
 func (d *Dialer) Close() error {
 	// Check if Close has already been called.
 	select {
@@ -602,63 +2449,131 @@ func (d *Dialer) Close() error {
 	for _, i := range d.cache {
 		i.Close()
 	}
+	for _, c := range d.ownedClients {
+		closeAdminClient(c)
+	}
+	d.regionalLock.Lock()
+	defer d.regionalLock.Unlock()
+	for _, c := range d.regionalClients {
+		closeAdminClient(c)
+	}
 	return nil
 }
 
+// connectionInfoCache returns the cache for the provided instance, creating
+// it if necessary. The returned bool reports whether the cache already
+// existed.
 func (d *Dialer) connectionInfoCache(
-	ctx context.Context, uri alloydb.InstanceURI,
-) (monitoredCache, error) {
+	ctx context.Context, uri instance.URI,
+) (monitoredCache, bool, error) {
 	d.lock.RLock()
 	c, ok := d.cache[uri]
 	d.lock.RUnlock()
-	if !ok {
-		d.lock.Lock()
-		defer d.lock.Unlock()
-		// Recheck to ensure instance wasn't created between locks
-		c, ok = d.cache[uri]
-		if !ok {
-			d.logger.Debugf(
-				ctx,
-				"[%v] Connection info added to cache",
-				uri.String(),
+	if ok {
+		return c, true, nil
+	}
+	// Get the key and the admin client before taking the write lock: waiting on
+	// the key pool or creating a regional client must not block dials to
+	// instances that are already cached.
+	var (
+		k      *rsa.PrivateKey
+		client *alloydbadmin.AlloyDBAdminClient
+	)
+	if d.cacheFactory == nil {
+		var err error
+		k, err = d.keyGenerator.rsaKey()
+		if err != nil {
+			return monitoredCache{}, false, err
+		}
+		client, err = d.adminClient(ctx, uri.Region())
+		if err != nil {
+			return monitoredCache{}, false, err
+		}
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	// Recheck to ensure instance wasn't created between locks
+	if c, ok = d.cache[uri]; ok {
+		return c, true, nil
+	}
+	d.logger.Debugf(
+		ctx,
+		"[%v] Connection info added to cache",
+		uri.String(),
+	)
+	var (
+		cache connectionInfoCache
+		open  uint64
+	)
+	if d.cacheFactory != nil {
+		fc, err := d.cacheFactory(uri)
+		if err != nil {
+			return monitoredCache{}, false, err
+		}
+		cache = &factoryCache{inst: uri, cache: fc}
+	}
+	opts := d.refreshOpts
+	if d.refreshNotifier != nil {
+		opts = append(opts[:len(opts):len(opts)],
+			alloydb.WithRefreshNotifier(func(err error) {
+				d.notifyRefresh(uri, err)
+			}),
+		)
+	}
+	switch {
+	case cache != nil:
+		// The factory created the cache.
+	case d.staticConnInfo != nil:
+		// Static connection info is never refreshed, so it takes
+		// precedence over the refresh strategy.
+		var err error
+		cache, err = alloydb.NewStaticConnectionInfoCache(
+			uri,
+			d.logger,
+			bytes.NewReader(d.staticConnInfo),
+		)
+		if err != nil {
+			return monitoredCache{}, false, err
+		}
+	case d.lazyRefresh:
+		cache = alloydb.NewLazyRefreshCache(
+			uri,
+			d.logger,
+			client, k,
+			d.refreshTimeout, d.dialerID,
+			d.disableMetadataExchange,
+			opts...,
+		)
+	default:
+		if d.maxRefreshFailures > 0 {
+			opts = append(opts[:len(opts):len(opts)],
+				alloydb.WithMaxRefreshFailures(d.maxRefreshFailures, func(err error) {
+					d.removeStopped(uri, &open, err)
+				}),
 			)
-			k, err := d.keyGenerator.rsaKey()
-			if err != nil {
-				return monitoredCache{}, err
-			}
-			var cache connectionInfoCache
-			switch {
-			case d.lazyRefresh:
-				cache = alloydb.NewLazyRefreshCache(
-					uri,
-					d.logger,
-					d.client, k,
-					d.refreshTimeout, d.dialerID,
-					d.disableMetadataExchange,
-				)
-			case d.staticConnInfo != nil:
-				var err error
-				cache, err = alloydb.NewStaticConnectionInfoCache(
-					uri,
-					d.logger,
-					d.staticConnInfo,
-				)
-				if err != nil {
-					return monitoredCache{}, err
-				}
-			default:
-				cache = alloydb.NewRefreshAheadCache(
-					uri,
-					d.logger,
-					d.client, k,
-					d.refreshTimeout, d.dialerID,
-					d.disableMetadataExchange,
-				)
-			}
-			var open uint64
-			c = monitoredCache{openConns: &open, connectionInfoCache: cache}
-			d.cache[uri] = c
 		}
+		cache = alloydb.NewRefreshAheadCache(
+			uri,
+			d.logger,
+			client, k,
+			d.refreshTimeout, d.dialerID,
+			d.disableMetadataExchange,
+			opts...,
+		)
 	}
-	return c, nil
+	c = monitoredCache{
+		openConns:           &open,
+		expiration:          new(int64),
+		connErrLimiter:      rate.NewLimiter(rate.Every(connErrRefreshInterval), 1),
+		connectionInfoCache: cache,
+	}
+	if d.tlsSessionCacheSize > 0 {
+		c.sessions = tls.NewLRUClientSessionCache(d.tlsSessionCacheSize)
+	}
+	if d.maxCachedInstances > 0 {
+		c.lastDial = new(int64)
+		d.evictLeastRecentlyDialed(ctx)
+	}
+	d.cache[uri] = c
+	return c, false, nil
 }
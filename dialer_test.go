@@ -19,25 +19,45 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
+	"cloud.google.com/go/alloydb/connectors/apiv1alpha/connectorspb"
 	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
 	"cloud.google.com/go/alloydbconn/internal/alloydb"
 	"cloud.google.com/go/alloydbconn/internal/mock"
+	"golang.org/x/net/proxy"
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/time/rate"
 	"google.golang.org/api/option"
+	"google.golang.org/protobuf/proto"
 )
 
 const testInstanceURI = "projects/my-project/locations/my-region/" +
@@ -49,6 +69,16 @@ func (stubTokenSource) Token() (*oauth2.Token, error) {
 	return &oauth2.Token{}, nil
 }
 
+// testRSAKey is shared by the tests' dialers as generating a 2048-bit key for
+// each dialer makes the suite (and especially -race runs) slow.
+var testRSAKey = func() *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}()
+
 func TestDialerIncompatibleOptions(t *testing.T) {
 	tcs := []struct {
 		desc string
@@ -58,6 +88,112 @@ func TestDialerIncompatibleOptions(t *testing.T) {
 			desc: "opt out connection check doesn't work with IAM authn",
 			opts: []Option{WithOptOutOfAdvancedConnectionCheck(), WithIAMAuthN()},
 		},
+		{
+			desc: "CA fingerprint must be a SHA-256 hash",
+			opts: []Option{WithExpectedCACertFingerprint("AB:CD")},
+		},
+		{
+			desc: "buffer size too small",
+			opts: []Option{WithMetadataExchangeBufferSize(1)},
+		},
+		{
+			desc: "buffer size too large",
+			opts: []Option{WithMetadataExchangeBufferSize(1 << 20)},
+		},
+		{
+			desc: "metadata exchange timeout must be positive",
+			opts: []Option{WithMetadataExchangeTimeout(0)},
+		},
+		{
+			desc: "TLS session cache capacity must be positive",
+			opts: []Option{WithTLSSessionCache(0)},
+		},
+		{
+			desc: "max refresh failures must be positive",
+			opts: []Option{WithMaxRefreshFailures(0)},
+		},
+		{
+			desc: "max cached instances must be positive",
+			opts: []Option{WithMaxCachedInstances(0)},
+		},
+		{
+			desc: "negative cache TTL must not be negative",
+			opts: []Option{WithNegativeCacheTTL(-time.Minute)},
+		},
+		{
+			desc: "unsupported proxy scheme",
+			opts: []Option{WithProxy("ftp://localhost:1080")},
+		},
+		{
+			desc: "proxy URL without host",
+			opts: []Option{WithProxy("socks5://")},
+		},
+		{
+			desc: "conflicting default IP types",
+			opts: []Option{WithDefaultDialOptions(WithPublicIP(), WithPSC())},
+		},
+		{
+			desc: "refresh ahead window must be positive",
+			opts: []Option{WithRefreshAheadWindow(0)},
+		},
+		{
+			desc: "refresh ahead window must be less than the cert lifetime",
+			opts: []Option{WithRefreshAheadWindow(time.Hour)},
+		},
+		{
+			desc: "refresh ahead window must be less than a custom cert lifetime",
+			opts: []Option{
+				WithRefreshAheadWindow(12 * time.Hour),
+				WithCertDuration(12 * time.Hour),
+			},
+		},
+		{
+			desc: "cert duration must be at least one hour",
+			opts: []Option{WithCertDuration(time.Minute)},
+		},
+		{
+			desc: "cert duration must be at most 24 hours",
+			opts: []Option{WithCertDuration(25 * time.Hour)},
+		},
+		{
+			desc: "auth type must be supported",
+			opts: []Option{WithAuthType(AuthType(-1))},
+		},
+		{
+			desc: "opt out connection check doesn't work with an IAM auth type",
+			opts: []Option{WithOptOutOfAdvancedConnectionCheck(), WithAuthType(AuthTypeAutoIAM)},
+		},
+		{
+			desc: "dialer ID must not be empty",
+			opts: []Option{WithDialerID("")},
+		},
+		{
+			desc: "dialer ID must not be too long",
+			opts: []Option{WithDialerID(strings.Repeat("a", 256))},
+		},
+		{
+			desc: "dialer ID must be printable ASCII",
+			opts: []Option{WithDialerID("dialer\n")},
+		},
+		{
+			desc: "key pool size must be positive",
+			opts: []Option{WithKeyPoolSize(0)},
+		},
+		{
+			desc: "key pool doesn't work with a custom RSA key",
+			opts: []Option{WithKeyPoolSize(2), WithRSAKey(&rsa.PrivateKey{})},
+		},
+		{
+			desc: "regional endpoint doesn't work with a custom admin client",
+			opts: []Option{
+				WithRegionalAdminEndpoint(),
+				WithAdminClient(&alloydbadmin.AlloyDBAdminClient{}),
+			},
+		},
+		{
+			desc: "TCP linger must not be negative",
+			opts: []Option{WithDefaultDialOptions(WithTCPLinger(-1))},
+		},
 	}
 
 	for _, tc := range tcs {
@@ -70,6 +206,129 @@ func TestDialerIncompatibleOptions(t *testing.T) {
 	}
 }
 
+func TestNewDialerIgnoresNilOptions(t *testing.T) {
+	var nilOpt Option
+	d, err := NewDialer(context.Background(),
+		nilOpt,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithOptions(nil, WithLazyRefresh()),
+		WithDefaultDialOptions(nil, DialOptions(nil)),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	if !d.lazyRefresh {
+		t.Fatal("want options after a nil option to be applied")
+	}
+}
+
+func TestDialerDialIgnoresNilDialOptions(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	var nilOpt DialOption
+	ctx = ContextWithDialOptions(ctx, nilOpt)
+	conn, err := d.Dial(ctx, testInstanceURI, nilOpt, DialOptions(nilOpt))
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+}
+
+func TestDialerWithInstanceValidator(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// Only the allowed instance's connection info is requested.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithInstanceValidator(func(uri instance.URI) error {
+			if uri.Project() != "my-project" {
+				return fmt.Errorf("project %v is not allowed", uri.Project())
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	const disallowed = "projects/other-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+	_, err = d.Dial(ctx, disallowed)
+	var cfgErr *errtype.ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("want = %T, got = %v", cfgErr, err)
+	}
+	if _, err := d.ConnectionInfo(ctx, disallowed); !errors.As(err, &cfgErr) {
+		t.Fatalf("want = %T, got = %v", cfgErr, err)
+	}
+	d.lock.RLock()
+	n := len(d.cache)
+	d.lock.RUnlock()
+	if n != 0 {
+		t.Fatalf("want no cached instances after rejected dials, got = %v", n)
+	}
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+}
+
 func TestDialerCanConnectToInstance(t *testing.T) {
 	ctx := context.Background()
 	inst := mock.NewFakeInstance(
@@ -92,7 +351,7 @@ func TestDialerCanConnectToInstance(t *testing.T) {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
 
-	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
 	if err != nil {
 		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
 	}
@@ -120,10 +379,7 @@ func TestDialerCanConnectToInstance(t *testing.T) {
 
 func writeStaticInfo(t *testing.T, i mock.FakeAlloyDBInstance) io.Reader {
 	t.Helper()
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		t.Fatal(err)
-	}
+	key := testRSAKey
 
 	pub := x509.MarshalPKCS1PublicKey(&key.PublicKey)
 	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: pub})
@@ -172,6 +428,7 @@ func TestDialerWorksWithStaticConnectionInfo(t *testing.T) {
 	d, err := NewDialer(
 		ctx,
 		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
 		WithStaticConnectionInfo(staticPath),
 	)
 	if err != nil {
@@ -193,307 +450,4950 @@ func TestDialerWorksWithStaticConnectionInfo(t *testing.T) {
 	}
 }
 
-func TestDialWithAdminAPIErrors(t *testing.T) {
+func TestDialerStaticConnectionInfoWithRefreshOptions(t *testing.T) {
 	ctx := context.Background()
-	mc, url, cleanup := mock.HTTPClient()
-	defer func() {
-		if err := cleanup(); err != nil {
-			t.Fatalf("%v", err)
-		}
-	}()
-	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
-	if err != nil {
-		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
-	}
-	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
-	if err != nil {
-		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
-	}
-	d.client = c
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
 
-	_, err = d.Dial(ctx, "bad-instance-name")
-	var wantErr1 *errtype.ConfigError
-	if !errors.As(err, &wantErr1) {
-		t.Fatalf("when instance name is invalid, want = %T, got = %v", wantErr1, err)
+	files := staticInfoFiles(t, inst)
+	fsys := mapFS(t, map[string]map[string]interface{}{
+		"static/my-instance.json": files[0],
+	})
+	tcs := []struct {
+		desc string
+		// opts returns the options, as the static info reader can only be
+		// read once.
+		opts func() []Option
+	}{
+		{
+			desc: "static info with background refresh disabled",
+			opts: func() []Option {
+				return []Option{
+					WithStaticConnectionInfo(writeStaticInfo(t, inst)),
+					WithBackgroundRefreshDisabled(),
+				}
+			},
+		},
+		{
+			desc: "static info with lazy refresh",
+			opts: func() []Option {
+				return []Option{
+					WithStaticConnectionInfo(writeStaticInfo(t, inst)),
+					WithLazyRefresh(),
+				}
+			},
+		},
+		{
+			desc: "static info FS with background refresh disabled",
+			opts: func() []Option {
+				return []Option{
+					WithStaticConnectionInfoFS(fsys, "static/*.json"),
+					WithBackgroundRefreshDisabled(),
+				}
+			},
+		},
 	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			// The static connection info is used, so the Admin API is never
+			// called.
+			mc, url, cleanup := mock.HTTPClient()
+			defer func() {
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+			)
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			opts := append([]Option{WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey)}, tc.opts()...)
+			d, err := NewDialer(ctx, opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
 
-	// Refresh will fail because no API responses have been configured above.
-	_, err = d.Dial(context.Background(), testInstanceURI)
-	var wantErr2 *errtype.RefreshError
-	if !errors.As(err, &wantErr2) {
-		t.Fatalf("when API call fails, want = %T, got = %v", wantErr2, err)
+			conn, err := d.Dial(ctx, testInstanceURI)
+			if err != nil {
+				t.Fatalf("expected Dial to succeed, but got error: %v", err)
+			}
+			defer conn.Close()
+			data, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatalf("expected ReadAll to succeed, got error %v", err)
+			}
+			if string(data) != "my-instance" {
+				t.Fatalf("expected known response from the server, but got %v", string(data))
+			}
+		})
 	}
 }
 
-func TestDialWithUnavailableServerErrors(t *testing.T) {
-	ctx := context.Background()
-	inst := mock.NewFakeInstance(
-		"my-project", "my-region", "my-cluster", "my-instance",
-	)
-	// Don't use the cleanup function. Because this test is about error
-	// cases, API requests (started in two separate goroutines) will
-	// sometimes succeed and clear the mock, and sometimes not.
-	// This test is about error return values from Dial, not API interaction.
-	mc, url, _ := mock.HTTPClient(
-		mock.InstanceGetSuccess(inst, 2),
-		mock.CreateEphemeralSuccess(inst, 2),
-	)
-	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+// staticInfoFiles returns static connection info files for the provided
+// instances that share a key pair. Only the first file holds the key pair.
+func staticInfoFiles(t *testing.T, insts ...mock.FakeAlloyDBInstance) []map[string]interface{} {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
-		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+		t.Fatal(err)
 	}
-
-	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
-	if err != nil {
-		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	pubPEM := pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey),
+	})
+	privPEM := pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	var files []map[string]interface{}
+	for n, i := range insts {
+		chain, err := i.GeneratePEMCertificateChain(&key.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := map[string]interface{}{
+			i.String(): map[string]interface{}{
+				"ipAddress":           fmt.Sprintf("127.0.0.%d", n+1),
+				"pemCertificateChain": chain,
+				"caCert":              chain[len(chain)-1],
+			},
+		}
+		if n == 0 {
+			f["publicKey"] = string(pubPEM)
+			f["privateKey"] = string(privPEM)
+		}
+		files = append(files, f)
 	}
-	d.client = c
+	return files
+}
 
-	_, err = d.Dial(ctx, testInstanceURI)
-	var wantErr2 *errtype.DialError
-	if !errors.As(err, &wantErr2) {
-		t.Fatalf("when server proxy socket is unavailable, want = %T, got = %v", wantErr2, err)
+func mapFS(t *testing.T, files map[string]map[string]interface{}) fstest.MapFS {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, f := range files {
+		data, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys[name] = &fstest.MapFile{Data: data}
 	}
+	return fsys
 }
 
-func TestDialerWithCustomDialFunc(t *testing.T) {
+func TestDialerWithStaticConnectionInfoFS(t *testing.T) {
 	ctx := context.Background()
 	inst := mock.NewFakeInstance(
 		"my-project", "my-region", "my-cluster", "my-instance",
 	)
-	mc, url, cleanup := mock.HTTPClient(
-		mock.InstanceGetSuccess(inst, 1),
-		mock.CreateEphemeralSuccess(inst, 1),
+	other := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "other-instance",
 	)
 	stop := mock.StartServerProxy(t, inst)
-	defer func() {
-		stop()
-		if err := cleanup(); err != nil {
-			t.Fatalf("%v", err)
-		}
-	}()
-	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
-	if err != nil {
-		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	t.Cleanup(stop)
+
+	files := staticInfoFiles(t, inst, other)
+	fsys := mapFS(t, map[string]map[string]interface{}{
+		"static/my-instance.json":    files[0],
+		"static/other-instance.json": files[1],
+		"static/README.md":           {},
+	})
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithStaticConnectionInfoFS(fsys, "static/*.json"),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+
+	// The second instance is read from the same merged static connection
+	// info.
+	ci, err := d.ConnectionInfo(ctx, other.String())
+	if err != nil {
+		t.Fatalf("expected ConnectionInfo to succeed, but got error: %v", err)
+	}
+	if got, want := ci.IPAddrs[alloydb.PrivateIP], "127.0.0.2"; got != want {
+		t.Fatalf("want private IP = %v, got = %v", want, got)
+	}
+}
+
+func TestDialerWithStaticConnectionInfoFSErrors(t *testing.T) {
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	other := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "other-instance",
+	)
+	files := staticInfoFiles(t, inst, other)
+	otherKeys := staticInfoFiles(t, other)[0]
+	tcs := []struct {
+		desc  string
+		files map[string]map[string]interface{}
+	}{
+		{
+			desc:  "no matching files",
+			files: map[string]map[string]interface{}{"static/a.txt": files[0]},
+		},
+		{
+			desc: "duplicate instance",
+			files: map[string]map[string]interface{}{
+				"static/a.json": files[0],
+				"static/b.json": {inst.String(): files[0][inst.String()]},
+			},
+		},
+		{
+			desc: "conflicting key pairs",
+			files: map[string]map[string]interface{}{
+				"static/a.json": files[0],
+				"static/b.json": otherKeys,
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewDialer(context.Background(),
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				WithStaticConnectionInfoFS(mapFS(t, tc.files), "static/*.json"),
+			)
+			var wantErr *errtype.ConfigError
+			if !errors.As(err, &wantErr) {
+				t.Fatalf("want = %T, got = %v", wantErr, err)
+			}
+		})
+	}
+}
+
+func oneOffConnectionInfoFor(
+	t *testing.T, i mock.FakeAlloyDBInstance, expiration time.Time,
+) ConnectionInfo {
+	t.Helper()
+	key := testRSAKey
+	chain, err := i.GeneratePEMCertificateChain(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	cert, err := tls.X509KeyPair([]byte(strings.Join(chain, "")), keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	// CA cert is last in chain
+	if !pool.AppendCertsFromPEM([]byte(chain[len(chain)-1])) {
+		t.Fatal("failed to add CA cert to pool")
+	}
+	return ConnectionInfo{
+		Instance:   i.String(),
+		IPAddrs:    map[string]string{"PRIVATE": "127.0.0.1"},
+		Expiration: expiration,
+		ClientCert: cert,
+		RootCAs:    pool,
+	}
+}
+
+func TestDialerWithOneOffConnectionInfo(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// The mock fails the test if it receives any requests.
+	mc, url, cleanup := mock.HTTPClient()
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	ci := oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour))
+	conn, err := d.Dial(ctx, testInstanceURI, WithOneOffConnectionInfo(ci))
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+	if _, err := d.RefreshStatus(testInstanceURI); err == nil {
+		t.Fatal("want one-off connection info to not be cached, got nil error")
+	}
+
+	expired := oneOffConnectionInfoFor(t, inst, time.Now().Add(-time.Hour))
+	_, err = d.Dial(ctx, testInstanceURI, WithOneOffConnectionInfo(expired))
+	var cfgErr *errtype.ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("when connection info has expired, want = %T, got = %v", cfgErr, err)
+	}
+}
+
+func TestDialerSecure(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	// The raw connection is an in-memory pipe whose other end is relayed to
+	// the server proxy.
+	rawConn, tunnel := net.Pipe()
+	go func() {
+		pipe(tunnel, "127.0.0.1:5433")
+		tunnel.Close()
+	}()
+	conn, err := d.Secure(ctx, testInstanceURI, rawConn)
+	if err != nil {
+		t.Fatalf("expected Secure to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+}
+
+func TestDialerSecureClosesRawConnOnError(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	rawConn, other := net.Pipe()
+	defer other.Close()
+	_, err = d.Secure(ctx, "bad-instance-name", rawConn)
+	var cfgErr *errtype.ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("when instance name is invalid, want = %T, got = %v", cfgErr, err)
+	}
+	if _, err := rawConn.Write([]byte("a")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("want raw conn to be closed, got write error = %v", err)
+	}
+}
+
+func TestDialerWithAdminClient(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// Each dialer refreshes the connection info once.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	defer c.Close()
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	dial := func(d *Dialer) {
+		t.Helper()
+		conn, err := d.Dial(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		defer conn.Close()
+		data, err := io.ReadAll(conn)
+		if err != nil {
+			t.Fatalf("expected ReadAll to succeed, got error %v", err)
+		}
+		if string(data) != "my-instance" {
+			t.Fatalf("expected known response from the server, but got %v", string(data))
+		}
+	}
+
+	d1, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithAdminClient(c),
+		WithLazyRefresh(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d2, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithAdminClient(c),
+		WithLazyRefresh(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d2.Close()
+
+	dial(d1)
+	// Closing a dialer leaves the shared client usable by other dialers.
+	if err := d1.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, but got error: %v", err)
+	}
+	dial(d2)
+}
+
+func TestDialerWithMetadataExchangeTimeout(t *testing.T) {
+	d, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithMetadataExchangeTimeout(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	// The server reads the request but never responds.
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+
+	start := time.Now()
+	err = d.metadataExchange(client, instance.URI{}, stubTokenSource{})
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("want = %v, got = %v", os.ErrDeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("want metadata exchange to time out quickly, took %v", elapsed)
+	}
+}
+
+// readMetadataExchangeRequest reads a metadata exchange request from conn and
+// responds with OK.
+func readMetadataExchangeRequest(conn net.Conn) (*connectorspb.MetadataExchangeRequest, error) {
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(conn, size); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint32(size))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	req := &connectorspb.MetadataExchangeRequest{}
+	if err := proto.Unmarshal(buf, req); err != nil {
+		return nil, err
+	}
+	resp, err := proto.Marshal(&connectorspb.MetadataExchangeResponse{
+		ResponseCode: connectorspb.MetadataExchangeResponse_OK,
+	})
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(size, uint32(len(resp)))
+	if _, err := conn.Write(append(size, resp...)); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func TestDialerWithAuthType(t *testing.T) {
+	tcs := []struct {
+		desc string
+		opts []Option
+		want connectorspb.MetadataExchangeRequest_AuthType
+	}{
+		{
+			desc: "default",
+			want: connectorspb.MetadataExchangeRequest_DB_NATIVE,
+		},
+		{
+			desc: "DB native",
+			opts: []Option{WithAuthType(AuthTypeDBNative)},
+			want: connectorspb.MetadataExchangeRequest_DB_NATIVE,
+		},
+		{
+			desc: "auto IAM",
+			opts: []Option{WithAuthType(AuthTypeAutoIAM)},
+			want: connectorspb.MetadataExchangeRequest_AUTO_IAM,
+		},
+		{
+			desc: "IAM authn",
+			opts: []Option{WithIAMAuthN()},
+			want: connectorspb.MetadataExchangeRequest_AUTO_IAM,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := append([]Option{WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey)}, tc.opts...)
+			d, err := NewDialer(context.Background(), opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			reqs := make(chan *connectorspb.MetadataExchangeRequest, 1)
+			go func() {
+				req, err := readMetadataExchangeRequest(server)
+				if err != nil {
+					t.Errorf("failed to read metadata exchange request: %v", err)
+				}
+				reqs <- req
+			}()
+
+			err = d.metadataExchange(client, instance.URI{}, stubTokenSource{})
+			if err != nil {
+				t.Fatalf("expected metadata exchange to succeed, but got error: %v", err)
+			}
+			if got := (<-reqs).GetAuthType(); got != tc.want {
+				t.Fatalf("auth type mismatch, want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDialerWithDialCredentials(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithOAuth2Token("delegated-token"),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	ci := oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour))
+
+	// The Dialer's token is rejected by the instance.
+	_, err = d.Dial(ctx, testInstanceURI, WithOneOffConnectionInfo(ci))
+	var mdxErr *errtype.MetadataExchangeError
+	if !errors.As(err, &mdxErr) {
+		t.Fatalf("want = %T, got = %v", mdxErr, err)
+	}
+
+	creds := &google.Credentials{
+		TokenSource: oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: "delegated-token"},
+		),
+	}
+	conn, err := d.Dial(ctx, testInstanceURI,
+		WithOneOffConnectionInfo(ci),
+		WithDialCredentials(creds),
+	)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+
+	_, err = d.Dial(ctx, testInstanceURI,
+		WithOneOffConnectionInfo(ci),
+		WithDialCredentials(&google.Credentials{}),
+	)
+	var cfgErr *errtype.ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("want = %T for credentials without a token source, got = %v", cfgErr, err)
+	}
+}
+
+func TestDialerWithServerNameOverride(t *testing.T) {
+	ctx := context.Background()
+	// The server's certificate is valid for 127.0.0.1 and the PSC DNS name.
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithPSC("proxy.example.com"),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	ci := oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour))
+
+	conn, err := d.Dial(ctx, testInstanceURI,
+		WithOneOffConnectionInfo(ci),
+		WithServerNameOverride("proxy.example.com"),
+	)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+
+	// The server's certificate is not valid for the overridden name, even
+	// though it is valid for the address dialed.
+	_, err = d.Dial(ctx, testInstanceURI,
+		WithOneOffConnectionInfo(ci),
+		WithServerNameOverride("other.example.com"),
+	)
+	var dialErr *errtype.DialError
+	if !errors.As(err, &dialErr) {
+		t.Fatalf("want = %T, got = %v", dialErr, err)
+	}
+}
+
+func TestDialerWarmupAll(t *testing.T) {
+	ctx := context.Background()
+	var (
+		uris []string
+		reqs []*mock.Request
+	)
+	for i := 0; i < 3; i++ {
+		inst := mock.NewFakeInstance(
+			"my-project", "my-region", "my-cluster", fmt.Sprintf("my-instance-%d", i),
+		)
+		uris = append(uris, inst.String())
+		reqs = append(reqs,
+			mock.InstanceGetSuccess(inst, 1),
+			mock.CreateEphemeralSuccess(inst, 1),
+		)
+	}
+	mc, url, cleanup := mock.HTTPClient(reqs...)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	const badURI = "bad-uri"
+	errs := d.WarmupAll(ctx, append(uris, uris[0], badURI))
+
+	if got, want := len(errs), len(uris)+1; got != want {
+		t.Fatalf("want %v results, got = %v", want, errs)
+	}
+	for _, uri := range uris {
+		if err := errs[uri]; err != nil {
+			t.Fatalf("%v: expected warmup to succeed, but got error: %v", uri, err)
+		}
+		s, err := d.RefreshStatus(uri)
+		if err != nil {
+			t.Fatalf("%v: expected RefreshStatus to succeed, but got error: %v", uri, err)
+		}
+		if s.Expiration.IsZero() {
+			t.Fatalf("%v: want cached connection info, got = %+v", uri, s)
+		}
+	}
+	var cfgErr *errtype.ConfigError
+	if !errors.As(errs[badURI], &cfgErr) {
+		t.Fatalf("%v: want = %T, got = %v", badURI, cfgErr, errs[badURI])
+	}
+}
+
+func TestDialerPing(t *testing.T) {
+	tcs := []struct {
+		desc    string
+		opts    []mock.Option
+		wantErr bool
+	}{
+		{
+			desc: "reachable instance",
+		},
+		{
+			desc:    "instance rejects the metadata exchange",
+			opts:    []mock.Option{mock.WithMetadataExchangeError("permission denied")},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance", tc.opts...,
+			)
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			stop := mock.StartServerProxy(t, inst)
+			defer func() {
+				stop()
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+			)
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			err = d.Ping(ctx, testInstanceURI)
+			if tc.wantErr {
+				var mdxErr *errtype.MetadataExchangeError
+				if !errors.As(err, &mdxErr) {
+					t.Fatalf("want = %T, got = %v", mdxErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("expected Ping to succeed, but got error: %v", err)
+			}
+			if got := d.Stats().OpenConnections; got != 0 {
+				t.Fatalf("want no open connections after Ping, got = %v", got)
+			}
+		})
+	}
+}
+
+func TestDialerMetadataExchangeError(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithMetadataExchangeError("permission denied"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	_, err = d.Dial(ctx, testInstanceURI)
+	var mdxErr *errtype.MetadataExchangeError
+	if !errors.As(err, &mdxErr) {
+		t.Fatalf("want = %T, got = %v", mdxErr, err)
+	}
+	if mdxErr.ResponseCode != "ERROR" {
+		t.Fatalf("want response code = ERROR, got = %v", mdxErr.ResponseCode)
+	}
+	if mdxErr.ServerMessage != "permission denied" {
+		t.Fatalf(
+			"want server message = %q, got = %q",
+			"permission denied", mdxErr.ServerMessage,
+		)
+	}
+}
+
+func TestDialWithAdminAPIErrors(t *testing.T) {
+	ctx := context.Background()
+	mc, url, cleanup := mock.HTTPClient()
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	_, err = d.Dial(ctx, "bad-instance-name")
+	var wantErr1 *errtype.ConfigError
+	if !errors.As(err, &wantErr1) {
+		t.Fatalf("when instance name is invalid, want = %T, got = %v", wantErr1, err)
+	}
+
+	// Refresh will fail because no API responses have been configured above.
+	_, err = d.Dial(context.Background(), testInstanceURI)
+	var wantErr2 *errtype.RefreshError
+	if !errors.As(err, &wantErr2) {
+		t.Fatalf("when API call fails, want = %T, got = %v", wantErr2, err)
+	}
+}
+
+func TestDialWithUnavailableServerErrors(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// Don't use the cleanup function. Because this test is about error
+	// cases, API requests (started in two separate goroutines) will
+	// sometimes succeed and clear the mock, and sometimes not.
+	// This test is about error return values from Dial, not API interaction.
+	mc, url, _ := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	_, err = d.Dial(ctx, testInstanceURI)
+	var wantErr2 *errtype.DialError
+	if !errors.As(err, &wantErr2) {
+		t.Fatalf("when server proxy socket is unavailable, want = %T, got = %v", wantErr2, err)
+	}
+}
+
+func TestDialerWithCustomDialFunc(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx,
+		WithDialFunc(func(_ context.Context, _, _ string) (net.Conn, error) {
+			return nil, errors.New("sentinel error")
+		}),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	_, err = d.Dial(ctx, testInstanceURI)
+	if !strings.Contains(err.Error(), "sentinel error") {
+		t.Fatalf("want = sentinel error, got = %v", err)
+	}
+}
+
+func TestDialerUserAgent(t *testing.T) {
+	data, err := os.ReadFile("version.txt")
+	if err != nil {
+		t.Fatalf("failed to read version.txt: %v", err)
+	}
+	ver := strings.TrimSpace(string(data))
+	want := "alloydb-go-connector/" + ver
+	if want != userAgent {
+		t.Errorf("embed version mismatched: want %q, got %q", want, userAgent)
+	}
+}
+
+func TestDialerRemovesInvalidInstancesFromCache(t *testing.T) {
+	// When a dialer attempts to retrieve connection info for a
+	// non-existent instance, it should delete the instance from
+	// the cache and ensure no background refresh happens (which would be
+	// wasted cycles).
+	d, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithRefreshTimeout(time.Second),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer func(d *Dialer) {
+		err := d.Close()
+		if err != nil {
+			t.Log(err)
+		}
+	}(d)
+
+	// Populate instance map with connection info cache that will always fail.
+	// This allows the test to verify the error case path invoking close.
+	badInstanceName := "projects/bad/locations/bad/clusters/bad/instances/bad"
+	tcs := []struct {
+		desc string
+		uri  string
+		resp connectionInfoResp
+		opts []DialOption
+	}{
+		{
+			desc: "dialing a bad instance URI",
+			uri:  badInstanceName,
+			resp: connectionInfoResp{
+				err: errors.New("connect info failed"),
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Manually populate the internal cache with a spy
+			inst, _ := instance.ParseURI(tc.uri)
+			spy := &spyConnectionInfoCache{
+				connectInfoCalls: []connectionInfoResp{tc.resp},
+			}
+			d.cache[inst] = monitoredCache{
+				connectionInfoCache: spy,
+			}
+
+			_, err = d.Dial(context.Background(), tc.uri, tc.opts...)
+			if err == nil {
+				t.Fatal("expected Dial to return error")
+			}
+			// Verify that the connection info cache was closed (to prevent
+			// further failed refresh operations)
+			if got, want := spy.CloseWasCalled(), true; got != want {
+				t.Fatal("Close was not called")
+			}
+
+			// Now verify that bad connection name has been deleted from map.
+			d.lock.RLock()
+			_, ok := d.cache[inst]
+			d.lock.RUnlock()
+			if ok {
+				t.Fatal("connection info was not removed from cache")
+			}
+		})
+	}
+
+}
+
+func TestDialerKeepsCacheForMissingIPType(t *testing.T) {
+	d, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	info := alloydb.ConnectionInfo{
+		IPAddrs: map[string]string{
+			// no public IP
+			alloydb.PrivateIP: "10.0.0.1",
+		},
+		Expiration: time.Now().Add(time.Hour),
+	}
+	inst, _ := instance.ParseURI(testInstanceURI)
+	spy := &spyConnectionInfoCache{
+		connectInfoCalls: []connectionInfoResp{{info: info}, {info: info}},
+	}
+	d.cache[inst] = monitoredCache{
+		openConns:           new(uint64),
+		connectionInfoCache: spy,
+	}
+
+	var errs []string
+	for i := 0; i < 2; i++ {
+		_, err := d.Dial(context.Background(), testInstanceURI, WithPublicIP())
+		var cfgErr *errtype.ConfigError
+		if !errors.As(err, &cfgErr) {
+			t.Fatalf("want = %T, got = %v", cfgErr, err)
+		}
+		errs = append(errs, err.Error())
+	}
+	if errs[0] != errs[1] {
+		t.Fatalf("want the same error for each dial, got = %q and %q", errs[0], errs[1])
+	}
+	if spy.CloseWasCalled() {
+		t.Fatal("want connection info cache to stay open")
+	}
+	d.lock.RLock()
+	c, ok := d.cache[inst]
+	d.lock.RUnlock()
+	if !ok || c.connectionInfoCache != spy {
+		t.Fatal("want connection info to stay cached")
+	}
+}
+
+func TestDialRefreshesExpiredCertificates(t *testing.T) {
+	d, err := NewDialer(
+		context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+
+	sentinel := errors.New("connect info failed")
+	inst := testInstanceURI
+	cn, _ := instance.ParseURI(inst)
+	spy := &spyConnectionInfoCache{
+		connectInfoCalls: []connectionInfoResp{
+			// First call returns expired certificate
+			{
+				info: alloydb.ConnectionInfo{
+					Expiration: time.Now().Add(-10 * time.Hour),
+				},
+			},
+			// Second call errors to validate error path
+			{
+				err: sentinel,
+			},
+		},
+	}
+	d.cache[cn] = monitoredCache{
+		connectionInfoCache: spy,
+	}
+
+	_, err = d.Dial(context.Background(), inst)
+	if !errors.Is(err, sentinel) {
+		t.Fatalf("expected Dial to return sentinel error, instead got = %v", err)
+	}
+
+	// Verify that the cache was refreshed
+	if got, want := spy.ForceRefreshWasCalled(), true; got != want {
+		t.Fatal("ForceRefresh was not called")
+	}
+
+	// Verify that the connection info cache was closed (to prevent
+	// further failed refresh operations)
+	if got, want := spy.CloseWasCalled(), true; got != want {
+		t.Fatal("Close was not called")
+	}
+
+	// Now verify that bad connection name has been deleted from map.
+	d.lock.RLock()
+	_, ok := d.cache[cn]
+	d.lock.RUnlock()
+	if ok {
+		t.Fatal("bad instance was not removed from the cache")
+	}
+}
+
+type connectionInfoResp struct {
+	info alloydb.ConnectionInfo
+	err  error
+}
+
+type spyConnectionInfoCache struct {
+	mu                    sync.Mutex
+	connectInfoIndex      int
+	connectInfoCalls      []connectionInfoResp
+	closed                bool
+	forceRefreshWasCalled bool
+	forceRefreshCalls     int
+	// embed interface to avoid having to implement irrelevant methods
+	connectionInfoCache
+}
+
+func (s *spyConnectionInfoCache) ConnectionInfo(context.Context) (alloydb.ConnectionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := s.connectInfoCalls[s.connectInfoIndex]
+	s.connectInfoIndex++
+	return res.info, res.err
+}
+
+func (s *spyConnectionInfoCache) ForceRefresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forceRefreshWasCalled = true
+	s.forceRefreshCalls++
+}
+
+func (s *spyConnectionInfoCache) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *spyConnectionInfoCache) CloseWasCalled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *spyConnectionInfoCache) ForceRefreshWasCalled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.forceRefreshWasCalled
+}
+
+func (s *spyConnectionInfoCache) ForceRefreshCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.forceRefreshCalls
+}
+
+func TestDialerRefreshesWhenServerRejectsCertAfterConnect(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		// Skip the metadata exchange so that Dial returns once the client
+		// completes the TLS handshake, before the server verifies the client
+		// certificate.
+		WithOptOutOfAdvancedConnectionCheck(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	cn, _ := instance.ParseURI(testInstanceURI)
+	ci := oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour))
+	// The server does not trust a self-signed client certificate, as after a
+	// CA rotation on the instance.
+	ci.ClientCert = selfSignedCert(t)
+	spy := &spyConnectionInfoCache{
+		connectInfoCalls: []connectionInfoResp{{info: oneOffConnectionInfo(cn, ci)}},
+	}
+	d.cache[cn] = monitoredCache{
+		openConns:           new(uint64),
+		connErrLimiter:      rate.NewLimiter(rate.Every(time.Hour), 1),
+		connectionInfoCache: spy,
+	}
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	if _, err := io.ReadAll(conn); err == nil {
+		t.Fatal("want read to fail when the server rejects the client certificate")
+	}
+	if !spy.ForceRefreshWasCalled() {
+		t.Fatal("ForceRefresh was not called")
+	}
+}
+
+func TestDialerWithAdditionalRootCAs(t *testing.T) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "customer-managed CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// serverCert returns a certificate issued by the extra CA for the server
+	// with the provided common name and IP address or DNS name.
+	serverCert := func(cn, ip, dnsName string) tls.Certificate {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		if ip != "" {
+			tmpl.IPAddresses = []net.IP{net.ParseIP(ip)}
+		}
+		if dnsName != "" {
+			tmpl.DNSNames = []string{dnsName}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	extra := x509.NewCertPool()
+	extra.AddCert(ca)
+
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	ci := oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour))
+
+	const serverName = "00000000-0000-0000-0000-000000000000.server.alloydb"
+	tcs := []struct {
+		desc       string
+		opts       []Option
+		serverCert tls.Certificate
+		wantErr    bool
+	}{
+		{
+			desc:       "extra root is not trusted by default",
+			serverCert: serverCert(serverName, "127.0.0.1", ""),
+			wantErr:    true,
+		},
+		{
+			desc:       "extra root is trusted with the option",
+			opts:       []Option{WithAdditionalRootCAs(extra)},
+			serverCert: serverCert(serverName, "127.0.0.1", ""),
+		},
+		{
+			desc:       "server address is still verified",
+			opts:       []Option{WithAdditionalRootCAs(extra)},
+			serverCert: serverCert(serverName, "10.0.0.1", ""),
+			wantErr:    true,
+		},
+		{
+			desc: "certificate for a different instance is rejected",
+			opts: []Option{WithAdditionalRootCAs(extra)},
+			serverCert: serverCert(
+				"11111111-1111-1111-1111-111111111111.server.alloydb",
+				"", "other-instance.psc.alloydb.goog",
+			),
+			wantErr: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			opts := append([]Option{
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				WithOptOutOfAdvancedConnectionCheck(),
+			}, tc.opts...)
+			d, err := NewDialer(ctx, opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+
+			cert := tc.serverCert
+			dial := func(context.Context, string, string) (net.Conn, error) {
+				client, server := net.Pipe()
+				go func() {
+					defer server.Close()
+					s := tls.Server(server, &tls.Config{
+						Certificates: []tls.Certificate{cert},
+						MinVersion:   tls.VersionTLS13,
+					})
+					if err := s.Handshake(); err != nil {
+						return
+					}
+					_, _ = io.Copy(io.Discard, s)
+				}()
+				return client, nil
+			}
+			conn, err := d.Dial(ctx, testInstanceURI,
+				WithOneOffConnectionInfo(ci), WithOneOffDialFunc(dial),
+			)
+			if tc.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatal("want Dial to fail, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected Dial to succeed, but got error: %v", err)
+			}
+			conn.Close()
+		})
+	}
+}
+
+func TestDialerDialWithResult(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	for _, wantHit := range []bool{false, true} {
+		conn, res, err := d.DialWithResult(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected DialWithResult to succeed, but got error: %v", err)
+		}
+		conn.Close()
+		want := DialResult{
+			IPType:   "PRIVATE",
+			Addr:     "127.0.0.1:5433",
+			CacheHit: wantHit,
+			Latency:  res.Latency,
+		}
+		if res != want {
+			t.Fatalf("want = %+v, got = %+v", want, res)
+		}
+		if res.Latency <= 0 {
+			t.Fatalf("want positive latency, got = %v", res.Latency)
+		}
+	}
+}
+
+func TestDialerDialWithResultForcedRefresh(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	cn, _ := instance.ParseURI(testInstanceURI)
+	// The cached certificate has expired, which forces a refresh.
+	expired := oneOffConnectionInfoFor(t, inst, time.Now().Add(-time.Hour))
+	valid := oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour))
+	spy := &spyConnectionInfoCache{
+		connectInfoCalls: []connectionInfoResp{
+			{info: oneOffConnectionInfo(cn, expired)},
+			{info: oneOffConnectionInfo(cn, valid)},
+		},
+	}
+	d.cache[cn] = monitoredCache{
+		openConns:           new(uint64),
+		connErrLimiter:      rate.NewLimiter(rate.Every(time.Hour), 1),
+		connectionInfoCache: spy,
+	}
+
+	conn, res, err := d.DialWithResult(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected DialWithResult to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	if !res.ForcedRefresh || !res.CacheHit {
+		t.Fatalf("want a forced refresh of cached connection info, got = %+v", res)
+	}
+}
+
+func TestDialerWithRefreshOnDialFailureDisabled(t *testing.T) {
+	tcs := []struct {
+		desc string
+		opts []Option
+		want int
+	}{
+		{
+			desc: "refreshes by default",
+			want: 1,
+		},
+		{
+			desc: "does not refresh with the option set",
+			opts: []Option{WithRefreshOnDialFailureDisabled()},
+			want: 0,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance",
+			)
+			opts := append([]Option{
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				WithDialFunc(func(context.Context, string, string) (net.Conn, error) {
+					return nil, errors.New("connection refused")
+				}),
+			}, tc.opts...)
+			d, err := NewDialer(ctx, opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+
+			cn, _ := instance.ParseURI(testInstanceURI)
+			valid := oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour))
+			spy := &spyConnectionInfoCache{
+				connectInfoCalls: []connectionInfoResp{
+					{info: oneOffConnectionInfo(cn, valid)},
+				},
+			}
+			d.cache[cn] = monitoredCache{
+				openConns:           new(uint64),
+				connErrLimiter:      rate.NewLimiter(rate.Every(time.Hour), 1),
+				connectionInfoCache: spy,
+			}
+
+			_, res, err := d.DialWithResult(ctx, testInstanceURI)
+			var wantErr *errtype.DialError
+			if !errors.As(err, &wantErr) {
+				t.Fatalf("want = %T, got = %v", wantErr, err)
+			}
+			if got := spy.ForceRefreshCalls(); got != tc.want {
+				t.Fatalf("want ForceRefresh calls = %v, got = %v", tc.want, got)
+			}
+			if res.ForcedRefresh != (tc.want > 0) {
+				t.Fatalf("want ForcedRefresh = %v, got = %+v", tc.want > 0, res)
+			}
+		})
+	}
+}
+
+func TestDialerWithClientCertProbe(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		// Skip the metadata exchange so that only the probe can surface the
+		// server's rejection of the client certificate.
+		WithOptOutOfAdvancedConnectionCheck(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	cn, _ := instance.ParseURI(testInstanceURI)
+	valid := oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour))
+	// The server rejects the first certificate, as it would an expired one,
+	// and accepts the refreshed one.
+	rejected := valid
+	rejected.ClientCert = selfSignedCert(t)
+	spy := &spyConnectionInfoCache{
+		connectInfoCalls: []connectionInfoResp{
+			{info: oneOffConnectionInfo(cn, rejected)},
+			{info: oneOffConnectionInfo(cn, valid)},
+		},
+	}
+	d.cache[cn] = monitoredCache{
+		openConns:           new(uint64),
+		connErrLimiter:      rate.NewLimiter(rate.Every(time.Hour), 1),
+		connectionInfoCache: spy,
+	}
+
+	conn, err := d.Dial(ctx, testInstanceURI, WithClientCertProbe(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	if got := spy.ForceRefreshCalls(); got != 1 {
+		t.Fatalf("want ForceRefresh calls = 1, got = %v", got)
+	}
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("want write on the retried connection to succeed, got = %v", err)
+	}
+}
+
+func TestPrefixConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	// The prefix holds the data read by a probe.
+	c := &prefixConn{Conn: client, prefix: []byte("ab")}
+	go func() { _, _ = server.Write([]byte("cd")) }()
+
+	got, err := io.ReadAll(io.LimitReader(c, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "abcd" {
+		t.Fatalf("want = %q, got = %q", "abcd", got)
+	}
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "self-signed"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMonitoredCacheRefreshOnConnErr(t *testing.T) {
+	certRejected := &net.OpError{
+		Op: "remote error", Err: errors.New("tls: bad certificate"),
+	}
+	tcs := []struct {
+		desc string
+		errs []error
+		want int
+	}{
+		{
+			desc: "certificate rejected",
+			errs: []error{certRejected},
+			want: 1,
+		},
+		{
+			desc: "repeated rejections are rate limited",
+			errs: []error{certRejected, certRejected, certRejected},
+			want: 1,
+		},
+		{
+			desc: "end of stream",
+			errs: []error{io.EOF},
+			want: 0,
+		},
+		{
+			desc: "connection reset",
+			errs: []error{&net.OpError{Op: "read", Err: errors.New("connection reset by peer")}},
+			want: 0,
+		},
+		{
+			desc: "other TLS alert",
+			errs: []error{&net.OpError{Op: "remote error", Err: errors.New("tls: internal error")}},
+			want: 0,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			spy := &spyConnectionInfoCache{}
+			c := monitoredCache{
+				connErrLimiter:      rate.NewLimiter(rate.Every(time.Hour), 1),
+				connectionInfoCache: spy,
+			}
+			for _, err := range tc.errs {
+				c.refreshOnConnErr(err)
+			}
+			if got := spy.ForceRefreshCalls(); got != tc.want {
+				t.Fatalf("want = %v ForceRefresh calls, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDialerSupportsOneOffDialFunction(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx,
+		WithDialFunc(func(_ context.Context, _, _ string) (net.Conn, error) {
+			return nil, errors.New("sentinel error")
+		}),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer func() {
+		if err := d.Close(); err != nil {
+			t.Log(err)
+		}
+		_ = cleanup()
+	}()
+
+	sentinelErr := errors.New("dial func was called")
+	f := func(context.Context, string, string) (net.Conn, error) {
+		return nil, sentinelErr
+	}
+
+	_, err = d.Dial(ctx, testInstanceURI, WithOneOffDialFunc(f))
+	if !errors.Is(err, sentinelErr) {
+		t.Fatal("one-off dial func was not called")
+	}
+}
+
+func TestDialerCloseReportsFriendlyError(t *testing.T) {
+	d, err := NewDialer(
+		context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Close()
+
+	_, err = d.Dial(context.Background(), testInstanceURI)
+	if !errors.Is(err, ErrDialerClosed) {
+		t.Fatalf("want = %v, got = %v", ErrDialerClosed, err)
+	}
+
+	// Ensure multiple calls to close don't panic
+	_ = d.Close()
+
+	_, err = d.Dial(context.Background(), testInstanceURI)
+	if !errors.Is(err, ErrDialerClosed) {
+		t.Fatalf("want = %v, got = %v", ErrDialerClosed, err)
+	}
+}
+
+func TestDialerCloseDuringDialReportsFriendlyError(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithLazyRefresh(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	uri, _ := instance.ParseURI(testInstanceURI)
+	if _, _, _, _, err := d.instanceInfo(ctx, uri, d.defaultDialCfg, nil); err != nil {
+		t.Fatalf("expected instanceInfo to succeed, but got error: %v", err)
+	}
+	// A dial that started before Close finds the instance's cache closed.
+	_ = d.Close()
+	_, _, _, _, err = d.instanceInfo(ctx, uri, d.defaultDialCfg, nil)
+	if !errors.Is(err, ErrDialerClosed) {
+		t.Fatalf("want = %v, got = %v", ErrDialerClosed, err)
+	}
+}
+
+func TestDialerRefreshStatus(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if _, err := d.RefreshStatus(testInstanceURI); err == nil {
+		t.Fatal("want error for instance that has not been dialed, got nil")
+	}
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	s, err := d.RefreshStatus(testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected RefreshStatus to succeed, but got error: %v", err)
+	}
+	now := time.Now()
+	if s.LastRefresh.IsZero() || s.LastRefresh.After(now) {
+		t.Fatalf("want last refresh before now, got = %v", s.LastRefresh)
+	}
+	if !s.Expiration.After(now) {
+		t.Fatalf("want expiration after now, got = %v", s.Expiration)
+	}
+	if !s.NextRefresh.After(now) || !s.NextRefresh.Before(s.Expiration) {
+		t.Fatalf(
+			"want next refresh between now and expiration (%v), got = %v",
+			s.Expiration, s.NextRefresh,
+		)
+	}
+}
+
+func TestDialerRefreshMode(t *testing.T) {
+	tcs := []struct {
+		desc string
+		opts []Option
+		want string
+	}{
+		{
+			desc: "refresh ahead by default",
+			want: "refresh-ahead",
+		},
+		{
+			desc: "lazy refresh",
+			opts: []Option{WithLazyRefresh()},
+			want: "lazy",
+		},
+		{
+			desc: "background refresh disabled",
+			opts: []Option{WithBackgroundRefreshDisabled()},
+			want: "lazy",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := append([]Option{WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey)}, tc.opts...)
+			d, err := NewDialer(context.Background(), opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+			if got := d.RefreshMode(); got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// statusCache is a connectionInfoCache that reports a fixed refresh status.
+type statusCache struct {
+	status alloydb.RefreshStatus
+	// embed interface to avoid having to implement irrelevant methods
+	connectionInfoCache
+}
+
+func (c *statusCache) RefreshStatus() alloydb.RefreshStatus {
+	return c.status
+}
+
+func TestDialerStats(t *testing.T) {
+	d, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	if got := d.Stats(); got.CachedInstances != 0 || len(got.Instances) != 0 {
+		t.Fatalf("want no cached instances, got = %+v", got)
+	}
+
+	now := time.Now()
+	first, _ := instance.ParseURI(
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/a",
+	)
+	second, _ := instance.ParseURI(
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/b",
+	)
+	firstStatus := alloydb.RefreshStatus{
+		LastRefresh: now.Add(-time.Minute),
+		Expiration:  now.Add(time.Hour),
+		NextRefresh: now.Add(time.Minute),
+	}
+	secondStatus := alloydb.RefreshStatus{
+		LastRefresh: now.Add(-time.Hour),
+		LastFailure: now.Add(-time.Second),
+		Expiration:  now.Add(time.Minute),
+	}
+	firstOpen, secondOpen := uint64(2), uint64(3)
+	// Add the instances out of order to check the snapshot is sorted.
+	d.cache[second] = monitoredCache{
+		openConns:           &secondOpen,
+		connectionInfoCache: &statusCache{status: secondStatus},
+	}
+	d.cache[first] = monitoredCache{
+		openConns:           &firstOpen,
+		connectionInfoCache: &statusCache{status: firstStatus},
+	}
+
+	want := DialerStats{
+		CachedInstances: 2,
+		OpenConnections: 5,
+		Instances: []InstanceStats{
+			{
+				Instance:        first.URI(),
+				OpenConnections: 2,
+				RefreshStatus: RefreshStatus{
+					LastRefresh: firstStatus.LastRefresh,
+					Expiration:  firstStatus.Expiration,
+					NextRefresh: firstStatus.NextRefresh,
+				},
+			},
+			{
+				Instance:        second.URI(),
+				OpenConnections: 3,
+				RefreshStatus: RefreshStatus{
+					LastRefresh: secondStatus.LastRefresh,
+					LastFailure: secondStatus.LastFailure,
+					Expiration:  secondStatus.Expiration,
+				},
+			},
+		},
+	}
+	if got := d.Stats(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want = %+v, got = %+v", want, got)
+	}
+	// Remove the entries so that closing the dialer doesn't close the stub
+	// caches.
+	d.lock.Lock()
+	delete(d.cache, first)
+	delete(d.cache, second)
+	d.lock.Unlock()
+}
+
+func TestDialerWithSeparateAdminAPIEndpoints(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// Each mock server only responds to one of the two API calls.
+	infoClient, infoURL, infoCleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+	)
+	_, certURL, certCleanup := mock.HTTPClient(
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := infoCleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := certCleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		// Both test servers use the same certificate, so either client works.
+		WithHTTPClient(infoClient),
+		WithConnectionInfoEndpoint(infoURL),
+		WithCertificateEndpoint(certURL),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+}
+
+// spyOnCloseAdminClient records the Admin API clients closed by a Dialer
+// until the test ends.
+func spyOnCloseAdminClient(t *testing.T) func() []*alloydbadmin.AlloyDBAdminClient {
+	var (
+		mu     sync.Mutex
+		closed []*alloydbadmin.AlloyDBAdminClient
+	)
+	orig := closeAdminClient
+	closeAdminClient = func(c *alloydbadmin.AlloyDBAdminClient) error {
+		mu.Lock()
+		closed = append(closed, c)
+		mu.Unlock()
+		return orig(c)
+	}
+	t.Cleanup(func() { closeAdminClient = orig })
+	return func() []*alloydbadmin.AlloyDBAdminClient {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*alloydbadmin.AlloyDBAdminClient{}, closed...)
+	}
+}
+
+func TestDialerCloseClosesAdminClients(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("clients created by the dialer", func(t *testing.T) {
+		closed := spyOnCloseAdminClient(t)
+		d, err := NewDialer(ctx,
+			WithTokenSource(stubTokenSource{}),
+			WithRSAKey(testRSAKey),
+			WithConnectionInfoEndpoint("https://info.example.com"),
+			WithCertificateEndpoint("https://cert.example.com"),
+		)
+		if err != nil {
+			t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+		}
+		if got := closed(); len(got) != 0 {
+			t.Fatalf("want no clients closed before Close, got = %v", len(got))
+		}
+		if err := d.Close(); err != nil {
+			t.Fatalf("expected Close to succeed, but got error: %v", err)
+		}
+		// The default client and one per endpoint.
+		if got := closed(); len(got) != 3 {
+			t.Fatalf("want 3 clients closed, got = %v", len(got))
+		}
+		// Closing again does not close the clients twice.
+		d.Close()
+		if got := closed(); len(got) != 3 {
+			t.Fatalf("want 3 clients closed, got = %v", len(got))
+		}
+	})
+
+	t.Run("NewDialer fails", func(t *testing.T) {
+		closed := spyOnCloseAdminClient(t)
+		_, err := NewDialer(ctx,
+			WithTokenSource(stubTokenSource{}),
+			WithRSAKey(testRSAKey),
+			WithConnectionInfoEndpoint("https://info.example.com"),
+			WithCertificateEndpoint("https://cert.example.com"),
+			// The invalid dial option fails NewDialer after the clients are
+			// created.
+			WithDefaultDialOptions(WithDialRetries(-1, time.Millisecond)),
+		)
+		if err == nil {
+			t.Fatal("expected NewDialer to fail, but got no error")
+		}
+		if got := closed(); len(got) != 3 {
+			t.Fatalf("want 3 clients closed, got = %v", len(got))
+		}
+	})
+
+	t.Run("client supplied with WithAdminClient", func(t *testing.T) {
+		closed := spyOnCloseAdminClient(t)
+		c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx,
+			option.WithTokenSource(stubTokenSource{}),
+		)
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		defer c.Close()
+		d, err := NewDialer(ctx,
+			WithTokenSource(stubTokenSource{}),
+			WithRSAKey(testRSAKey),
+			WithAdminClient(c),
+			WithConnectionInfoEndpoint("https://info.example.com"),
+		)
+		if err != nil {
+			t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+		}
+		if err := d.Close(); err != nil {
+			t.Fatalf("expected Close to succeed, but got error: %v", err)
+		}
+		got := closed()
+		if len(got) != 1 {
+			t.Fatalf("want 1 client closed, got = %v", len(got))
+		}
+		if got[0] == c {
+			t.Fatal("want the client from WithAdminClient to stay open")
+		}
+	})
+}
+
+// redirectTransport is an http.RoundTripper that records the host of each
+// request and then sends the request to target instead.
+type redirectTransport struct {
+	mu     sync.Mutex
+	hosts  []string
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (r *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.hosts = append(r.hosts, req.URL.Host)
+	r.mu.Unlock()
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	req.Host = r.target.Host
+	return r.base.RoundTrip(req)
+}
+
+func TestDialerWithRegionalAdminEndpoint(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, mockURL, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	target, err := url.Parse(mockURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := &redirectTransport{target: target, base: mc.Transport}
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithHTTPClient(&http.Client{Transport: rt}),
+		WithAdminAPIEndpoint("https://alloydb.googleapis.com"),
+		WithRegionalAdminEndpoint(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if len(rt.hosts) != 2 {
+		t.Fatalf("want 2 Admin API requests, got = %v", rt.hosts)
+	}
+	const want = "my-region-alloydb.googleapis.com"
+	for _, h := range rt.hosts {
+		if h != want {
+			t.Fatalf("want host = %v, got = %v", want, h)
+		}
+	}
+}
+
+func TestDialerCloseClosesRegionalAdminClients(t *testing.T) {
+	ctx := context.Background()
+	closed := spyOnCloseAdminClient(t)
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithRegionalAdminEndpoint(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	var regional []*alloydbadmin.AlloyDBAdminClient
+	for _, region := range []string{"us-central1", "europe-west1"} {
+		c, err := d.adminClient(ctx, region)
+		if err != nil {
+			t.Fatalf("expected adminClient to succeed, but got error: %v", err)
+		}
+		regional = append(regional, c)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, but got error: %v", err)
+	}
+
+	got := closed()
+	for _, c := range regional {
+		if !slices.Contains(got, c) {
+			t.Fatalf("want regional client %p closed, got = %v", c, got)
+		}
+	}
+	// A closed dialer doesn't create more clients.
+	if _, err := d.adminClient(ctx, "asia-east1"); !errors.Is(err, ErrDialerClosed) {
+		t.Fatalf("want = %v, got = %v", ErrDialerClosed, err)
+	}
+}
+
+// recordUserAgentTransport is an http.RoundTripper that records the User-Agent
+// header of each request.
+type recordUserAgentTransport struct {
+	mu         sync.Mutex
+	userAgents []string
+	base       http.RoundTripper
+}
+
+func (r *recordUserAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.userAgents = append(r.userAgents, req.Header.Get("User-Agent"))
+	r.mu.Unlock()
+	return r.base.RoundTrip(req)
+}
+
+// TestDialerSendsUserAgentToAdminAPI checks the User-Agent header on the
+// wire. Passing option.WithUserAgent to the Admin API client alone fails it:
+// requests carry "Go-http-client/1.1" with the default HTTP client and no
+// User-Agent with WithHTTPClient.
+func TestDialerSendsUserAgentToAdminAPI(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	want := userAgent + " custom-agent/1.0"
+
+	t.Run("default HTTP client", func(t *testing.T) {
+		var (
+			mu  sync.Mutex
+			got []string
+		)
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			got = append(got, r.Header.Get("User-Agent"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer s.Close()
+		d, err := NewDialer(ctx,
+			WithTokenSource(stubTokenSource{}),
+			WithRSAKey(testRSAKey),
+			WithUserAgent("custom-agent/1.0"),
+			WithAdminAPIEndpoint(s.URL),
+		)
+		if err != nil {
+			t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+		}
+		defer d.Close()
+
+		// The server fails every request, so only the header matters.
+		_, _ = d.ConnectionInfo(ctx, testInstanceURI)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(got) == 0 {
+			t.Fatal("want Admin API requests, got none")
+		}
+		for _, ua := range got {
+			if ua != want {
+				t.Fatalf("want User-Agent = %q, got = %q", want, ua)
+			}
+		}
+	})
+
+	t.Run("WithHTTPClient", func(t *testing.T) {
+		mc, url, cleanup := mock.HTTPClient(
+			mock.InstanceGetSuccess(inst, 1),
+			mock.CreateEphemeralSuccess(inst, 1),
+		)
+		defer func() {
+			if err := cleanup(); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}()
+		rt := &recordUserAgentTransport{base: mc.Transport}
+		d, err := NewDialer(ctx,
+			WithTokenSource(stubTokenSource{}),
+			WithRSAKey(testRSAKey),
+			WithUserAgent("custom-agent/1.0"),
+			WithHTTPClient(&http.Client{Transport: rt}),
+			WithAdminAPIEndpoint(url),
+		)
+		if err != nil {
+			t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+		}
+		defer d.Close()
+
+		if _, err := d.ConnectionInfo(ctx, testInstanceURI); err != nil {
+			t.Fatalf("expected ConnectionInfo to succeed, but got error: %v", err)
+		}
+
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		if len(rt.userAgents) != 2 {
+			t.Fatalf("want 2 Admin API requests, got = %v", rt.userAgents)
+		}
+		for _, ua := range rt.userAgents {
+			if ua != want {
+				t.Fatalf("want User-Agent = %q, got = %q", want, ua)
+			}
+		}
+	})
+}
+
+func TestDialerDialOptionPrecedence(t *testing.T) {
+	defaultErr := errors.New("default dial func")
+	contextErr := errors.New("context dial func")
+	callErr := errors.New("per-call dial func")
+	dialFunc := func(err error) DialOption {
+		return WithOneOffDialFunc(func(context.Context, string, string) (net.Conn, error) {
+			return nil, err
+		})
+	}
+
+	tcs := []struct {
+		desc    string
+		ctxOpts []DialOption
+		opts    []DialOption
+		want    error
+	}{
+		{
+			desc: "dialer defaults only",
+			want: defaultErr,
+		},
+		{
+			desc:    "context options override dialer defaults",
+			ctxOpts: []DialOption{dialFunc(contextErr)},
+			want:    contextErr,
+		},
+		{
+			desc:    "per-call options override context options",
+			ctxOpts: []DialOption{dialFunc(contextErr)},
+			opts:    []DialOption{dialFunc(callErr)},
+			want:    callErr,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			d, err := NewDialer(
+				context.Background(),
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				WithDefaultDialOptions(dialFunc(defaultErr)),
+			)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+			inst, _ := instance.ParseURI(testInstanceURI)
+			d.cache[inst] = monitoredCache{
+				connectionInfoCache: &spyConnectionInfoCache{
+					connectInfoCalls: []connectionInfoResp{{
+						info: alloydb.ConnectionInfo{
+							IPAddrs:    map[string]string{alloydb.PrivateIP: "10.0.0.1"},
+							Expiration: time.Now().Add(time.Hour),
+						},
+					}},
+				},
+			}
+
+			ctx := context.Background()
+			if tc.ctxOpts != nil {
+				ctx = ContextWithDialOptions(ctx, tc.ctxOpts...)
+			}
+			_, err = d.Dial(ctx, testInstanceURI, tc.opts...)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("want = %v, got = %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestDialerWithNetworkType(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	var got string
+	spy := WithOneOffDialFunc(func(_ context.Context, network, _ string) (net.Conn, error) {
+		got = network
+		return nil, errors.New("sentinel error")
+	})
+	tcs := []struct {
+		desc string
+		opts []DialOption
+		want string
+	}{
+		{desc: "default", opts: []DialOption{spy}, want: "tcp"},
+		{desc: "tcp4", opts: []DialOption{spy, WithNetworkType("tcp4")}, want: "tcp4"},
+		{desc: "tcp6", opts: []DialOption{spy, WithNetworkType("tcp6")}, want: "tcp6"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, _ = d.Dial(ctx, testInstanceURI, tc.opts...)
+			if got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+
+	_, err = d.Dial(ctx, testInstanceURI, WithNetworkType("udp"))
+	var wantErr *errtype.ConfigError
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("want = %T, got = %v", wantErr, err)
+	}
+}
+
+func TestDialerWithFastFailover(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		// The private IP is never reachable in this test.
+		mock.WithPrivateIP("10.0.0.1"),
+		mock.WithPublicIP("127.0.0.1"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	canceled := make(chan struct{})
+	var dialer net.Dialer
+	f := WithOneOffDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if strings.HasPrefix(addr, "10.0.0.1") {
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		}
+		return dialer.DialContext(ctx, network, addr)
+	})
+
+	conn, err := d.Dial(ctx, testInstanceURI, f, WithFastFailover(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	// The connection reports the address that won the race, not the
+	// preferred one.
+	ac, ok := conn.(interface{ RemoteInstanceAddr() string })
+	if !ok {
+		t.Fatalf("want conn to implement RemoteInstanceAddr, got = %T", conn)
+	}
+	if got, want := ac.RemoteInstanceAddr(), "127.0.0.1:5433"; got != want {
+		t.Fatalf("RemoteInstanceAddr() mismatch, want = %v, got = %v", want, got)
+	}
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("want losing dial to be canceled")
+	}
+}
+
+func TestDialerConnectionInfo(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithPublicIP("127.0.0.2"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	ci, err := d.ConnectionInfo(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected ConnectionInfo to succeed, but got error: %v", err)
+	}
+	if ci.Instance != testInstanceURI {
+		t.Fatalf("want = %v, got = %v", testInstanceURI, ci.Instance)
+	}
+	wantAddrs := map[string]string{
+		"PRIVATE": "127.0.0.1",
+		"PUBLIC":  "127.0.0.2",
+	}
+	if !reflect.DeepEqual(ci.IPAddrs, wantAddrs) {
+		t.Fatalf("want = %v, got = %v", wantAddrs, ci.IPAddrs)
+	}
+	if !ci.Expiration.After(time.Now()) {
+		t.Fatalf("want expiration after now, got = %v", ci.Expiration)
+	}
+	// The connection info is cached for later calls.
+	if _, err := d.RefreshStatus(testInstanceURI); err != nil {
+		t.Fatalf("expected RefreshStatus to succeed, but got error: %v", err)
+	}
+}
+
+func TestDialerWithMaxConnectionLifetime(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI, WithMaxConnectionLifetime(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := conn.Write([]byte("hello")); err == nil {
+		t.Fatal("want write to fail after max lifetime, got nil")
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("want close of expired conn to succeed, got = %v", err)
+	}
+
+	u, err := instance.ParseURI(testInstanceURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.lock.RLock()
+	openConns := d.cache[u].openConns
+	d.lock.RUnlock()
+	for i := 0; i < 10; i++ {
+		if atomic.LoadUint64(openConns) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("want open connections = 0, got = %v", atomic.LoadUint64(openConns))
+}
+
+func TestInstrumentedConnIdleTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+
+	var closes atomic.Int32
+	conn := newInstrumentedConn(client, func() { closes.Add(1) },
+		"dialer-id", testInstanceURI, "127.0.0.1:5433",
+	)
+	conn.record = func(f func()) { f() }
+	conn.startTimers(0, 100*time.Millisecond)
+
+	// Writing resets the idle timer, so the connection outlives the timeout.
+	for i := 0; i < 5; i++ {
+		if _, err := conn.Write([]byte("hello")); err != nil {
+			t.Fatalf("want write to succeed while active, got = %v", err)
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	// The connection goes idle.
+	time.Sleep(200 * time.Millisecond)
+	if _, err := conn.Write([]byte("hello")); err == nil {
+		t.Fatal("want write to fail after idle timeout, got nil")
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("want close of idle conn to succeed, got = %v", err)
+	}
+	if got := closes.Load(); got != 1 {
+		t.Fatalf("want close to be reported once, got = %v", got)
+	}
+}
+
+func TestDialerConcurrentColdDialsRefreshOnce(t *testing.T) {
+	tcs := []struct {
+		desc string
+		opts []Option
+	}{
+		{desc: "refresh ahead cache"},
+		{desc: "lazy refresh cache", opts: []Option{WithLazyRefresh()}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance",
+			)
+			// Only a single call to each endpoint is expected. Any additional
+			// calls fail.
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			stop := mock.StartServerProxy(t, inst)
+			defer func() {
+				stop()
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+			)
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			opts := append([]Option{WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey)}, tc.opts...)
+			d, err := NewDialer(ctx, opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			const n = 50
+			var wg sync.WaitGroup
+			errs := make(chan error, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					conn, err := d.Dial(ctx, testInstanceURI)
+					if err != nil {
+						errs <- err
+						return
+					}
+					_ = conn.Close()
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Errorf("expected Dial to succeed, but got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDialerWarmConnectionInfoIsNotShared(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	uri, _ := instance.ParseURI(testInstanceURI)
+	cache, _, err := d.connectionInfoCache(ctx, uri)
+	if err != nil {
+		t.Fatalf("expected connectionInfoCache to succeed, but got error: %v", err)
+	}
+	if _, err := d.connectionInfo(ctx, uri, cache); err != nil {
+		t.Fatalf("expected connectionInfo to succeed, but got error: %v", err)
+	}
+
+	// Hold a shared call for the cache open. A caller that joined it would
+	// wait until its context is done.
+	block := make(chan struct{})
+	defer close(block)
+	key := fmt.Sprintf("%s/%p", uri.String(), cache.openConns)
+	d.infoGroup.DoChan(key, func() (any, error) {
+		<-block
+		return nil, errors.New("shared call")
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if _, err := d.connectionInfo(ctx, uri, cache); err != nil {
+		t.Fatalf("expected connectionInfo to succeed, but got error: %v", err)
+	}
+}
+
+// fakeConn wraps a net.Conn to hide its concrete type.
+type fakeConn struct {
+	net.Conn
+}
+
+func TestDialerWithTCPLinger(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	var dialer net.Dialer
+	tcs := []struct {
+		desc string
+		opts []DialOption
+	}{
+		{
+			desc: "with a TCP connection",
+			opts: []DialOption{WithTCPLinger(0)},
+		},
+		{
+			desc: "with a non-TCP connection",
+			opts: []DialOption{
+				WithTCPLinger(5),
+				WithOneOffDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+					conn, err := dialer.DialContext(ctx, network, addr)
+					if err != nil {
+						return nil, err
+					}
+					return &fakeConn{Conn: conn}, nil
+				}),
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			conn, err := d.Dial(ctx, testInstanceURI, tc.opts...)
+			if err != nil {
+				t.Fatalf("expected Dial to succeed, but got error: %v", err)
+			}
+			defer conn.Close()
+			data, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatalf("expected ReadAll to succeed, got error %v", err)
+			}
+			if string(data) != "my-instance" {
+				t.Fatalf("expected known response from the server, but got %v", string(data))
+			}
+		})
+	}
+
+	_, err = d.Dial(ctx, testInstanceURI, WithTCPLinger(-1))
+	var wantErr *errtype.ConfigError
+	if !errors.As(err, &wantErr) {
+		t.Fatalf("when linger is negative, want = %T, got = %v", wantErr, err)
+	}
+}
+
+func TestDialerWithMetadataExchangeBufferSize(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithMetadataExchangeBufferSize(minMessageSize),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	// Dial concurrently to ensure pooled buffers are not shared between
+	// connections.
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := d.Dial(ctx, testInstanceURI)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			data, err := io.ReadAll(conn)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if string(data) != "my-instance" {
+				errs <- fmt.Errorf("unexpected response from the server: %v", string(data))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func BenchmarkBuffer(b *testing.B) {
+	for _, size := range []int{minMessageSize, 4 * 1024, maxMessageSize} {
+		b.Run(fmt.Sprintf("pooled/%d", size), func(b *testing.B) {
+			buf := newBuffer(size)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					p := buf.get()
+					(*p)[0] = 1
+					buf.put(p)
+				}
+			})
+		})
+		b.Run(fmt.Sprintf("unpooled/%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					p := make([]byte, size)
+					p[0] = 1
+					sink = p
+				}
+			})
+		})
+	}
+}
+
+// sink prevents the compiler from optimizing away allocations in benchmarks.
+var sink []byte
+
+// readAllTLS reads conn until the server closes it, which also processes any
+// TLS session tickets, and reports whether the TLS session was resumed.
+func readAllTLS(t testing.TB, conn net.Conn) bool {
+	t.Helper()
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+	return conn.(*instrumentedConn).Conn.(*tls.Conn).ConnectionState().DidResume
+}
+
+func TestDialerWithTLSSessionCache(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithLazyRefresh(),
+		WithTLSSessionCache(8),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	dial := func() bool {
+		t.Helper()
+		conn, err := d.Dial(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		return readAllTLS(t, conn)
+	}
+	if dial() {
+		t.Fatal("want first connection to use a full handshake")
+	}
+	if !dial() {
+		t.Fatal("want second connection to resume the TLS session")
+	}
+}
+
+func TestCertSessionCacheSeparatesCertificates(t *testing.T) {
+	c := tls.NewLRUClientSessionCache(8)
+	certA := tls.Certificate{Certificate: [][]byte{[]byte("cert-a")}}
+	certB := tls.Certificate{Certificate: [][]byte{[]byte("cert-b")}}
+
+	newCertSessionCache(c, certA).Put("10.0.0.1", &tls.ClientSessionState{})
+	if _, ok := newCertSessionCache(c, certA).Get("10.0.0.1"); !ok {
+		t.Fatal("want session to be found for the same certificate")
+	}
+	if _, ok := newCertSessionCache(c, certB).Get("10.0.0.1"); ok {
+		t.Fatal("want session to be missing for a different certificate")
+	}
+	if got := newCertSessionCache(nil, certA); got != nil {
+		t.Fatalf("want nil cache when sessions are disabled, got %v", got)
+	}
+}
+
+func BenchmarkDialTLSSessionCache(b *testing.B) {
+	tcs := []struct {
+		desc string
+		opts []Option
+	}{
+		{desc: "full handshake"},
+		{desc: "session resumption", opts: []Option{WithTLSSessionCache(8)}},
+	}
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	stop := mock.StartServerProxy(b, inst)
+	b.Cleanup(stop)
+	for _, tc := range tcs {
+		b.Run(tc.desc, func(b *testing.B) {
+			ctx := context.Background()
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			defer cleanup()
+			c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+			)
+			if err != nil {
+				b.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			opts := append([]Option{
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				WithLazyRefresh(),
+				WithAdminClient(c),
+			}, tc.opts...)
+			d, err := NewDialer(ctx, opts...)
+			if err != nil {
+				b.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				conn, err := d.Dial(ctx, testInstanceURI)
+				if err != nil {
+					b.Fatalf("expected Dial to succeed, but got error: %v", err)
+				}
+				readAllTLS(b, conn)
+			}
+		})
+	}
+}
+
+func TestDialerWithOnDial(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	events := make(chan DialEvent, 3)
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithOnDial(func(e DialEvent) { events <- e }),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	next := func() DialEvent {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for dial event")
+			return DialEvent{}
+		}
+	}
+
+	for i, wantHit := range []bool{false, true} {
+		conn, err := d.Dial(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		conn.Close()
+		e := next()
+		if e.Instance != testInstanceURI || e.IPType != "PRIVATE" ||
+			e.CacheHit != wantHit || e.Latency <= 0 || e.Err != nil {
+			t.Fatalf("dial %d: unexpected event %+v", i, e)
+		}
+	}
+
+	_, err = d.Dial(ctx, testInstanceURI, WithPSC())
+	if err == nil {
+		t.Fatal("expected Dial to fail, but got no error")
+	}
+	if e := next(); e.IPType != "PSC" || !errors.Is(e.Err, err) {
+		t.Fatalf("unexpected event for failed dial %+v", e)
+	}
+}
+
+func TestDialerWithMaxRefreshFailures(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// Two failed background refreshes, then a successful refresh by a new
+	// cache.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 3),
+		mock.CreateEphemeralError(inst, http.StatusInternalServerError, 2),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithMaxRefreshFailures(2),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	// Create the cache without dialing, as Dial removes the cache after a
+	// failed refresh on its own.
+	cn, _ := instance.ParseURI(testInstanceURI)
+	if _, _, err := d.connectionInfoCache(ctx, cn); err != nil {
+		t.Fatalf("expected connectionInfoCache to succeed, but got error: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := d.RefreshStatus(testInstanceURI); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("want cache to be removed after repeated refresh failures")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A later dial creates a new cache.
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	conn.Close()
+}
+
+func TestDialerWithNegativeCacheTTL(t *testing.T) {
+	tcs := []struct {
+		desc string
+		code int
+		// wantCalls is the number of Admin API calls expected across two
+		// dials.
+		wantCalls int
+	}{
+		{desc: "permanent error is cached", code: http.StatusNotFound, wantCalls: 1},
+		{desc: "permission error is cached", code: http.StatusForbidden, wantCalls: 1},
+		{desc: "transient error is not cached", code: http.StatusInternalServerError, wantCalls: 2},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance",
+			)
+			// The mock fails if the expected calls are not made and responds
+			// to any additional calls with a 501 error.
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, tc.wantCalls),
+				mock.CreateEphemeralError(inst, tc.code, tc.wantCalls),
+			)
+			defer func() {
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+			)
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			d, err := NewDialer(ctx,
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				// Lazy refresh avoids background refreshes after failures.
+				WithLazyRefresh(),
+				WithNegativeCacheTTL(time.Minute),
+			)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			for i := 0; i < 2; i++ {
+				_, err = d.Dial(ctx, testInstanceURI)
+				var rErr *errtype.RefreshError
+				if !errors.As(err, &rErr) {
+					t.Fatalf("dial %d: want = %T, got = %v", i, rErr, err)
+				}
+				herr, ok := rErr.HTTPError()
+				if !ok || herr.Code != tc.code {
+					t.Fatalf("dial %d: want HTTP error code %v, got = %v", i, tc.code, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDialerRefreshErrorSentinels(t *testing.T) {
+	tcs := []struct {
+		desc    string
+		code    int
+		want    error
+		notWant error
+	}{
+		{
+			desc:    "not found",
+			code:    http.StatusNotFound,
+			want:    ErrInstanceNotFound,
+			notWant: ErrPermissionDenied,
+		},
+		{
+			desc:    "permission denied",
+			code:    http.StatusForbidden,
+			want:    ErrPermissionDenied,
+			notWant: ErrInstanceNotFound,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance",
+			)
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralError(inst, tc.code, 1),
+			)
+			defer func() {
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+			)
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			d, err := NewDialer(ctx,
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				WithLazyRefresh(),
+			)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			_, err = d.Dial(ctx, testInstanceURI)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("want errors.Is(err, %v), got = %v", tc.want, err)
+			}
+			if errors.Is(err, tc.notWant) {
+				t.Fatalf("want !errors.Is(err, %v), got = %v", tc.notWant, err)
+			}
+		})
+	}
+}
+
+func TestDialerWithDialRetries(t *testing.T) {
+	tcs := []struct {
+		desc    string
+		retries int
+		// wantAttempts is the number of times the dial function is called.
+		wantAttempts int
+		wantErr      bool
+	}{
+		{desc: "succeeds after retries", retries: 2, wantAttempts: 3},
+		{desc: "fails when retries are exhausted", retries: 1, wantAttempts: 2, wantErr: true},
+		{desc: "does not retry by default", retries: 0, wantAttempts: 1, wantErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance",
+			)
+			// Each failed attempt forces a refresh, so every attempt
+			// retrieves new connection info.
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, tc.wantAttempts),
+				mock.CreateEphemeralSuccess(inst, tc.wantAttempts),
+			)
+			stop := mock.StartServerProxy(t, inst)
+			defer func() {
+				stop()
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+			)
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+
+			var attempts int
+			d, err := NewDialer(ctx,
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				WithLazyRefresh(),
+				WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+					attempts++
+					if attempts <= 2 {
+						return nil, errors.New("transient error")
+					}
+					var d net.Dialer
+					return d.DialContext(ctx, network, addr)
+				}),
+			)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			conn, err := d.Dial(ctx, testInstanceURI,
+				WithDialRetries(tc.retries, time.Millisecond),
+			)
+			if tc.wantErr {
+				var dErr *errtype.DialError
+				if !errors.As(err, &dErr) {
+					t.Fatalf("want = %T, got = %v", dErr, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("expected Dial to succeed, but got error: %v", err)
+				}
+				conn.Close()
+			}
+			if attempts != tc.wantAttempts {
+				t.Fatalf("want = %v attempts, got = %v", tc.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestDialerWithDialRetriesDoesNotRetryConfigErrors(t *testing.T) {
+	d, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithDialFunc(func(context.Context, string, string) (net.Conn, error) {
+			t.Fatal("dial function should not be called")
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	_, err = d.Dial(context.Background(), "bad-instance-uri",
+		WithDialRetries(3, time.Millisecond),
+	)
+	var cErr *errtype.ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+}
+
+func TestDialerWithDialRetriesDoesNotRetryNonTransientErrors(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithPSC("x.y.alloydb.goog"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	var attempts int
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithPSCEndpointResolver(func(string) (string, error) {
+			attempts++
+			return "", errors.New("sentinel error")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	_, err = d.Dial(ctx, testInstanceURI, WithPSC(),
+		WithDialRetries(3, time.Millisecond),
+	)
+	var dErr *errtype.DialError
+	if !errors.As(err, &dErr) {
+		t.Fatalf("want = %T, got = %v", dErr, err)
+	}
+	if attempts != 1 {
+		t.Fatalf("want = 1 attempt, got = %v", attempts)
+	}
+}
+
+func TestDialerWithDialRetriesInvalid(t *testing.T) {
+	d, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithDialFunc(func(context.Context, string, string) (net.Conn, error) {
+			t.Fatal("dial function should not be called")
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	tcs := []struct {
+		desc    string
+		retries int
+		backoff time.Duration
+	}{
+		{desc: "negative retries", retries: -1, backoff: time.Millisecond},
+		{desc: "negative backoff", retries: 3, backoff: -time.Millisecond},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := d.Dial(context.Background(), testInstanceURI,
+				WithDialRetries(tc.retries, tc.backoff),
+			)
+			var cErr *errtype.ConfigError
+			if !errors.As(err, &cErr) {
+				t.Fatalf("want = %T, got = %v", cErr, err)
+			}
+		})
+	}
+}
+
+func TestDialRetryBackoff(t *testing.T) {
+	tcs := []struct {
+		desc    string
+		base    time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{desc: "first retry", base: time.Second, attempt: 0, want: time.Second},
+		{desc: "doubles", base: time.Second, attempt: 3, want: 8 * time.Second},
+		{desc: "capped", base: time.Second, attempt: 5, want: maxDialRetryBackoff},
+		{desc: "no overflow", base: time.Second, attempt: 70, want: maxDialRetryBackoff},
+		{desc: "zero backoff", base: 0, attempt: 70, want: 0},
+		{desc: "long backoff", base: time.Minute, attempt: 2, want: time.Minute},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := dialRetryBackoff(tc.base, tc.attempt); got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDialerConflictingIPTypes(t *testing.T) {
+	d, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithDialFunc(func(context.Context, string, string) (net.Conn, error) {
+			t.Fatal("dial function should not be called")
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	tcs := []struct {
+		desc string
+		ctx  context.Context
+		opts []DialOption
+	}{
+		{
+			desc: "in Dial options",
+			ctx:  context.Background(),
+			opts: []DialOption{WithPublicIP(), WithPSC()},
+		},
+		{
+			desc: "in nested Dial options",
+			ctx:  context.Background(),
+			opts: []DialOption{DialOptions(WithPrivateIP()), WithPublicIP()},
+		},
+		{
+			desc: "in context options",
+			ctx: ContextWithDialOptions(
+				context.Background(), WithPSC(), WithPrivateIP(),
+			),
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := d.Dial(tc.ctx, testInstanceURI, tc.opts...)
+			var cErr *errtype.ConfigError
+			if !errors.As(err, &cErr) {
+				t.Fatalf("want = %T, got = %v", cErr, err)
+			}
+			if !strings.Contains(err.Error(), "conflicting IP type") {
+				t.Fatalf("want conflicting IP type error, got = %v", err)
+			}
+		})
+	}
+}
+
+type errorTokenSource struct{}
+
+func (errorTokenSource) Token() (*oauth2.Token, error) {
+	return nil, errors.New("token error")
+}
+
+// memoryCache is a ConnectionInfoCache that holds fixed connection info.
+type memoryCache struct {
+	mu     sync.Mutex
+	info   ConnectionInfo
+	closed bool
+}
+
+func (c *memoryCache) ConnectionInfo(context.Context) (ConnectionInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info, nil
+}
+
+// ForceRefresh is a no-op, as the connection info never changes.
+func (c *memoryCache) ForceRefresh() {}
+
+func (c *memoryCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func TestDialerWithConnectionInfoCacheFactory(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// The mock fails the test if it receives any requests.
+	mc, url, cleanup := mock.HTTPClient()
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	var (
+		mu     sync.Mutex
+		caches = map[instance.URI]*memoryCache{}
+	)
+	factory := func(uri instance.URI) (ConnectionInfoCache, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		c := &memoryCache{
+			info: oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour)),
+		}
+		caches[uri] = c
+		return c, nil
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithConnectionInfoCacheFactory(factory),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	for i := 0; i < 2; i++ {
+		conn, err := d.Dial(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		data, err := io.ReadAll(conn)
+		if err != nil {
+			t.Fatalf("expected ReadAll to succeed, got error %v", err)
+		}
+		if string(data) != "my-instance" {
+			t.Fatalf("expected known response from the server, but got %v", string(data))
+		}
+		conn.Close()
+	}
+	mu.Lock()
+	if got := len(caches); got != 1 {
+		t.Fatalf("want factory to be called once, got = %v", got)
+	}
+	cn, _ := instance.ParseURI(testInstanceURI)
+	cache, ok := caches[cn]
+	mu.Unlock()
+	if !ok {
+		t.Fatalf("want cache for %v", cn)
+	}
+
+	ci, err := d.ConnectionInfo(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected ConnectionInfo to succeed, but got error: %v", err)
+	}
+	if want := cache.info.Expiration; !ci.Expiration.Equal(want) {
+		t.Fatalf("want expiration = %v, got = %v", want, ci.Expiration)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, but got error: %v", err)
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if !cache.closed {
+		t.Fatal("want cache to be closed with the dialer")
+	}
+}
+
+func TestDialerWithMaxCachedInstances(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	var (
+		mu     sync.Mutex
+		caches = map[instance.URI]*memoryCache{}
+	)
+	factory := func(uri instance.URI) (ConnectionInfoCache, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		c := &memoryCache{
+			info: oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour)),
+		}
+		caches[uri] = c
+		return c, nil
+	}
+	const n = 2
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithConnectionInfoCacheFactory(factory),
+		WithMaxCachedInstances(n),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	var uris []instance.URI
+	for i := 0; i <= n; i++ {
+		uri := fmt.Sprintf(
+			"projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance-%d", i,
+		)
+		conn, err := d.Dial(ctx, uri)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		conn.Close()
+		cn, _ := instance.ParseURI(uri)
+		uris = append(uris, cn)
+	}
+
+	d.lock.RLock()
+	_, firstCached := d.cache[uris[0]]
+	cached := len(d.cache)
+	d.lock.RUnlock()
+	if firstCached {
+		t.Fatalf("want %v to be evicted", uris[0])
+	}
+	if cached != n {
+		t.Fatalf("want %v cached instances, got = %v", n, cached)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, uri := range uris {
+		c := caches[uri]
+		c.mu.Lock()
+		closed := c.closed
+		c.mu.Unlock()
+		if want := i == 0; closed != want {
+			t.Fatalf("%v: want closed = %v, got = %v", uri, want, closed)
+		}
+	}
+}
+
+func TestDialerWithConnectionInfoCacheFactoryError(t *testing.T) {
+	ctx := context.Background()
+	wantErr := errors.New("factory error")
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithConnectionInfoCacheFactory(func(instance.URI) (ConnectionInfoCache, error) {
+			return nil, wantErr
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Dial(ctx, testInstanceURI); !errors.Is(err, wantErr) {
+		t.Fatalf("want = %v, got = %v", wantErr, err)
+	}
+}
+
+func TestDialerWithBackgroundRefreshDisabled(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	var events atomic.Int32
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithBackgroundRefreshDisabled(),
+		WithOnDial(func(DialEvent) { events.Add(1) }),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	dial := func() {
+		t.Helper()
+		conn, err := d.Dial(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		if _, err := io.ReadAll(conn); err != nil {
+			t.Fatalf("expected ReadAll to succeed, got error %v", err)
+		}
+		if err := conn.Close(); err != nil {
+			t.Fatalf("expected Close to succeed, got error %v", err)
+		}
+	}
+	// The first dial refreshes the connection info, which leaves the HTTP
+	// client's idle connections open.
+	dial()
+	cn, _ := instance.ParseURI(testInstanceURI)
+	d.lock.RLock()
+	cache := d.cache[cn]
+	d.lock.RUnlock()
+	if _, ok := cache.connectionInfoCache.(*alloydb.LazyRefreshCache); !ok {
+		t.Fatalf("want lazy refresh cache, got = %T", cache.connectionInfoCache)
+	}
+
+	before := runtime.NumGoroutine()
+	dial()
+	if after := runtime.NumGoroutine(); after != before {
+		t.Fatalf("want %v goroutines after Dial, got = %v", before, after)
+	}
+	// The callback runs before Dial returns.
+	if got := events.Load(); got != 2 {
+		t.Fatalf("want 2 dial events, got = %v", got)
+	}
+}
+
+func TestDialerVerifyCredentials(t *testing.T) {
+	tcs := []struct {
+		desc    string
+		opts    []Option
+		wantErr string
+	}{
+		{
+			desc: "valid token source",
+			opts: []Option{WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey)},
+		},
+		{
+			desc: "failing token source",
+			opts: []Option{WithTokenSource(errorTokenSource{})},
+			wantErr: "failed to get an OAuth2 token with scopes " +
+				"[" + CloudPlatformScope + "] for the AlloyDB Admin API: token error",
+		},
+		{
+			desc: "failing token source with IAM authentication",
+			opts: []Option{
+				WithTokenSource(errorTokenSource{}),
+				WithIAMAuthN(),
+			},
+			wantErr: "for the AlloyDB Admin API and IAM database authentication",
+		},
+		{
+			desc: "failing token source with custom scopes",
+			opts: []Option{
+				WithTokenSource(errorTokenSource{}),
+				WithScopes("https://www.googleapis.com/auth/alloydb.login"),
+			},
+			wantErr: "scopes [https://www.googleapis.com/auth/alloydb.login]",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			d, err := NewDialer(ctx, tc.opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+
+			err = d.VerifyCredentials(ctx)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("want no error, got = %v", err)
+				}
+				return
+			}
+			var cfgErr *errtype.ConfigError
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("want ConfigError, got = %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("want error containing %q, got = %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDialerSetTokenSource(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(errorTokenSource{}))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	// The metadata exchange fails without a token.
+	if _, err := d.Dial(ctx, testInstanceURI); err == nil {
+		t.Fatal("want Dial to fail with a failing token source")
+	}
+	// The fake server proxy stops accepting connections after a failed
+	// metadata exchange, so start a new one.
+	stop()
+	stop = mock.StartServerProxy(t, inst)
+
+	d.SetTokenSource(stubTokenSource{})
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+}
+
+func TestDialerWithPSCEndpointResolver(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		// The PSC DNS name does not resolve from the test's network.
+		mock.WithPSC("x.y.alloydb.goog"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	var resolved string
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithPSCEndpointResolver(func(instance string) (string, error) {
+			resolved = instance
+			if instance != testInstanceURI {
+				return "", errors.New("unknown instance")
+			}
+			return "127.0.0.1", nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, testInstanceURI, WithPSC())
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	if resolved != testInstanceURI {
+		t.Fatalf("resolver instance mismatch, want = %v, got = %v", testInstanceURI, resolved)
+	}
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+	ac := conn.(interface{ RemoteInstanceAddr() string })
+	if got, want := ac.RemoteInstanceAddr(), "127.0.0.1:5433"; got != want {
+		t.Fatalf("RemoteInstanceAddr() mismatch, want = %v, got = %v", want, got)
+	}
+}
+
+func TestDialerWithPSCEndpointResolverError(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithPSC("x.y.alloydb.goog"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithPSCEndpointResolver(func(string) (string, error) {
+			return "", errors.New("sentinel error")
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	_, err = d.Dial(ctx, testInstanceURI, WithPSC())
+	var dErr *errtype.DialError
+	if !errors.As(err, &dErr) || !strings.Contains(err.Error(), "sentinel error") {
+		t.Fatalf("want = %T wrapping sentinel error, got = %v", dErr, err)
+	}
+}
+
+// stubTXTResolver resolves the TXT records of domain names from a map.
+type stubTXTResolver map[string][]string
+
+func (r stubTXTResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestDialerWithDNSResolver(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	var gotAddr string
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithDNSResolver(),
+		WithDialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			gotAddr = addr
+			return proxy.Dial(ctx, network, addr)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	d.dnsResolver = stubTXTResolver{
+		"localhost": {"v=spf1 -all", testInstanceURI},
+	}
+	defer d.Close()
+
+	conn, err := d.Dial(ctx, "localhost")
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+	// The domain name is dialed instead of the address from the Admin API.
+	if want := "localhost:5433"; gotAddr != want {
+		t.Fatalf("dialed address: want = %v, got = %v", want, gotAddr)
+	}
+}
+
+func TestDialerWithDNSResolverErrors(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		resolver txtResolver
+	}{
+		{
+			desc: "without a DNS resolver",
+		},
+		{
+			desc:     "when the domain name does not exist",
+			resolver: stubTXTResolver{},
+		},
+		{
+			desc: "when no TXT record holds an instance URI",
+			resolver: stubTXTResolver{
+				"db.example.com": {"v=spf1 -all"},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			d, err := NewDialer(context.Background(),
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+			)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.dnsResolver = tc.resolver
+			defer d.Close()
+
+			_, err = d.Dial(context.Background(), "db.example.com")
+			var cfgErr *errtype.ConfigError
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("want = %T, got = %v", cfgErr, err)
+			}
+		})
+	}
+}
+
+// blockingTransport is an http.RoundTripper that blocks until the request's
+// context is done, simulating an unresponsive AlloyDB Admin API.
+type blockingTransport struct{}
+
+func (blockingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	<-r.Context().Done()
+	return nil, r.Context().Err()
+}
+
+func TestDialerInitialConnectionInfoRespectsDeadlines(t *testing.T) {
+	tcs := []struct {
+		desc string
+		opts []Option
+		// timeout is the Dial context's timeout. Zero means no timeout.
+		timeout time.Duration
+	}{
+		{
+			desc:    "Dial context with refresh ahead",
+			timeout: 10 * time.Millisecond,
+		},
+		{
+			desc:    "Dial context with lazy refresh",
+			opts:    []Option{WithLazyRefresh()},
+			timeout: 10 * time.Millisecond,
+		},
+		{
+			desc: "refresh timeout with refresh ahead",
+			opts: []Option{WithRefreshTimeout(10 * time.Millisecond)},
+		},
+		{
+			desc: "refresh timeout with lazy refresh",
+			opts: []Option{
+				WithLazyRefresh(), WithRefreshTimeout(10 * time.Millisecond),
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := append([]Option{
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				WithHTTPClient(&http.Client{Transport: blockingTransport{}}),
+			}, tc.opts...)
+			d, err := NewDialer(context.Background(), opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			start := time.Now()
+			_, err = d.Dial(ctx, testInstanceURI)
+			if err == nil {
+				t.Fatal("want Dial to fail, got no error")
+			}
+			if tc.timeout > 0 && !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("want = %v, got = %v", context.DeadlineExceeded, err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("want Dial to return promptly, took %v", elapsed)
+			}
+		})
+	}
+}
+
+// serviceAccountJSON returns service account credentials that request tokens
+// from tokenURL.
+func serviceAccountJSON(t *testing.T, tokenURL string) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "my-project",
+		"private_key_id": "my-key",
+		"private_key": string(pem.EncodeToMemory(
+			&pem.Block{Type: "PRIVATE KEY", Bytes: der},
+		)),
+		"client_email": "sa@my-project.iam.gserviceaccount.com",
+		"token_uri":    tokenURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDialerWithScopes(t *testing.T) {
+	// The token server records the scope claim of the JWT assertion sent by
+	// the service account credentials.
+	scopes := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if len(parts) != 3 {
+			http.Error(w, "invalid assertion", http.StatusBadRequest)
+			return
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var claims struct {
+			Scope string `json:"scope"`
+		}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		scopes <- claims.Scope
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer srv.Close()
+	creds := serviceAccountJSON(t, srv.URL)
+
+	tcs := []struct {
+		desc string
+		opts []Option
+		want string
+	}{
+		{
+			desc: "default scope",
+			opts: []Option{WithCredentialsJSON(creds)},
+			want: CloudPlatformScope,
+		},
+		{
+			desc: "custom scopes",
+			opts: []Option{WithCredentialsJSON(creds), WithScopes("scope-a", "scope-b")},
+			want: "scope-a scope-b",
+		},
+		{
+			desc: "custom scopes set before credentials",
+			opts: []Option{WithScopes("scope-a"), WithCredentialsJSON(creds)},
+			want: "scope-a",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			d, err := NewDialer(context.Background(), tc.opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+			if _, err := d.iamTokenSource.Token(); err != nil {
+				t.Fatalf("expected Token to succeed, but got error: %v", err)
+			}
+			if got := <-scopes; got != tc.want {
+				t.Fatalf("scope mismatch, want = %q, got = %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDialerWithScopesRequiresScope(t *testing.T) {
+	_, err := NewDialer(context.Background(), WithScopes())
+	var cErr *errtype.ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("want = %T, got = %v", cErr, err)
+	}
+}
+
+// spyLogger records the messages logged with Debugf.
+type spyLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *spyLogger) Debugf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, fmt.Sprintf(format, args...))
+}
+
+func (l *spyLogger) contains(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range l.msgs {
+		if strings.Contains(m, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDialerWarnIfPasswordIgnored(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		opts     []Option
+		password string
+		wantWarn bool
+	}{
+		{
+			desc:     "IAM authentication with password",
+			opts:     []Option{WithIAMAuthN()},
+			password: "secret",
+			wantWarn: true,
+		},
+		{
+			desc: "IAM authentication without password",
+			opts: []Option{WithIAMAuthN()},
+		},
+		{
+			desc:     "built-in authentication with password",
+			password: "secret",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			l := &spyLogger{}
+			opts := append([]Option{
+				WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey), WithDebugLogger(l),
+			}, tc.opts...)
+			d, err := NewDialer(context.Background(), opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
+
+			d.WarnIfPasswordIgnored(context.Background(), testInstanceURI, tc.password)
+			if got := l.contains("password"); got != tc.wantWarn {
+				t.Fatalf("want warning = %v, got = %v (messages = %v)", tc.wantWarn, got, l.msgs)
+			}
+		})
+	}
+}
+
+func TestDialerWithRefreshAheadWindowForCertDuration(t *testing.T) {
+	// The window is checked against the cert duration regardless of the
+	// order of the options.
+	for _, opts := range [][]Option{
+		{WithCertDuration(12 * time.Hour), WithRefreshAheadWindow(2 * time.Hour)},
+		{WithRefreshAheadWindow(2 * time.Hour), WithCertDuration(12 * time.Hour)},
+	} {
+		opts = append(opts, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+		d, err := NewDialer(context.Background(), opts...)
+		if err != nil {
+			t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+		}
+		d.Close()
+	}
+}
+
+func TestDialerWithCertDuration(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralWithCertDuration(inst, 1, 2*time.Hour),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithLazyRefresh(),
+		WithCertDuration(2*time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	errs := d.WarmupAll(ctx, []string{testInstanceURI})
+	if err := errs[testInstanceURI]; err != nil {
+		t.Fatalf("expected certificate request with configured duration, but got error: %v", err)
+	}
+}
+
+func TestDialerWithRefreshNotifier(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	tcs := []struct {
+		desc    string
+		opts    []Option
+		certReq *mock.Request
+		wantErr bool
+	}{
+		{
+			desc:    "refresh ahead",
+			certReq: mock.CreateEphemeralSuccess(inst, 1),
+		},
+		{
+			desc:    "lazy refresh",
+			opts:    []Option{WithLazyRefresh()},
+			certReq: mock.CreateEphemeralSuccess(inst, 1),
+		},
+		{
+			desc:    "failed refresh",
+			opts:    []Option{WithLazyRefresh()},
+			certReq: mock.CreateEphemeralEmptyChain(inst, 1),
+			wantErr: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				tc.certReq,
+			)
+			defer func() {
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+			ch := make(chan RefreshEvent, 1)
+			opts := append([]Option{
+				WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey), WithRefreshNotifier(ch),
+			}, tc.opts...)
+			d, err := NewDialer(ctx, opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			before := time.Now()
+			d.WarmupAll(ctx, []string{testInstanceURI})
+
+			select {
+			case e := <-ch:
+				if e.Instance != testInstanceURI {
+					t.Fatalf("want instance = %v, got = %v", testInstanceURI, e.Instance)
+				}
+				if e.Time.Before(before) {
+					t.Fatalf("want event time after %v, got = %v", before, e.Time)
+				}
+				if gotErr := e.Err != nil; gotErr != tc.wantErr {
+					t.Fatalf("want error = %v, got = %v", tc.wantErr, e.Err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for refresh event")
+			}
+		})
+	}
+}
+
+func TestDialerWithRefreshNotifierDoesNotBlock(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	// Nothing ever receives from the unbuffered channel.
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithRefreshNotifier(make(chan RefreshEvent)),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
 	}
+	d.client = c
+	defer d.Close()
+
+	errs := d.WarmupAll(ctx, []string{testInstanceURI})
+	if err := errs[testInstanceURI]; err != nil {
+		t.Fatalf("expected WarmupAll to succeed, but got error: %v", err)
+	}
+}
 
+func TestDialerWithBeforeDial(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		// The private IP is never reachable in this test.
+		mock.WithPrivateIP("10.0.0.1"),
+		mock.WithPublicIP("127.0.0.1"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	var (
+		gotURI  instance.URI
+		gotAddr string
+	)
 	d, err := NewDialer(ctx,
-		WithDialFunc(func(_ context.Context, _, _ string) (net.Conn, error) {
-			return nil, errors.New("sentinel error")
-		}),
 		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithBeforeDial(func(_ context.Context, uri instance.URI, cfg *PublicDialConfig) error {
+			gotURI = uri
+			if cfg.IPType != "PRIVATE" {
+				t.Errorf("want default IP type = PRIVATE, got = %v", cfg.IPType)
+			}
+			cfg.IPType = "PUBLIC"
+			cfg.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				gotAddr = addr
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			}
+			return nil
+		}),
 	)
 	if err != nil {
 		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
 	}
 	d.client = c
+	defer d.Close()
+
+	conn, res, err := d.DialWithResult(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	if got := gotURI.URI(); got != testInstanceURI {
+		t.Fatalf("want hook URI = %v, got = %v", testInstanceURI, got)
+	}
+	if want := "127.0.0.1:5433"; gotAddr != want || res.Addr != want {
+		t.Fatalf("want address = %v, got dialed = %v, result = %v", want, gotAddr, res.Addr)
+	}
+	if res.IPType != "PUBLIC" {
+		t.Fatalf("want IP type = PUBLIC, got = %v", res.IPType)
+	}
+}
+
+func TestDialerWithBeforeDialErrors(t *testing.T) {
+	tcs := []struct {
+		desc string
+		f    BeforeDialFunc
+	}{
+		{
+			desc: "hook returns an error",
+			f: func(context.Context, instance.URI, *PublicDialConfig) error {
+				return errors.New("dialing disabled")
+			},
+		},
+		{
+			desc: "hook sets an unsupported IP type",
+			f: func(_ context.Context, _ instance.URI, cfg *PublicDialConfig) error {
+				cfg.IPType = "BOGUS"
+				return nil
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			d, err := NewDialer(context.Background(),
+				WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey), WithBeforeDial(tc.f),
+			)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			defer d.Close()
 
-	_, err = d.Dial(ctx, testInstanceURI)
-	if !strings.Contains(err.Error(), "sentinel error") {
-		t.Fatalf("want = sentinel error, got = %v", err)
+			_, err = d.Dial(context.Background(), testInstanceURI)
+			var wantErr *errtype.ConfigError
+			if !errors.As(err, &wantErr) {
+				t.Fatalf("when dialing, want = %T, got = %v", wantErr, err)
+			}
+		})
 	}
 }
 
-func TestDialerUserAgent(t *testing.T) {
-	data, err := os.ReadFile("version.txt")
+func TestDialerDefaults(t *testing.T) {
+	if DefaultRefreshTimeout != alloydb.RefreshTimeout {
+		t.Fatalf(
+			"want DefaultRefreshTimeout = %v, got = %v",
+			alloydb.RefreshTimeout, DefaultRefreshTimeout,
+		)
+	}
+	d, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
 	if err != nil {
-		t.Fatalf("failed to read version.txt: %v", err)
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
 	}
-	ver := strings.TrimSpace(string(data))
-	want := "alloydb-go-connector/" + ver
-	if want != userAgent {
-		t.Errorf("embed version mismatched: want %q, got %q", want, userAgent)
+	defer d.Close()
+	if d.refreshTimeout != DefaultRefreshTimeout {
+		t.Fatalf(
+			"want refresh timeout = %v, got = %v",
+			DefaultRefreshTimeout, d.refreshTimeout,
+		)
+	}
+	if got := d.defaultDialCfg.tcpKeepAlive; got != DefaultTCPKeepAlive {
+		t.Fatalf("want TCP keep alive = %v, got = %v", DefaultTCPKeepAlive, got)
+	}
+	// The mock server proxy listens on the port AlloyDB instances use.
+	if DefaultServerProxyPort != "5433" {
+		t.Fatalf("want DefaultServerProxyPort = 5433, got = %v", DefaultServerProxyPort)
 	}
 }
 
-func TestDialerRemovesInvalidInstancesFromCache(t *testing.T) {
-	// When a dialer attempts to retrieve connection info for a
-	// non-existent instance, it should delete the instance from
-	// the cache and ensure no background refresh happens (which would be
-	// wasted cycles).
-	d, err := NewDialer(context.Background(),
-		WithTokenSource(stubTokenSource{}),
-		WithRefreshTimeout(time.Second),
-	)
+func externalAccountJSON(t *testing.T, tokenURL string) []byte {
+	t.Helper()
+	subjectToken := filepath.Join(t.TempDir(), "subject-token")
+	if err := os.WriteFile(subjectToken, []byte("subject-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"type": "external_account",
+		"audience": "//iam.googleapis.com/projects/123456/locations/global/" +
+			"workloadIdentityPools/my-pool/providers/my-provider",
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url":          tokenURL,
+		"credential_source":  map[string]interface{}{"file": subjectToken},
+	})
 	if err != nil {
-		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+		t.Fatal(err)
 	}
-	defer func(d *Dialer) {
-		err := d.Close()
-		if err != nil {
-			t.Log(err)
+	return b
+}
+
+func TestDialerWithExternalAccountCredentialsFile(t *testing.T) {
+	// The security token service records the scope requested in the token
+	// exchange.
+	scopes := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("subject_token"); got != "subject-token" {
+			http.Error(w, "unexpected subject token", http.StatusBadRequest)
+			return
 		}
-	}(d)
+		scopes <- r.FormValue("scope")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"token","issued_token_type":`+
+			`"urn:ietf:params:oauth:token-type:access_token",`+
+			`"token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer srv.Close()
+	credsFile := filepath.Join(t.TempDir(), "external-account.json")
+	if err := os.WriteFile(credsFile, externalAccountJSON(t, srv.URL), 0o600); err != nil {
+		t.Fatal(err)
+	}
 
-	// Populate instance map with connection info cache that will always fail.
-	// This allows the test to verify the error case path invoking close.
-	badInstanceName := "projects/bad/locations/bad/clusters/bad/instances/bad"
 	tcs := []struct {
 		desc string
-		uri  string
-		resp connectionInfoResp
-		opts []DialOption
+		opts []Option
+		want string
 	}{
 		{
-			desc: "dialing a bad instance URI",
-			uri:  badInstanceName,
-			resp: connectionInfoResp{
-				err: errors.New("connect info failed"),
-			},
+			desc: "default scope",
+			opts: []Option{WithCredentialsFile(credsFile)},
+			want: CloudPlatformScope,
 		},
 		{
-			desc: "specifying an invalid IP type",
-			uri:  testInstanceURI,
-			resp: connectionInfoResp{
-				info: alloydb.ConnectionInfo{
-					IPAddrs: map[string]string{
-						// no public IP
-						alloydb.PrivateIP: "10.0.0.1",
-					},
-					Expiration: time.Now().Add(time.Hour),
-				},
+			desc: "custom scopes",
+			opts: []Option{
+				WithCredentialsFile(credsFile),
+				WithScopes("https://www.googleapis.com/auth/alloydb.login"),
 			},
-			opts: []DialOption{WithPublicIP()},
+			want: "https://www.googleapis.com/auth/alloydb.login",
 		},
 	}
-
 	for _, tc := range tcs {
 		t.Run(tc.desc, func(t *testing.T) {
-			// Manually populate the internal cache with a spy
-			inst, _ := alloydb.ParseInstURI(tc.uri)
-			spy := &spyConnectionInfoCache{
-				connectInfoCalls: []connectionInfoResp{tc.resp},
-			}
-			d.cache[inst] = monitoredCache{
-				connectionInfoCache: spy,
+			d, err := NewDialer(context.Background(), tc.opts...)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
 			}
-
-			_, err = d.Dial(context.Background(), tc.uri, tc.opts...)
-			if err == nil {
-				t.Fatal("expected Dial to return error")
+			defer d.Close()
+			tok, err := d.iamTokenSource.Token()
+			if err != nil {
+				t.Fatalf("expected Token to succeed, but got error: %v", err)
 			}
-			// Verify that the connection info cache was closed (to prevent
-			// further failed refresh operations)
-			if got, want := spy.CloseWasCalled(), true; got != want {
-				t.Fatal("Close was not called")
+			if tok.AccessToken != "token" {
+				t.Fatalf("want access token = token, got = %v", tok.AccessToken)
 			}
-
-			// Now verify that bad connection name has been deleted from map.
-			d.lock.RLock()
-			_, ok := d.cache[inst]
-			d.lock.RUnlock()
-			if ok {
-				t.Fatal("connection info was not removed from cache")
+			if got := <-scopes; got != tc.want {
+				t.Fatalf("scope mismatch, want = %q, got = %q", tc.want, got)
 			}
 		})
 	}
-
 }
 
-func TestDialRefreshesExpiredCertificates(t *testing.T) {
-	d, err := NewDialer(
-		context.Background(),
-		WithTokenSource(stubTokenSource{}),
+func TestDialerInvalidate(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// Expect the connection info to be retrieved again after invalidation.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
 	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
 	if err != nil {
 		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
 	}
+	d.client = c
+	defer d.Close()
 
-	sentinel := errors.New("connect info failed")
-	inst := testInstanceURI
-	cn, _ := alloydb.ParseInstURI(inst)
-	spy := &spyConnectionInfoCache{
-		connectInfoCalls: []connectionInfoResp{
-			// First call returns expired certificate
-			{
-				info: alloydb.ConnectionInfo{
-					Expiration: time.Now().Add(-10 * time.Hour),
-				},
-			},
-			// Second call errors to validate error path
-			{
-				err: sentinel,
-			},
-		},
-	}
-	d.cache[cn] = monitoredCache{
-		connectionInfoCache: spy,
+	// Invalidating an instance that was never dialed does nothing.
+	if err := d.Invalidate(testInstanceURI); err != nil {
+		t.Fatalf("expected Invalidate to succeed, but got error: %v", err)
 	}
 
-	_, err = d.Dial(context.Background(), inst)
-	if !errors.Is(err, sentinel) {
-		t.Fatalf("expected Dial to return sentinel error, instead got = %v", err)
+	dial := func() DialResult {
+		conn, res, err := d.DialWithResult(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		if err := conn.Close(); err != nil {
+			t.Fatalf("expected Close to succeed, got error %v", err)
+		}
+		return res
 	}
-
-	// Verify that the cache was refreshed
-	if got, want := spy.ForceRefreshWasCalled(), true; got != want {
-		t.Fatal("ForceRefresh was not called")
+	dial()
+	if err := d.Invalidate(testInstanceURI); err != nil {
+		t.Fatalf("expected Invalidate to succeed, but got error: %v", err)
 	}
-
-	// Verify that the connection info cache was closed (to prevent
-	// further failed refresh operations)
-	if got, want := spy.CloseWasCalled(), true; got != want {
-		t.Fatal("Close was not called")
+	if got := d.Stats().CachedInstances; got != 0 {
+		t.Fatalf("want no cached instances after Invalidate, got = %v", got)
 	}
-
-	// Now verify that bad connection name has been deleted from map.
-	d.lock.RLock()
-	_, ok := d.cache[cn]
-	d.lock.RUnlock()
-	if ok {
-		t.Fatal("bad instance was not removed from the cache")
+	if res := dial(); res.CacheHit {
+		t.Fatal("want Dial after Invalidate to miss the cache")
 	}
 }
 
-type connectionInfoResp struct {
-	info alloydb.ConnectionInfo
-	err  error
+// blockingCache is a ConnectionInfoCache whose ConnectionInfo blocks until
+// release is closed.
+type blockingCache struct {
+	started chan struct{}
+	release chan struct{}
 }
 
-type spyConnectionInfoCache struct {
-	mu                    sync.Mutex
-	connectInfoIndex      int
-	connectInfoCalls      []connectionInfoResp
-	closed                bool
-	forceRefreshWasCalled bool
-	// embed interface to avoid having to implement irrelevant methods
-	connectionInfoCache
+func (c *blockingCache) ConnectionInfo(context.Context) (ConnectionInfo, error) {
+	close(c.started)
+	<-c.release
+	return ConnectionInfo{}, errors.New("connection info cache is closed")
 }
 
-func (s *spyConnectionInfoCache) ConnectionInfo(context.Context) (alloydb.ConnectionInfo, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	res := s.connectInfoCalls[s.connectInfoIndex]
-	s.connectInfoIndex++
-	return res.info, res.err
-}
+func (c *blockingCache) ForceRefresh() {}
 
-func (s *spyConnectionInfoCache) ForceRefresh() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.forceRefreshWasCalled = true
-}
+func (c *blockingCache) Close() error { return nil }
 
-func (s *spyConnectionInfoCache) Close() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.closed = true
-	return nil
-}
+func TestDialerInvalidateDoesNotShareCallWithPreviousCache(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	first := &blockingCache{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	defer close(first.release)
+	var created int
+	factory := func(instance.URI) (ConnectionInfoCache, error) {
+		created++
+		if created == 1 {
+			return first, nil
+		}
+		return &memoryCache{
+			info: oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour)),
+		}, nil
+	}
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithConnectionInfoCacheFactory(factory),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
 
-func (s *spyConnectionInfoCache) CloseWasCalled() bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.closed
+	go d.ConnectionInfo(ctx, testInstanceURI)
+	<-first.started
+	if err := d.Invalidate(testInstanceURI); err != nil {
+		t.Fatalf("expected Invalidate to succeed, but got error: %v", err)
+	}
+
+	// The call on the invalidated cache is still in progress, but the next
+	// caller uses the new cache.
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := d.ConnectionInfo(ctx, testInstanceURI); err != nil {
+		t.Fatalf("expected ConnectionInfo to succeed, but got error: %v", err)
+	}
 }
 
-func (s *spyConnectionInfoCache) ForceRefreshWasCalled() bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.forceRefreshWasCalled
+func TestDialerInvalidateErrors(t *testing.T) {
+	d, err := NewDialer(context.Background(), WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	if err := d.Invalidate("bad-uri"); err == nil {
+		t.Fatal("want error for an invalid instance URI, got nil")
+	}
+	d.Close()
+	if err := d.Invalidate(testInstanceURI); !errors.Is(err, ErrDialerClosed) {
+		t.Fatalf("want = %v, got = %v", ErrDialerClosed, err)
+	}
 }
 
-func TestDialerSupportsOneOffDialFunction(t *testing.T) {
+func TestDialerColdDialDoesNotBlockCachedDial(t *testing.T) {
 	ctx := context.Background()
 	inst := mock.NewFakeInstance(
 		"my-project", "my-region", "my-cluster", "my-instance",
@@ -509,59 +5409,62 @@ func TestDialerSupportsOneOffDialFunction(t *testing.T) {
 			t.Fatalf("%v", err)
 		}
 	}()
-	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url))
 	if err != nil {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
-
-	d, err := NewDialer(ctx,
-		WithDialFunc(func(_ context.Context, _, _ string) (net.Conn, error) {
-			return nil, errors.New("sentinel error")
-		}),
-		WithTokenSource(stubTokenSource{}),
-	)
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
 	if err != nil {
 		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
 	}
 	d.client = c
-	defer func() {
-		if err := d.Close(); err != nil {
-			t.Log(err)
-		}
-		_ = cleanup()
-	}()
-
-	sentinelErr := errors.New("dial func was called")
-	f := func(context.Context, string, string) (net.Conn, error) {
-		return nil, sentinelErr
-	}
-
-	_, err = d.Dial(ctx, testInstanceURI, WithOneOffDialFunc(f))
-	if !errors.Is(err, sentinelErr) {
-		t.Fatal("one-off dial func was not called")
-	}
-}
+	defer d.Close()
 
-func TestDialerCloseReportsFriendlyError(t *testing.T) {
-	d, err := NewDialer(
-		context.Background(),
-		WithTokenSource(stubTokenSource{}),
-	)
+	conn, err := d.Dial(ctx, testInstanceURI)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
 	}
-	_ = d.Close()
+	conn.Close()
 
-	_, err = d.Dial(context.Background(), testInstanceURI)
-	if !errors.Is(err, ErrDialerClosed) {
-		t.Fatalf("want = %v, got = %v", ErrDialerClosed, err)
+	// Replace the key generator with a pool whose only key is still being
+	// generated, so that a dial to a new instance waits on it.
+	key := &pooledKey{ready: make(chan struct{})}
+	d.keyGenerator = &keyGenerator{pool: []*pooledKey{key}}
+	release := sync.OnceFunc(func() {
+		key.err = errors.New("key generation failed")
+		close(key.ready)
+	})
+	defer release()
+	coldErr := make(chan error, 1)
+	go func() {
+		_, err := d.Dial(ctx, "projects/my-project/locations/my-region/"+
+			"clusters/my-cluster/instances/other-instance")
+		coldErr <- err
+	}()
+	for d.keyGenerator.next.Load() == 0 {
+		time.Sleep(time.Millisecond)
 	}
 
-	// Ensure multiple calls to close don't panic
-	_ = d.Close()
+	warmErr := make(chan error, 1)
+	go func() {
+		conn, err := d.Dial(ctx, testInstanceURI)
+		if err == nil {
+			conn.Close()
+		}
+		warmErr <- err
+	}()
+	select {
+	case err := <-warmErr:
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dial of a cached instance blocked on a cold dial")
+	}
 
-	_, err = d.Dial(context.Background(), testInstanceURI)
-	if !errors.Is(err, ErrDialerClosed) {
-		t.Fatalf("want = %v, got = %v", ErrDialerClosed, err)
+	release()
+	if err := <-coldErr; err == nil {
+		t.Fatal("expected cold Dial to fail, but got no error")
 	}
 }
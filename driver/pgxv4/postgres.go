@@ -34,6 +34,12 @@ import (
 // differently configured Dialers. The driver uses pgx/v4 internally.
 // RegisterDriver returns a cleanup function that should be called one the
 // database connection is no longer needed.
+//
+// To use automatic IAM database authentication, pass alloydbconn.WithIAMAuthN
+// and omit the password from the DSN, e.g.,
+// "host=<INSTANCE URI> user=<IAM USER> dbname=<DB> sslmode=disable". The
+// Dialer authenticates the IAM principal with a fresh OAuth2 token on every
+// connection, so no token needs to be injected as the password.
 func RegisterDriver(name string, opts ...alloydbconn.Option) (func() error, error) {
 	d, err := alloydbconn.NewDialer(context.Background(), opts...)
 	if err != nil {
@@ -83,6 +89,7 @@ func (p *pgDriver) dbURI(name string) (string, error) {
 	}
 	instConnName := config.Config.Host // Extract instance connection name
 	config.Config.Host = "localhost"   // Replace it with a default value
+	p.d.WarnIfPasswordIgnored(context.Background(), instConnName, config.Config.Password)
 	config.DialFunc = func(ctx context.Context, _, _ string) (net.Conn, error) {
 		return p.d.Dial(ctx, instConnName)
 	}
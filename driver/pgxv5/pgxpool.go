@@ -0,0 +1,65 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgxv5
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"cloud.google.com/go/alloydbconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PgxpoolConfig returns a pgxpool configuration that connects to the instance
+// at instURI through a new alloydbconn.Dialer created with opts, along with a
+// cleanup function that closes the Dialer. Call the cleanup function once the
+// pool built from the configuration is closed.
+//
+// The dsn configures the database, user, and pool settings as accepted by
+// pgxpool.ParseConfig. Its host, port, and TLS settings are ignored, as the
+// Dialer connects to the instance and secures the connection. The pool
+// otherwise keeps pgxpool's defaults, which may be overridden in the dsn,
+// e.g., with pool_min_conns, or by changing the returned configuration.
+//
+// PgxpoolConfig retrieves the instance's connection info before returning, so
+// that the connections a pool opens at startup, e.g., for MinConns, do not all
+// wait on the same cold refresh.
+func PgxpoolConfig(
+	ctx context.Context, instURI, dsn string, opts ...alloydbconn.Option,
+) (*pgxpool.Config, func() error, error) {
+	noop := func() error { return nil }
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to parse pgx config: %w", err)
+	}
+	d, err := alloydbconn.NewDialer(ctx, opts...)
+	if err != nil {
+		return nil, noop, err
+	}
+	if err := d.WarmupAll(ctx, []string{instURI})[instURI]; err != nil {
+		d.Close()
+		return nil, noop, err
+	}
+	d.WarnIfPasswordIgnored(ctx, instURI, config.ConnConfig.Password)
+	// The Dialer secures the connection, so pgx must neither negotiate TLS
+	// nor fall back to other hosts.
+	config.ConnConfig.TLSConfig = nil
+	config.ConnConfig.Fallbacks = nil
+	config.ConnConfig.DialFunc = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.Dial(ctx, instURI)
+	}
+	return config, d.Close, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgxv5
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"golang.org/x/oauth2"
+)
+
+const testInstanceURI = "projects/my-project/locations/my-region/" +
+	"clusters/my-cluster/instances/my-instance"
+
+// staticConnectionInfo returns static connection info for inst that connects
+// to the local server proxy.
+func staticConnectionInfo(t *testing.T, inst mock.FakeAlloyDBInstance) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := inst.GeneratePEMCertificateChain(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"publicKey": string(pem.EncodeToMemory(&pem.Block{
+			Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey),
+		})),
+		"privateKey": string(pem.EncodeToMemory(&pem.Block{
+			Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})),
+		inst.String(): map[string]interface{}{
+			"ipAddress":           "127.0.0.1",
+			"pemCertificateChain": chain,
+			"caCert":              chain[len(chain)-1],
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestPgxpoolConfig(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	config, cleanup, err := PgxpoolConfig(ctx, testInstanceURI,
+		"user=postgres dbname=mydb sslmode=require pool_min_conns=2",
+		alloydbconn.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{})),
+		alloydbconn.WithStaticConnectionInfo(bytes.NewReader(staticConnectionInfo(t, inst))),
+	)
+	if err != nil {
+		t.Fatalf("expected PgxpoolConfig to succeed, but got error: %v", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("expected cleanup to succeed, but got error: %v", err)
+		}
+	}()
+	if config.MinConns != 2 {
+		t.Fatalf("want MinConns = 2 from the DSN, got = %v", config.MinConns)
+	}
+	if config.ConnConfig.TLSConfig != nil {
+		t.Fatal("want pgx TLS to be disabled")
+	}
+
+	// The dial function connects to the instance regardless of the address
+	// pgx passes.
+	conn, err := config.ConnConfig.DialFunc(ctx, "tcp", "localhost:5432")
+	if err != nil {
+		t.Fatalf("expected DialFunc to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("expected ReadAll to succeed, got error %v", err)
+	}
+	if string(data) != "my-instance" {
+		t.Fatalf("expected known response from the server, but got %v", string(data))
+	}
+}
+
+func TestPgxpoolConfigErrors(t *testing.T) {
+	tcs := []struct {
+		desc string
+		uri  string
+		dsn  string
+	}{
+		{
+			desc: "invalid DSN",
+			uri:  testInstanceURI,
+			dsn:  "pool_min_conns=bogus",
+		},
+		{
+			desc: "invalid instance URI",
+			uri:  "bad-uri",
+			dsn:  "user=postgres",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			config, cleanup, err := PgxpoolConfig(
+				context.Background(), tc.uri, tc.dsn,
+				alloydbconn.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{})),
+			)
+			if err == nil {
+				t.Fatalf("want error, got config = %v", config)
+			}
+			if err := cleanup(); err != nil {
+				t.Fatalf("expected cleanup to succeed, but got error: %v", err)
+			}
+		})
+	}
+}
@@ -226,6 +226,42 @@ func TestDatabaseSQLConnectPGXV4(t *testing.T) {
 	t.Log(tt)
 }
 
+func TestDatabaseSQLAutoIAMAuthNPGXV4(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration tests")
+	}
+
+	cleanup, err := pgxv4.RegisterDriver("alloydb-v4-iam", alloydbconn.WithIAMAuthN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(
+		"alloydb-v4-iam",
+		fmt.Sprintf(
+			// No password is necessary, because the Dialer authenticates the
+			// IAM user.
+			"host=%s user=%s dbname=%s sslmode=disable",
+			alloydbInstanceName, alloydbIAMUser, alloydbDB,
+		),
+	)
+	if err != nil {
+		_ = cleanup()
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+		// best effort
+		_ = cleanup()
+	}()
+
+	var tt time.Time
+	if err := db.QueryRow("SELECT NOW()").Scan(&tt); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(tt)
+}
+
 func TestDatabaseSQLConnectPGXV5(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration tests")
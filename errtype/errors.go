@@ -16,7 +16,15 @@
 // alloydbconn package.
 package errtype
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
 
 type genericError struct {
 	Message  string
@@ -67,6 +75,98 @@ func (e *RefreshError) Error() string {
 
 func (e *RefreshError) Unwrap() error { return e.Err }
 
+var (
+	// ErrInstanceNotFound matches a RefreshError caused by the AlloyDB Admin
+	// API reporting that the instance does not exist.
+	ErrInstanceNotFound = errors.New("instance not found")
+	// ErrPermissionDenied matches a RefreshError caused by the AlloyDB Admin
+	// API reporting that the caller is not permitted to access the instance.
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// Is reports whether the refresh failed for the reason described by target.
+// It supports ErrInstanceNotFound and ErrPermissionDenied, e.g.,
+//
+//	errors.Is(err, errtype.ErrInstanceNotFound)
+func (e *RefreshError) Is(target error) bool {
+	if target != ErrInstanceNotFound && target != ErrPermissionDenied {
+		return false
+	}
+	s, ok := e.Status()
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.NotFound:
+		return target == ErrInstanceNotFound
+	case codes.PermissionDenied:
+		return target == ErrPermissionDenied
+	default:
+		return false
+	}
+}
+
+// HTTPError returns the *googleapi.Error returned by the AlloyDB Admin API
+// that caused the refresh to fail, if any.
+func (e *RefreshError) HTTPError() (*googleapi.Error, bool) {
+	var herr *googleapi.Error
+	if !errors.As(e.Err, &herr) {
+		return nil, false
+	}
+	return herr, true
+}
+
+// Status returns a gRPC status describing the AlloyDB Admin API error that
+// caused the refresh to fail, if any. Callers may use the status code to
+// decide whether to retry (e.g., codes.Unavailable) or to fail fast (e.g.,
+// codes.PermissionDenied or codes.NotFound). When the API error is an HTTP
+// error, its HTTP status code is mapped to the corresponding gRPC code.
+func (e *RefreshError) Status() (*status.Status, bool) {
+	if herr, ok := e.HTTPError(); ok {
+		return status.New(httpCodeToGRPC(herr.Code), herr.Message), true
+	}
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(e.Err, &se) {
+		if s := se.GRPCStatus(); s != nil {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// httpCodeToGRPC maps an HTTP status code to a gRPC code following
+// https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto.
+func httpCodeToGRPC(c int) codes.Code {
+	switch c {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499: // Client Closed Request
+		return codes.Canceled
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Unknown
+	}
+}
+
 // NewDialError initializes a DialError.
 func NewDialError(msg, cn string, err error) *DialError {
 	return &DialError{
@@ -92,3 +192,39 @@ func (e *DialError) Error() string {
 }
 
 func (e *DialError) Unwrap() error { return e.Err }
+
+// NewMetadataExchangeError initializes a MetadataExchangeError.
+func NewMetadataExchangeError(cn, code, msg string) *MetadataExchangeError {
+	return &MetadataExchangeError{
+		genericError: &genericError{
+			Message:  "server rejected the metadata exchange",
+			ConnName: cn,
+		},
+		ResponseCode:  code,
+		ServerMessage: msg,
+	}
+}
+
+// MetadataExchangeError means that the instance's server-side proxy was
+// reachable and completed the TLS handshake, but rejected the metadata
+// exchange that precedes the database protocol (e.g., because the IAM
+// principal is not permitted to connect to the instance).
+type MetadataExchangeError struct {
+	*genericError
+	// ResponseCode is the response code sent by the server (e.g., ERROR).
+	ResponseCode string
+	// ServerMessage is the error message sent by the server and may be empty.
+	ServerMessage string
+}
+
+func (e *MetadataExchangeError) Error() string {
+	if e.ServerMessage == "" {
+		return fmt.Sprintf(
+			"Dial error: %v: response code = %v", e.genericError, e.ResponseCode,
+		)
+	}
+	return fmt.Sprintf(
+		"Dial error: %v: response code = %v: %v",
+		e.genericError, e.ResponseCode, e.ServerMessage,
+	)
+}
@@ -16,9 +16,14 @@ package errtype_test
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 
 	"cloud.google.com/go/alloydbconn/errtype"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestErrorFormatting(t *testing.T) {
@@ -60,6 +65,21 @@ func TestErrorFormatting(t *testing.T) {
 			),
 			want: "Dial error: message (instance URI = \"proj/reg/inst\"): inner-error",
 		},
+		{
+			desc: "Metadata exchange error without server message",
+			err:  errtype.NewMetadataExchangeError("proj/reg/inst", "ERROR", ""),
+			want: "Dial error: server rejected the metadata exchange " +
+				"(instance URI = \"proj/reg/inst\"): response code = ERROR",
+		},
+		{
+			desc: "Metadata exchange error with server message",
+			err: errtype.NewMetadataExchangeError(
+				"proj/reg/inst", "ERROR", "permission denied",
+			),
+			want: "Dial error: server rejected the metadata exchange " +
+				"(instance URI = \"proj/reg/inst\"): response code = ERROR: " +
+				"permission denied",
+		},
 	}
 
 	for _, c := range tc {
@@ -68,3 +88,129 @@ func TestErrorFormatting(t *testing.T) {
 		}
 	}
 }
+
+func TestRefreshErrorStatus(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		err      error
+		wantOK   bool
+		wantCode codes.Code
+	}{
+		{
+			desc: "without an inner error",
+		},
+		{
+			desc: "with a non-API error",
+			err:  errors.New("inner-error"),
+		},
+		{
+			desc:     "with a gRPC status error",
+			err:      status.Error(codes.PermissionDenied, "denied"),
+			wantOK:   true,
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			desc:     "with an HTTP error",
+			err:      &googleapi.Error{Code: http.StatusNotFound, Message: "not found"},
+			wantOK:   true,
+			wantCode: codes.NotFound,
+		},
+		{
+			desc: "with a wrapped HTTP error",
+			err: fmt.Errorf("outer: %w", &googleapi.Error{
+				Code: http.StatusServiceUnavailable, Message: "unavailable",
+			}),
+			wantOK:   true,
+			wantCode: codes.Unavailable,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := errtype.NewRefreshError("error message", "proj/reg/inst", tc.err)
+			s, ok := err.Status()
+			if ok != tc.wantOK {
+				t.Fatalf("want ok = %v, got = %v", tc.wantOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if got := s.Code(); got != tc.wantCode {
+				t.Fatalf("want = %v, got = %v", tc.wantCode, got)
+			}
+		})
+	}
+}
+
+func TestRefreshErrorUnwrapsAPIErrors(t *testing.T) {
+	var err error = errtype.NewRefreshError(
+		"error message", "proj/reg/inst",
+		status.Error(codes.Unavailable, "unavailable"),
+	)
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) {
+		t.Fatalf("want errors.As to find a gRPC status, got = %v", err)
+	}
+	if got := status.Code(err); got != codes.Unavailable {
+		t.Fatalf("want = %v, got = %v", codes.Unavailable, got)
+	}
+
+	herr := &googleapi.Error{Code: http.StatusForbidden, Message: "denied"}
+	err = errtype.NewRefreshError("error message", "proj/reg/inst", herr)
+	var gotHerr *googleapi.Error
+	if !errors.As(err, &gotHerr) || gotHerr != herr {
+		t.Fatalf("want errors.As to find %v, got = %v", herr, gotHerr)
+	}
+	if got, ok := err.(*errtype.RefreshError).HTTPError(); !ok || got != herr {
+		t.Fatalf("want HTTPError = %v, got = %v", herr, got)
+	}
+}
+
+func TestRefreshErrorIs(t *testing.T) {
+	tcs := []struct {
+		desc           string
+		err            error
+		wantNotFound   bool
+		wantPermDenied bool
+	}{
+		{
+			desc: "without an inner error",
+		},
+		{
+			desc:         "with a not found HTTP error",
+			err:          &googleapi.Error{Code: http.StatusNotFound},
+			wantNotFound: true,
+		},
+		{
+			desc:           "with a forbidden HTTP error",
+			err:            &googleapi.Error{Code: http.StatusForbidden},
+			wantPermDenied: true,
+		},
+		{
+			desc:         "with a not found gRPC status error",
+			err:          status.Error(codes.NotFound, "not found"),
+			wantNotFound: true,
+		},
+		{
+			desc:           "with a permission denied gRPC status error",
+			err:            status.Error(codes.PermissionDenied, "denied"),
+			wantPermDenied: true,
+		},
+		{
+			desc: "with an unavailable HTTP error",
+			err:  &googleapi.Error{Code: http.StatusServiceUnavailable},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := fmt.Errorf("outer: %w",
+				errtype.NewRefreshError("error message", "proj/reg/inst", tc.err),
+			)
+			if got := errors.Is(err, errtype.ErrInstanceNotFound); got != tc.wantNotFound {
+				t.Errorf("ErrInstanceNotFound: want = %v, got = %v", tc.wantNotFound, got)
+			}
+			if got := errors.Is(err, errtype.ErrPermissionDenied); got != tc.wantPermDenied {
+				t.Errorf("ErrPermissionDenied: want = %v, got = %v", tc.wantPermDenied, got)
+			}
+		})
+	}
+}
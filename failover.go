@@ -0,0 +1,109 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/internal/alloydb"
+)
+
+// secondaryIPType returns the IP type to race against the preferred IP type
+// when fast failover is enabled. Only private and public IP are considered
+// interchangeable; PSC has no secondary.
+func secondaryIPType(preferred string) (string, bool) {
+	switch preferred {
+	case alloydb.PrivateIP:
+		return alloydb.PublicIP, true
+	case alloydb.PublicIP:
+		return alloydb.PrivateIP, true
+	default:
+		return "", false
+	}
+}
+
+type dialResult struct {
+	conn net.Conn
+	// secondary reports whether the result is from the secondary address.
+	secondary bool
+	err       error
+}
+
+// dialFastFailover dials the primary address and, if it has not connected
+// after delay, races a dial to the secondary address. A failure to dial the
+// primary starts the secondary dial immediately. The first successful
+// connection is returned along with its address, and the other connection, if
+// any, is closed. If both dials fail, the primary's error is returned.
+func dialFastFailover(
+	ctx context.Context,
+	f func(ctx context.Context, network, addr string) (net.Conn, error),
+	network, primary, secondary string,
+	delay time.Duration,
+) (net.Conn, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan dialResult, 2)
+	var started, outstanding int
+	dial := func(addr string, isSecondary bool) {
+		started++
+		outstanding++
+		go func() {
+			conn, err := f(ctx, network, net.JoinHostPort(addr, DefaultServerProxyPort))
+			ch <- dialResult{conn: conn, secondary: isSecondary, err: err}
+		}()
+	}
+	dial(primary, false)
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	var primaryErr error
+	for {
+		select {
+		case <-t.C:
+			if started == 1 {
+				dial(secondary, true)
+			}
+		case r := <-ch:
+			outstanding--
+			if r.err == nil {
+				// Close the losing connection, if it succeeds at all.
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if l := <-ch; l.conn != nil {
+							_ = l.conn.Close()
+						}
+					}
+				}(outstanding)
+				if r.secondary {
+					return r.conn, secondary, nil
+				}
+				return r.conn, primary, nil
+			}
+			if !r.secondary {
+				primaryErr = r.err
+			}
+			if started == 1 {
+				dial(secondary, true)
+				continue
+			}
+			if outstanding == 0 {
+				return nil, "", primaryErr
+			}
+		}
+	}
+}
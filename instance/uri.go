@@ -0,0 +1,149 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package instance provides functions for parsing AlloyDB instance URIs.
+package instance
+
+import (
+	"fmt"
+	"regexp"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+)
+
+var (
+	// Instance URI is in the format:
+	// 'projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>'
+	// Additionally, we have to support legacy "domain-scoped" projects
+	// (e.g. "google.com:PROJECT")
+	uriRegex = regexp.MustCompile(
+		"^projects/([^:/]+(?::[^:/]+)?)/locations/([^:/]+)/clusters/([^:/]+)/instances/([^:/]+)$",
+	)
+)
+
+// URI represents an AlloyDB instance.
+type URI struct {
+	project string
+	region  string
+	cluster string
+	name    string
+}
+
+// Project returns the project ID of the instance. For legacy domain-scoped
+// projects, the domain is included (e.g., "google.com:PROJECT").
+func (u URI) Project() string { return u.project }
+
+// Region returns the region of the instance.
+func (u URI) Region() string { return u.region }
+
+// Cluster returns the name of the cluster the instance belongs to.
+func (u URI) Cluster() string { return u.cluster }
+
+// Name returns the name of the instance.
+func (u URI) Name() string { return u.name }
+
+// URI returns the full URI specifying an instance.
+func (u URI) URI() string {
+	return fmt.Sprintf(
+		"projects/%s/locations/%s/clusters/%s/instances/%s",
+		u.project, u.region, u.cluster, u.name,
+	)
+}
+
+// String returns a short-hand representation of an instance URI.
+func (u URI) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s", u.project, u.region, u.cluster, u.name)
+}
+
+// ParseURI initializes a new URI struct. If the provided string is not a
+// valid instance URI, ParseURI returns a zero URI and an errtype.ConfigError.
+//
+// The whole string must be a full instance URI, i.e.,
+// projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>,
+// with no leading or trailing text. Short forms such as
+// "proj.reg.clust.name" or "google.com:proj.reg.clust.name" are rejected.
+func ParseURI(cn string) (URI, error) {
+	m := uriRegex.FindStringSubmatch(cn)
+	if m == nil {
+		err := errtype.NewConfigError(
+			"invalid instance URI, expected projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>",
+			cn,
+		)
+		return URI{}, err
+	}
+
+	return URI{
+		project: m[1],
+		region:  m[2],
+		cluster: m[3],
+		name:    m[4],
+	}, nil
+}
+
+var (
+	// Cluster URI is in the format:
+	// 'projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>'
+	clusterURIRegex = regexp.MustCompile(
+		"^projects/([^:/]+(?::[^:/]+)?)/locations/([^:/]+)/clusters/([^:/]+)$",
+	)
+)
+
+// ClusterURI represents an AlloyDB cluster.
+type ClusterURI struct {
+	project string
+	region  string
+	cluster string
+}
+
+// Project returns the project ID of the cluster.
+func (c ClusterURI) Project() string { return c.project }
+
+// Region returns the region of the cluster.
+func (c ClusterURI) Region() string { return c.region }
+
+// Cluster returns the name of the cluster.
+func (c ClusterURI) Cluster() string { return c.cluster }
+
+// URI returns the full URI specifying a cluster.
+func (c ClusterURI) URI() string {
+	return fmt.Sprintf(
+		"projects/%s/locations/%s/clusters/%s",
+		c.project, c.region, c.cluster,
+	)
+}
+
+// String returns a short-hand representation of a cluster URI.
+func (c ClusterURI) String() string {
+	return fmt.Sprintf("%s/%s/%s", c.project, c.region, c.cluster)
+}
+
+// ParseClusterURI initializes a new ClusterURI struct. If the provided string
+// is not a valid cluster URI, ParseClusterURI returns a zero ClusterURI and an
+// errtype.ConfigError.
+func ParseClusterURI(cn string) (ClusterURI, error) {
+	m := clusterURIRegex.FindStringSubmatch(cn)
+	if m == nil {
+		err := errtype.NewConfigError(
+			"invalid cluster URI, expected projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>",
+			cn,
+		)
+		return ClusterURI{}, err
+	}
+
+	return ClusterURI{
+		project: m[1],
+		region:  m[2],
+		cluster: m[3],
+	}, nil
+}
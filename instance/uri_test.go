@@ -0,0 +1,213 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instance
+
+import (
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/alloydbconn/errtype"
+)
+
+func TestParseURI(t *testing.T) {
+	tcs := []struct {
+		desc string
+		in   string
+		want URI
+	}{
+		{
+			desc: "vanilla instance URI",
+			in:   "projects/proj/locations/reg/clusters/clust/instances/name",
+			want: URI{
+				project: "proj",
+				region:  "reg",
+				cluster: "clust",
+				name:    "name",
+			},
+		},
+		{
+			desc: "with legacy domain-scoped project",
+			in:   "projects/google.com:proj/locations/reg/clusters/clust/instances/name",
+			want: URI{
+				project: "google.com:proj",
+				region:  "reg",
+				cluster: "clust",
+				name:    "name",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseURI(tc.in)
+			if err != nil {
+				t.Fatalf("want no error, got = %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseURIErrors(t *testing.T) {
+	tcs := []struct {
+		desc string
+		in   string
+	}{
+		{
+			desc: "malformatted",
+			in:   "not-correct",
+		},
+		{
+			desc: "missing project",
+			in:   "reg:clust:name",
+		},
+		{
+			desc: "missing cluster",
+			in:   "proj:reg:name",
+		},
+		{
+			desc: "empty",
+			in:   "::::",
+		},
+		{
+			desc: "short-form with domain-scoped project",
+			in:   "google.com:proj.reg.clust.name",
+		},
+		{
+			desc: "leading garbage",
+			in:   "xprojects/proj/locations/reg/clusters/clust/instances/name",
+		},
+		{
+			desc: "trailing path segment",
+			in:   "projects/proj/locations/reg/clusters/clust/instances/name/extra",
+		},
+		{
+			desc: "slash inside region",
+			in:   "projects/proj/locations/a/b/clusters/clust/instances/name",
+		},
+		{
+			desc: "multiple domain scopes",
+			in:   "projects/a:b:c/locations/reg/clusters/clust/instances/name",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseURI(tc.in)
+			var cErr *errtype.ConfigError
+			if !errors.As(err, &cErr) {
+				t.Fatalf("want = %T, got = %v", cErr, err)
+			}
+			if got != (URI{}) {
+				t.Fatalf("want zero URI on error, got = %v", got)
+			}
+		})
+	}
+}
+
+func FuzzParseURI(f *testing.F) {
+	f.Add("projects/proj/locations/reg/clusters/clust/instances/name")
+	f.Add("projects/google.com:proj/locations/reg/clusters/clust/instances/name")
+	f.Add("google.com:proj.reg.clust.name")
+	f.Add("proj.reg.clust.name")
+	f.Add("projects//locations//clusters//instances/")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, in string) {
+		got, err := ParseURI(in)
+		if err != nil {
+			var cErr *errtype.ConfigError
+			if !errors.As(err, &cErr) {
+				t.Fatalf("ParseURI(%q): want = %T, got = %v", in, cErr, err)
+			}
+			if got != (URI{}) {
+				t.Fatalf("ParseURI(%q): want zero URI on error, got = %v", in, got)
+			}
+			return
+		}
+		if got.Project() == "" || got.Region() == "" ||
+			got.Cluster() == "" || got.Name() == "" {
+			t.Fatalf("ParseURI(%q): want all fields set, got = %#v", in, got)
+		}
+		if got.URI() != in {
+			t.Fatalf("ParseURI(%q).URI() = %q, want round trip", in, got.URI())
+		}
+	})
+}
+
+func TestParseClusterURI(t *testing.T) {
+	tcs := []struct {
+		desc string
+		in   string
+		want ClusterURI
+	}{
+		{
+			desc: "vanilla cluster URI",
+			in:   "projects/proj/locations/reg/clusters/clust",
+			want: ClusterURI{project: "proj", region: "reg", cluster: "clust"},
+		},
+		{
+			desc: "with legacy domain-scoped project",
+			in:   "projects/google.com:proj/locations/reg/clusters/clust",
+			want: ClusterURI{
+				project: "google.com:proj", region: "reg", cluster: "clust",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseClusterURI(tc.in)
+			if err != nil {
+				t.Fatalf("want no error, got = %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseClusterURIErrors(t *testing.T) {
+	tcs := []struct {
+		desc string
+		in   string
+	}{
+		{
+			desc: "malformatted",
+			in:   "not-correct",
+		},
+		{
+			desc: "instance URI",
+			in:   "projects/proj/locations/reg/clusters/clust/instances/name",
+		},
+		{
+			desc: "missing cluster",
+			in:   "projects/proj/locations/reg/clusters/",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := ParseClusterURI(tc.in)
+			var cErr *errtype.ConfigError
+			if !errors.As(err, &cErr) {
+				t.Fatalf("want = %T, got = %v", cErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydb
+
+import (
+	"context"
+	"errors"
+
+	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
+	"cloud.google.com/go/alloydb/apiv1alpha/alloydbpb"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
+	"cloud.google.com/go/alloydbconn/internal/tel"
+	"google.golang.org/api/iterator"
+)
+
+// FetchPrimaryInstance uses the AlloyDB Admin API's list method to find the
+// primary instance of a cluster.
+func FetchPrimaryInstance(
+	ctx context.Context, cl *alloydbadmin.AlloyDBAdminClient, c instance.ClusterURI,
+) (u instance.URI, err error) {
+	var end tel.EndSpanFunc
+	ctx, end = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.FetchPrimaryInstance")
+	defer func() { end(err) }()
+	insts, err := listInstances(ctx, cl, c, alloydbpb.Instance_PRIMARY)
+	if err != nil {
+		return instance.URI{}, err
+	}
+	if len(insts) == 0 {
+		return instance.URI{}, errtype.NewConfigError(
+			"cluster has no primary instance", c.String(),
+		)
+	}
+	return insts[0], nil
+}
+
+// FetchReadPoolInstances uses the AlloyDB Admin API's list method to find the
+// read pool instances of a cluster.
+func FetchReadPoolInstances(
+	ctx context.Context, cl *alloydbadmin.AlloyDBAdminClient, c instance.ClusterURI,
+) (us []instance.URI, err error) {
+	var end tel.EndSpanFunc
+	ctx, end = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.FetchReadPoolInstances")
+	defer func() { end(err) }()
+	insts, err := listInstances(ctx, cl, c, alloydbpb.Instance_READ_POOL)
+	if err != nil {
+		return nil, err
+	}
+	if len(insts) == 0 {
+		return nil, errtype.NewConfigError(
+			"cluster has no read pool instances", c.String(),
+		)
+	}
+	return insts, nil
+}
+
+// listInstances returns the URIs of all instances of the provided type in a
+// cluster.
+func listInstances(
+	ctx context.Context, cl *alloydbadmin.AlloyDBAdminClient,
+	c instance.ClusterURI, t alloydbpb.Instance_InstanceType,
+) ([]instance.URI, error) {
+	it := cl.ListInstances(ctx, &alloydbpb.ListInstancesRequest{
+		Parent: c.URI(),
+	})
+	var insts []instance.URI
+	for {
+		inst, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, errtype.NewRefreshError(
+				"failed to list cluster instances", c.String(), err,
+			)
+		}
+		if inst.GetInstanceType() != t {
+			continue
+		}
+		u, err := instance.ParseURI(inst.GetName())
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, u)
+	}
+	return insts, nil
+}
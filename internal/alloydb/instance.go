@@ -17,14 +17,14 @@ package alloydb
 import (
 	"context"
 	"crypto/rsa"
-	"fmt"
-	"regexp"
 	"sync"
 	"time"
 
 	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
 	"cloud.google.com/go/alloydbconn/debug"
 	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
+	"cloud.google.com/go/alloydbconn/internal/tel"
 	"golang.org/x/time/rate"
 )
 
@@ -44,57 +44,15 @@ const (
 
 	// refreshBurst is the initial burst allowed by the rate limiter.
 	refreshBurst = 2
-)
-
-var (
-	// Instance URI is in the format:
-	// 'projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>'
-	// Additionally, we have to support legacy "domain-scoped" projects
-	// (e.g. "google.com:PROJECT")
-	instURIRegex = regexp.MustCompile("projects/([^:]+(:[^:]+)?)/locations/([^:]+)/clusters/([^:]+)/instances/([^:]+)")
-)
 
-// InstanceURI represents an AlloyDB instance.
-type InstanceURI struct {
-	project string
-	region  string
-	cluster string
-	name    string
-}
-
-// URI returns the full URI specifying an instance.
-func (i *InstanceURI) URI() string {
-	return fmt.Sprintf(
-		"projects/%s/locations/%s/clusters/%s/instances/%s",
-		i.project, i.region, i.cluster, i.name,
-	)
-}
+	// ClientCertDuration is the default lifetime requested for client
+	// certificates.
+	ClientCertDuration = time.Hour
 
-// String returns a short-hand representation of an instance URI.
-func (i *InstanceURI) String() string {
-	return fmt.Sprintf("%s/%s/%s/%s", i.project, i.region, i.cluster, i.name)
-}
-
-// ParseInstURI initializes a new InstanceURI struct.
-func ParseInstURI(cn string) (InstanceURI, error) {
-	b := []byte(cn)
-	m := instURIRegex.FindSubmatch(b)
-	if m == nil {
-		err := errtype.NewConfigError(
-			"invalid instance URI, expected projects/<PROJECT>/locations/<REGION>/clusters/<CLUSTER>/instances/<INSTANCE>",
-			cn,
-		)
-		return InstanceURI{}, err
-	}
-
-	c := InstanceURI{
-		project: string(m[1]),
-		region:  string(m[3]),
-		cluster: string(m[4]),
-		name:    string(m[5]),
-	}
-	return c, nil
-}
+	// MaxClientCertDuration is the longest lifetime the AlloyDB Admin API
+	// accepts for client certificates.
+	MaxClientCertDuration = 24 * time.Hour
+)
 
 // refreshOperation is a pending result of a refresh operation of data used to
 // connect securely. It should only be initialized by the Instance struct as
@@ -104,7 +62,9 @@ type refreshOperation struct {
 	err    error
 
 	// timer that triggers refresh, can be used to cancel.
-	timer *time.Timer
+	timer timer
+	// scheduled is the time at which the timer is set to fire.
+	scheduled time.Time
 	// indicates the struct is ready to read from
 	ready chan struct{}
 }
@@ -117,14 +77,14 @@ func (r *refreshOperation) cancel() bool {
 }
 
 // IsValid returns true if this result is complete, successful, and is still
-// valid.
-func (r *refreshOperation) isValid() bool {
+// valid at now.
+func (r *refreshOperation) isValid(now time.Time) bool {
 	// verify the result has finished running
 	select {
 	default:
 		return false
 	case <-r.ready:
-		if r.err != nil || time.Now().After(r.result.Expiration) {
+		if r.err != nil || now.After(r.result.Expiration) {
 			return false
 		}
 		return true
@@ -134,9 +94,10 @@ func (r *refreshOperation) isValid() bool {
 // RefreshAheadCache manages the information used to connect to the AlloyDB instance by
 // periodically calling the AlloyDB Admin API. It automatically refreshes the
 // required information approximately 4 minutes before the previous certificate
-// expires (every ~56 minutes).
+// expires (every ~56 minutes for one hour certificates), or when the window
+// configured with WithRefreshAheadWindow remains before expiration.
 type RefreshAheadCache struct {
-	instanceURI InstanceURI
+	instanceURI instance.URI
 	logger      debug.ContextLogger
 	// refreshTimeout sets the maximum duration a refresh cycle can run
 	// for.
@@ -144,6 +105,8 @@ type RefreshAheadCache struct {
 	// l controls the rate at which refresh cycles are run.
 	l *rate.Limiter
 	r adminAPIClient
+	// clock is the source of time for scheduling refreshes.
+	clock clock
 
 	resultGuard sync.RWMutex
 	// cur represents the current refreshOperation that will be used to
@@ -153,30 +116,42 @@ type RefreshAheadCache struct {
 	// next represents a future or ongoing refreshOperation. Once complete,
 	// it will replace cur and schedule a replacement to occur.
 	next *refreshOperation
+	// lastRefresh is the time of the most recent successful refresh.
+	lastRefresh time.Time
+	// lastFailure is the time of the most recent failed refresh.
+	lastFailure time.Time
+	// failures is the number of consecutive failed refreshes.
+	failures int
 
 	// ctx is the default ctx for refresh operations. Canceling it prevents
 	// new refresh operations from being triggered.
 	ctx    context.Context
 	cancel context.CancelFunc
+	// running tracks the refresh operation in progress, if any, so that
+	// Close can wait for it to stop using the Admin API client.
+	running sync.WaitGroup
 }
 
 // NewRefreshAheadCache initializes a new cache that proactively refreshes the
 // caches connection info.
 func NewRefreshAheadCache(
-	instance InstanceURI,
+	inst instance.URI,
 	l debug.ContextLogger,
 	client *alloydbadmin.AlloyDBAdminClient,
 	key *rsa.PrivateKey,
 	refreshTimeout time.Duration,
 	dialerID string,
 	disableMetadataExchange bool,
+	opts ...Option,
 ) *RefreshAheadCache {
 	ctx, cancel := context.WithCancel(context.Background())
+	r := newAdminAPIClient(client, key, dialerID, disableMetadataExchange, opts...)
 	i := &RefreshAheadCache{
-		instanceURI:    instance,
+		instanceURI:    inst,
 		logger:         l,
 		l:              rate.NewLimiter(rate.Every(refreshInterval), refreshBurst),
-		r:              newAdminAPIClient(client, key, dialerID, disableMetadataExchange),
+		r:              r,
+		clock:          r.clock,
 		refreshTimeout: refreshTimeout,
 		ctx:            ctx,
 		cancel:         cancel,
@@ -191,13 +166,15 @@ func NewRefreshAheadCache(
 }
 
 // Close closes the instance; it stops the refresh cycle and prevents it from
-// making additional calls to the AlloyDB Admin API.
+// making additional calls to the AlloyDB Admin API. Close waits for a refresh
+// operation in progress to stop.
 func (i *RefreshAheadCache) Close() error {
 	i.resultGuard.Lock()
-	defer i.resultGuard.Unlock()
 	i.cancel()
 	i.cur.cancel()
 	i.next.cancel()
+	i.resultGuard.Unlock()
+	i.running.Wait()
 	return nil
 }
 
@@ -233,25 +210,60 @@ func (i *RefreshAheadCache) ForceRefresh() {
 	}
 	// block all sequential connection attempts on the next refresh operation
 	// if current is invalid
-	if !i.cur.isValid() {
+	if !i.cur.isValid(i.clock.Now()) {
 		i.cur = i.next
 	}
 }
 
-// refreshDuration returns the duration to wait before starting the next
-// refresh. Usually that duration will be half of the time until certificate
-// expiration.
-func refreshDuration(now, certExpiry time.Time) time.Duration {
-	d := certExpiry.Sub(now)
-	if d < time.Hour {
-		// Something is wrong with the certification, refresh now.
-		if d < refreshBuffer {
-			return 0
+// RefreshStatus reports the times of the last successful and failed
+// refreshes, the expiration of the current certificate, and when the next refresh is
+// scheduled. Calling RefreshStatus does not trigger a refresh.
+func (i *RefreshAheadCache) RefreshStatus() RefreshStatus {
+	i.resultGuard.RLock()
+	defer i.resultGuard.RUnlock()
+	s := RefreshStatus{
+		LastRefresh: i.lastRefresh,
+		LastFailure: i.lastFailure,
+		NextRefresh: i.next.scheduled,
+	}
+	select {
+	case <-i.cur.ready:
+		if i.cur.err == nil {
+			s.Expiration = i.cur.result.Expiration
 		}
-		// Otherwise wait until 4 minutes before expiration for next refresh cycle.
-		return d - refreshBuffer
+	default:
 	}
-	return d / 2
+	return s
+}
+
+// refreshDuration returns the duration to wait before starting the next
+// refresh. The next refresh starts refreshBuffer before the certificate
+// expires. For certificates valid for longer than one hour, the margin grows
+// with the certificate's lifetime (from notBefore to notAfter). If window is
+// positive and shorter than the lifetime, the next refresh instead starts when
+// window remains before expiration. If the refresh time has already passed,
+// the refresh starts immediately.
+func refreshDuration(now, notBefore, notAfter time.Time, window time.Duration) time.Duration {
+	lifetime := notAfter.Sub(notBefore)
+	margin := max(refreshBuffer, lifetime/15)
+	if window > 0 && window < lifetime {
+		margin = window
+	}
+	d := notAfter.Sub(now) - margin
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// certValidity returns the validity period of the client certificate in ci.
+// If the parsed certificate is unavailable, the validity period is assumed to
+// start now.
+func certValidity(ci ConnectionInfo, now time.Time) (notBefore, notAfter time.Time) {
+	if leaf := ci.ClientCert.Leaf; leaf != nil {
+		return leaf.NotBefore, ci.Expiration
+	}
+	return now, ci.Expiration
 }
 
 // scheduleRefresh schedules a refresh operation to be triggered after a given
@@ -260,9 +272,12 @@ func refreshDuration(now, certExpiry time.Time) time.Duration {
 func (i *RefreshAheadCache) scheduleRefresh(d time.Duration) *refreshOperation {
 	r := &refreshOperation{}
 	r.ready = make(chan struct{})
-	r.timer = time.AfterFunc(d, func() {
+	r.scheduled = i.clock.Now().Add(d)
+	r.timer = i.clock.AfterFunc(d, func() {
 		// instance has been closed, don't schedule anything
+		i.resultGuard.Lock()
 		if err := i.ctx.Err(); err != nil {
+			i.resultGuard.Unlock()
 			i.logger.Debugf(
 				context.Background(),
 				"[%v] Instance is closed, stopping refresh operations",
@@ -272,6 +287,10 @@ func (i *RefreshAheadCache) scheduleRefresh(d time.Duration) *refreshOperation {
 			close(r.ready)
 			return
 		}
+		i.running.Add(1)
+		i.resultGuard.Unlock()
+		defer i.running.Done()
+		started := i.clock.Now()
 		i.logger.Debugf(
 			context.Background(),
 			"[%v] Connection info refresh operation started",
@@ -295,7 +314,12 @@ func (i *RefreshAheadCache) scheduleRefresh(d time.Duration) *refreshOperation {
 				r.err,
 			)
 		} else {
+			start := i.clock.Now()
 			r.result, r.err = i.r.connectionInfo(i.ctx, i.instanceURI)
+			go tel.RecordRefreshLatency(
+				context.Background(), i.instanceURI.String(), i.r.dialerID,
+				tel.RefreshAheadType, i.clock.Now().Sub(start).Milliseconds(), r.err,
+			)
 			i.logger.Debugf(
 				ctx,
 				"[%v] Connection info refresh operation complete",
@@ -309,12 +333,42 @@ func (i *RefreshAheadCache) scheduleRefresh(d time.Duration) *refreshOperation {
 			)
 		}
 
-		close(r.ready)
-
 		// Once the refresh is complete, update "current" with working
-		// result and schedule a new refresh
+		// result and schedule a new refresh. Mark the operation as ready
+		// while holding the lock so that callers never observe a completed
+		// operation before the cache's state reflects it.
 		i.resultGuard.Lock()
 		defer i.resultGuard.Unlock()
+		defer func() { i.r.notifyRefresh(r.err) }()
+		// next is the time of the next scheduled refresh. It stays zero when
+		// refreshes stop.
+		var next time.Time
+		defer func() { i.logTimeline(ctx, r, started, next) }()
+		close(r.ready)
+
+		if r.err != nil {
+			i.failures++
+			i.lastFailure = i.clock.Now()
+		} else {
+			i.failures = 0
+		}
+		if r.err != nil && i.r.maxRefreshFailures > 0 &&
+			i.failures >= i.r.maxRefreshFailures {
+			i.logger.Debugf(
+				ctx,
+				"[%v] Connection info refresh failed %d consecutive times, "+
+					"stopping refresh operations",
+				i.instanceURI.String(),
+				i.failures,
+			)
+			// Surface the error to callers and leave no refresh pending.
+			i.cur = r
+			i.next = r
+			if i.r.onRefreshStop != nil {
+				go i.r.onRefreshStop(r.err)
+			}
+			return
+		}
 
 		// if failed, scheduled the next refresh immediately
 		if r.err != nil {
@@ -324,13 +378,14 @@ func (i *RefreshAheadCache) scheduleRefresh(d time.Duration) *refreshOperation {
 				i.instanceURI.String(),
 			)
 			i.next = i.scheduleRefresh(0)
+			next = i.next.scheduled
 			// If the latest result is bad, avoid replacing the
 			// used result while it's still valid and potentially
 			// able to provide successful connections. TODO: This
 			// means that errors while the current result is still
 			// valid are suppressed. We should try to surface
 			// errors in a more meaningful way.
-			if !i.cur.isValid() {
+			if !i.cur.isValid(i.clock.Now()) {
 				i.cur = r
 			}
 			return
@@ -338,15 +393,47 @@ func (i *RefreshAheadCache) scheduleRefresh(d time.Duration) *refreshOperation {
 		// Update the current results, and schedule the next refresh in
 		// the future
 		i.cur = r
-		t := refreshDuration(time.Now(), i.cur.result.Expiration)
+		now := i.clock.Now()
+		i.lastRefresh = now
+		notBefore, notAfter := certValidity(i.cur.result, now)
+		t := refreshDuration(now, notBefore, notAfter, i.r.refreshAheadWindow)
 		i.logger.Debugf(
 			ctx,
 			"[%v] Connection info refresh operation scheduled at %v (now + %v)",
 			i.instanceURI.String(),
-			time.Now().Add(t).UTC().Format(time.RFC3339),
+			now.Add(t).UTC().Format(time.RFC3339),
 			t.Round(time.Minute),
 		)
 		i.next = i.scheduleRefresh(t)
+		next = i.next.scheduled
 	})
 	return r
 }
+
+// logTimeline logs a single line summarizing the refresh operation r: when it
+// was scheduled and started, how long it took, the expiration of the new
+// certificate, and when the next refresh is scheduled.
+func (i *RefreshAheadCache) logTimeline(
+	ctx context.Context, r *refreshOperation, started, next time.Time,
+) {
+	expiry, nextRefresh := "none", "none"
+	if r.err == nil {
+		expiry = r.result.Expiration.UTC().Format(time.RFC3339)
+	}
+	if !next.IsZero() {
+		nextRefresh = next.UTC().Format(time.RFC3339)
+	}
+	i.logger.Debugf(
+		ctx,
+		"[%v] Refresh timeline: scheduled = %v, started = %v (delay = %v), "+
+			"duration = %v, expiry = %v, next = %v, err = %v",
+		i.instanceURI.String(),
+		r.scheduled.UTC().Format(time.RFC3339Nano),
+		started.UTC().Format(time.RFC3339Nano),
+		started.Sub(r.scheduled),
+		i.clock.Now().Sub(started),
+		expiry,
+		nextRefresh,
+		r.err,
+	)
+}
@@ -19,11 +19,16 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
 	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
 	"cloud.google.com/go/alloydbconn/internal/mock"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/option"
@@ -33,6 +38,31 @@ type nullLogger struct{}
 
 func (nullLogger) Debugf(context.Context, string, ...interface{}) {}
 
+// spyLogger records the messages it logs.
+type spyLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *spyLogger) Debugf(_ context.Context, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, fmt.Sprintf(format, args...))
+}
+
+// find returns the logged messages that contain substr.
+func (l *spyLogger) find(substr string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var found []string
+	for _, m := range l.msgs {
+		if strings.Contains(m, substr) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
 // genRSAKey generates an RSA key used for test.
 func genRSAKey() *rsa.PrivateKey {
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -45,80 +75,6 @@ func genRSAKey() *rsa.PrivateKey {
 // rsaKey is used for test only.
 var rsaKey = genRSAKey()
 
-func TestParseInstURI(t *testing.T) {
-	tcs := []struct {
-		desc string
-		in   string
-		want InstanceURI
-	}{
-		{
-			desc: "vanilla instance URI",
-			in:   "projects/proj/locations/reg/clusters/clust/instances/name",
-			want: InstanceURI{
-				project: "proj",
-				region:  "reg",
-				cluster: "clust",
-				name:    "name",
-			},
-		},
-		{
-			desc: "with legacy domain-scoped project",
-			in:   "projects/google.com:proj/locations/reg/clusters/clust/instances/name",
-			want: InstanceURI{
-				project: "google.com:proj",
-				region:  "reg",
-				cluster: "clust",
-				name:    "name",
-			},
-		},
-	}
-
-	for _, tc := range tcs {
-		t.Run(tc.desc, func(t *testing.T) {
-			got, err := ParseInstURI(tc.in)
-			if err != nil {
-				t.Fatalf("want no error, got = %v", err)
-			}
-			if got != tc.want {
-				t.Fatalf("want = %v, got = %v", got, tc.want)
-			}
-		})
-	}
-}
-
-func TestParseConnNameErrors(t *testing.T) {
-	tcs := []struct {
-		desc string
-		in   string
-	}{
-		{
-			desc: "malformatted",
-			in:   "not-correct",
-		},
-		{
-			desc: "missing project",
-			in:   "reg:clust:name",
-		},
-		{
-			desc: "missing cluster",
-			in:   "proj:reg:name",
-		},
-		{
-			desc: "empty",
-			in:   "::::",
-		},
-	}
-
-	for _, tc := range tcs {
-		t.Run(tc.desc, func(t *testing.T) {
-			_, err := ParseInstURI(tc.in)
-			if err == nil {
-				t.Fatal("want error, got nil")
-			}
-		})
-	}
-}
-
 type stubTokenSource struct{}
 
 func (stubTokenSource) Token() (*oauth2.Token, error) {
@@ -192,8 +148,8 @@ func TestConnectionInfo(t *testing.T) {
 
 }
 
-func testInstanceURI() InstanceURI {
-	i, _ := ParseInstURI("projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
+func testInstanceURI() instance.URI {
+	i, _ := instance.ParseURI("projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance")
 	return i
 }
 
@@ -216,6 +172,9 @@ func TestConnectInfoErrors(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to initialize Instance: %v", err)
 	}
+	// Failed refreshes are rescheduled immediately, so close the cache to
+	// stop the background refresh cycle once the test completes.
+	defer i.Close()
 
 	_, err = i.ConnectionInfo(ctx)
 	var wantErr *errtype.DialError
@@ -260,42 +219,86 @@ func TestClose(t *testing.T) {
 	}
 }
 
+func TestCloseWaitsForRefresh(t *testing.T) {
+	tr := &blockingTransport{started: make(chan struct{}, 1)}
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		context.Background(),
+		option.WithHTTPClient(&http.Client{Transport: tr}),
+		option.WithEndpoint("http://127.0.0.1"),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	i := NewRefreshAheadCache(
+		testInstanceURI(),
+		nullLogger{},
+		c, rsaKey, time.Minute, "dialer-id",
+		false,
+	)
+	// Wait for the initial refresh to reach the Admin API.
+	<-tr.started
+	i.resultGuard.RLock()
+	r := i.cur
+	i.resultGuard.RUnlock()
+
+	i.Close()
+	// The refresh has stopped, so it is safe to close the client.
+	select {
+	case <-r.ready:
+	default:
+		t.Fatal("want Close to wait for the refresh in progress")
+	}
+	c.Close()
+}
+
 func TestRefreshDuration(t *testing.T) {
 	now := time.Now()
 	tcs := []struct {
-		desc   string
-		expiry time.Time
-		want   time.Duration
+		desc      string
+		notBefore time.Time
+		notAfter  time.Time
+		want      time.Duration
 	}{
 		{
-			desc:   "when expiration is greater than 1 hour",
-			expiry: now.Add(4 * time.Hour),
-			want:   2 * time.Hour,
+			desc:      "when a 5 minute certificate was just issued",
+			notBefore: now,
+			notAfter:  now.Add(5 * time.Minute),
+			want:      time.Minute,
 		},
 		{
-			desc:   "when expiration is equal to 1 hour",
-			expiry: now.Add(time.Hour),
-			want:   30 * time.Minute,
+			desc:      "when a 1 hour certificate was just issued",
+			notBefore: now,
+			notAfter:  now.Add(time.Hour),
+			want:      56 * time.Minute,
 		},
 		{
-			desc:   "when expiration is less than 1 hour, but greater than 4 minutes",
-			expiry: now.Add(5 * time.Minute),
-			want:   time.Minute,
+			desc:      "when a 24 hour certificate was just issued",
+			notBefore: now,
+			notAfter:  now.Add(24 * time.Hour),
+			want:      24*time.Hour - 96*time.Minute,
 		},
 		{
-			desc:   "when expiration is less than 4 minutes",
-			expiry: now.Add(3 * time.Minute),
-			want:   0,
+			desc:      "when part of the lifetime has elapsed",
+			notBefore: now.Add(-10 * time.Minute),
+			notAfter:  now.Add(50 * time.Minute),
+			want:      46 * time.Minute,
 		},
 		{
-			desc:   "when expiration is now",
-			expiry: now,
-			want:   0,
+			desc:      "when the refresh buffer has started",
+			notBefore: now.Add(-58 * time.Minute),
+			notAfter:  now.Add(2 * time.Minute),
+			want:      0,
+		},
+		{
+			desc:      "when expiration is now",
+			notBefore: now.Add(-time.Hour),
+			notAfter:  now,
+			want:      0,
 		},
 	}
 	for _, tc := range tcs {
 		t.Run(tc.desc, func(t *testing.T) {
-			got := refreshDuration(now, tc.expiry)
+			got := refreshDuration(now, tc.notBefore, tc.notAfter, 0)
 			// round to the second to remove millisecond differences
 			if got.Round(time.Second) != tc.want {
 				t.Fatalf("time until refresh: want = %v, got = %v", tc.want, got)
@@ -303,3 +306,292 @@ func TestRefreshDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshDurationKeepsBufferForOneHourCertificates(t *testing.T) {
+	// One hour certificates are refreshed refreshBuffer before they expire,
+	// however much of their lifetime has elapsed.
+	now := time.Now()
+	for _, elapsed := range []time.Duration{
+		0, 5 * time.Second, 10 * time.Minute, 30 * time.Minute, 55 * time.Minute,
+	} {
+		t.Run(elapsed.String(), func(t *testing.T) {
+			notBefore := now.Add(-elapsed)
+			notAfter := notBefore.Add(time.Hour)
+			want := notAfter.Sub(now) - refreshBuffer
+			got := refreshDuration(now, notBefore, notAfter, 0)
+			if got != want {
+				t.Fatalf("time until refresh: want = %v, got = %v", want, got)
+			}
+		})
+	}
+}
+
+func TestRefreshDurationWithWindow(t *testing.T) {
+	now := time.Now()
+	tcs := []struct {
+		desc      string
+		notBefore time.Time
+		notAfter  time.Time
+		window    time.Duration
+		want      time.Duration
+	}{
+		{
+			desc:      "when a 1 hour certificate was just issued",
+			notBefore: now,
+			notAfter:  now.Add(time.Hour),
+			window:    10 * time.Minute,
+			want:      50 * time.Minute,
+		},
+		{
+			desc:      "when the window has already started",
+			notBefore: now.Add(-55 * time.Minute),
+			notAfter:  now.Add(5 * time.Minute),
+			window:    10 * time.Minute,
+			want:      0,
+		},
+		{
+			desc:      "when the window exceeds the lifetime",
+			notBefore: now,
+			notAfter:  now.Add(5 * time.Minute),
+			window:    10 * time.Minute,
+			// falls back to the refresh buffer
+			want: time.Minute,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := refreshDuration(now, tc.notBefore, tc.notAfter, tc.window)
+			if got.Round(time.Second) != tc.want {
+				t.Fatalf("time until refresh: want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRefreshAheadCacheSchedulesRefreshWithWindow(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc),
+		option.WithEndpoint(url),
+		option.WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	window := 10 * time.Minute
+	i := NewRefreshAheadCache(
+		testInstanceURI(),
+		nullLogger{},
+		c, rsaKey, 30*time.Second, "dialer-id",
+		false,
+		WithRefreshAheadWindow(window),
+	)
+	defer i.Close()
+	if _, err := i.ConnectionInfo(ctx); err != nil {
+		t.Fatalf("failed to retrieve connect info: %v", err)
+	}
+
+	s := i.RefreshStatus()
+	want := s.Expiration.Add(-window).Round(time.Second)
+	if got := s.NextRefresh.Round(time.Second); !got.Equal(want) {
+		t.Fatalf("next refresh: want = %v, got = %v", want, got)
+	}
+}
+
+func TestRefreshDurationLeavesSafeMargin(t *testing.T) {
+	now := time.Now()
+	for _, lifetime := range []time.Duration{
+		5 * time.Minute, time.Hour, 24 * time.Hour,
+	} {
+		t.Run(lifetime.String(), func(t *testing.T) {
+			// Assume the certificate arrives shortly after being issued.
+			notBefore := now.Add(-5 * time.Second)
+			notAfter := notBefore.Add(lifetime)
+			refresh := now.Add(refreshDuration(now, notBefore, notAfter, 0))
+			// The refresh must leave enough time for a full refresh cycle,
+			// including waiting on the rate limiter, to complete before the
+			// certificate expires.
+			margin := notAfter.Sub(refresh)
+			if want := refreshBuffer; margin < want {
+				t.Fatalf("margin before expiry: want >= %v, got = %v", want, margin)
+			}
+			if !refresh.After(now) {
+				t.Fatalf("want refresh in the future, got = %v", refresh)
+			}
+		})
+	}
+}
+
+func TestRefreshAheadCacheRefreshesOnSchedule(t *testing.T) {
+	ctx := context.Background()
+	clk := newFakeClock(time.Now())
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithCertExpiry(clk.Now().Add(time.Hour)),
+	)
+	// Expect one call for the initial refresh and one scheduled refresh.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc),
+		option.WithEndpoint(url),
+		option.WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	i := NewRefreshAheadCache(
+		testInstanceURI(),
+		nullLogger{},
+		c, rsaKey, 30*time.Second, "dialer-id",
+		false,
+		withClock(clk),
+	)
+	defer i.Close()
+	if _, err := i.ConnectionInfo(ctx); err != nil {
+		t.Fatalf("failed to retrieve connect info: %v", err)
+	}
+	start := clk.Now()
+	s := i.RefreshStatus()
+	if !s.LastRefresh.Equal(start) {
+		t.Fatalf("last refresh: want = %v, got = %v", start, s.LastRefresh)
+	}
+	// The next refresh is due 4 minutes before the certificate expires.
+	next := s.NextRefresh
+	if want := start.Add(56 * time.Minute); next.Sub(want).Abs() > time.Minute {
+		t.Fatalf("next refresh: want ~%v, got = %v", want, next)
+	}
+
+	clk.Advance(next.Sub(start) - time.Second)
+	if got := i.RefreshStatus().LastRefresh; !got.Equal(start) {
+		t.Fatalf("want no refresh before it is due, got last refresh = %v", got)
+	}
+	clk.Advance(time.Second)
+	if got := i.RefreshStatus().LastRefresh; !got.Equal(next) {
+		t.Fatalf("last refresh: want = %v, got = %v", next, got)
+	}
+}
+
+func TestRefreshAheadCacheStopsAfterMaxRefreshFailures(t *testing.T) {
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	// The mock fails the test if the cache keeps refreshing after the second
+	// failure.
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralError(inst, http.StatusInternalServerError, 2),
+	)
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc),
+		option.WithEndpoint(url),
+		option.WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	stopped := make(chan error, 1)
+	i := NewRefreshAheadCache(
+		testInstanceURI(),
+		nullLogger{},
+		c, rsaKey, 30*time.Second, "dialer-id",
+		false,
+		WithMaxRefreshFailures(2, func(err error) { stopped <- err }),
+	)
+	defer i.Close()
+
+	var stopErr error
+	select {
+	case stopErr = <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want cache to stop refreshing after two failures")
+	}
+	if stopErr == nil {
+		t.Fatal("want refresh error when the cache stops refreshing")
+	}
+	if _, err := i.ConnectionInfo(ctx); err == nil {
+		t.Fatal("want ConnectionInfo to return the refresh error")
+	}
+	if err := cleanup(); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestRefreshAheadCacheLogsTimeline(t *testing.T) {
+	ctx := context.Background()
+	clk := newFakeClock(time.Now())
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithCertExpiry(clk.Now().Add(time.Hour)),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(ctx, option.WithHTTPClient(mc),
+		option.WithEndpoint(url),
+		option.WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	l := &spyLogger{}
+	start := clk.Now()
+	i := NewRefreshAheadCache(
+		testInstanceURI(),
+		l,
+		c, rsaKey, 30*time.Second, "dialer-id",
+		false,
+		withClock(clk),
+	)
+	defer i.Close()
+	if _, err := i.ConnectionInfo(ctx); err != nil {
+		t.Fatalf("failed to retrieve connect info: %v", err)
+	}
+
+	msgs := l.find("Refresh timeline")
+	if len(msgs) != 1 {
+		t.Fatalf("want 1 timeline message, got = %v", msgs)
+	}
+	s := i.RefreshStatus()
+	for _, want := range []string{
+		"[" + testInstanceURI().String() + "]",
+		"scheduled = " + start.UTC().Format(time.RFC3339Nano),
+		"started = " + start.UTC().Format(time.RFC3339Nano),
+		"delay = 0s",
+		"duration = 0s",
+		"expiry = " + s.Expiration.UTC().Format(time.RFC3339),
+		"next = " + s.NextRefresh.UTC().Format(time.RFC3339),
+		"err = <nil>",
+	} {
+		if !strings.Contains(msgs[0], want) {
+			t.Errorf("want timeline to contain %q, got = %v", want, msgs[0])
+		}
+	}
+}
@@ -17,38 +17,59 @@ package alloydb
 import (
 	"context"
 	"crypto/rsa"
+	"errors"
 	"sync"
 	"time"
 
 	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
 	"cloud.google.com/go/alloydbconn/debug"
+	"cloud.google.com/go/alloydbconn/instance"
+	"cloud.google.com/go/alloydbconn/internal/tel"
 )
 
+// ErrCacheClosed is returned by ConnectionInfo once the cache is closed.
+var ErrCacheClosed = errors.New("connection info cache is closed")
+
 // LazyRefreshCache is caches connection info and refreshes the cache only when
 // a caller requests connection info and the current certificate is expired.
 type LazyRefreshCache struct {
-	uri          InstanceURI
+	uri          instance.URI
 	logger       debug.ContextLogger
 	r            adminAPIClient
+	clock        clock
 	mu           sync.Mutex
 	needsRefresh bool
 	cached       ConnectionInfo
+	lastRefresh  time.Time
+	lastFailure  time.Time
+	// closed reports whether Close has been called. Once closed, the cache
+	// no longer calls the AlloyDB Admin API.
+	closed bool
+	// ctx is canceled by Close to stop a refresh in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewLazyRefreshCache initializes a new LazyRefreshCache.
 func NewLazyRefreshCache(
-	uri InstanceURI,
+	uri instance.URI,
 	l debug.ContextLogger,
 	client *alloydbadmin.AlloyDBAdminClient,
 	key *rsa.PrivateKey,
 	_ time.Duration,
 	dialerID string,
 	disableMetadataExchange bool,
+	opts ...Option,
 ) *LazyRefreshCache {
+	r := newAdminAPIClient(client, key, dialerID, disableMetadataExchange, opts...)
+	ctx, cancel := context.WithCancel(context.Background())
 	return &LazyRefreshCache{
 		uri:    uri,
 		logger: l,
-		r:      newAdminAPIClient(client, key, dialerID, disableMetadataExchange),
+		r:      r,
+		clock:  r.clock,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
@@ -61,8 +82,11 @@ func (c *LazyRefreshCache) ConnectionInfo(
 ) (ConnectionInfo, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.closed {
+		return ConnectionInfo{}, ErrCacheClosed
+	}
 	// strip monotonic clock with UTC()
-	now := time.Now().UTC()
+	now := c.clock.Now().UTC()
 	// Pad expiration with a buffer to give the client plenty of time to
 	// establish a connection to the server with the certificate.
 	exp := c.cached.Expiration.UTC().Add(-refreshBuffer)
@@ -80,7 +104,20 @@ func (c *LazyRefreshCache) ConnectionInfo(
 		"[%v] Connection info refresh operation started",
 		c.uri.String(),
 	)
+	// Stop the refresh if the cache is closed while it is in progress.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer stop()
+	start := c.clock.Now()
 	ci, err := c.r.connectionInfo(ctx, c.uri)
+	latency := c.clock.Now().Sub(start).Milliseconds()
+	c.r.record(func() {
+		tel.RecordRefreshLatency(
+			context.Background(), c.uri.String(), c.r.dialerID,
+			tel.LazyType, latency, err,
+		)
+	})
 	if err != nil {
 		c.logger.Debugf(
 			ctx,
@@ -88,6 +125,8 @@ func (c *LazyRefreshCache) ConnectionInfo(
 			c.uri.String(),
 			err,
 		)
+		c.lastFailure = c.clock.Now()
+		c.r.notifyRefresh(err)
 		return ConnectionInfo{}, err
 	}
 	c.logger.Debugf(
@@ -103,9 +142,24 @@ func (c *LazyRefreshCache) ConnectionInfo(
 	)
 	c.cached = ci
 	c.needsRefresh = false
+	c.lastRefresh = c.clock.Now()
+	c.r.notifyRefresh(nil)
 	return ci, nil
 }
 
+// RefreshStatus reports the times of the last successful and failed refreshes
+// and the expiration of the cached certificate. A lazy cache never schedules a
+// background refresh, so NextRefresh is always zero.
+func (c *LazyRefreshCache) RefreshStatus() RefreshStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return RefreshStatus{
+		LastRefresh: c.lastRefresh,
+		LastFailure: c.lastFailure,
+		Expiration:  c.cached.Expiration,
+	}
+}
+
 // ForceRefresh invalidates the caches and configures the next call to
 // ConnectionInfo to retrieve a fresh connection info.
 func (c *LazyRefreshCache) ForceRefresh() {
@@ -114,8 +168,12 @@ func (c *LazyRefreshCache) ForceRefresh() {
 	c.needsRefresh = true
 }
 
-// Close is a no-op and provided purely for a consistent interface with other
-// caching types.
+// Close stops a refresh in progress and prevents the cache from making
+// additional calls to the AlloyDB Admin API.
 func (c *LazyRefreshCache) Close() error {
+	c.cancel()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
 	return nil
 }
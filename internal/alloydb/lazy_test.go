@@ -16,6 +16,8 @@ package alloydb
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"testing"
 	"time"
 
@@ -26,7 +28,7 @@ import (
 
 func TestLazyRefreshCacheConnectionInfo(t *testing.T) {
 	u := testInstanceURI()
-	inst := mock.NewFakeInstance(u.project, u.region, u.cluster, u.name)
+	inst := mock.NewFakeInstance(u.Project(), u.Region(), u.Cluster(), u.Name())
 	client, url, cleanup := mock.HTTPClient(
 		mock.InstanceGetSuccess(inst, 1),
 		mock.CreateEphemeralSuccess(inst, 1),
@@ -69,7 +71,7 @@ func TestLazyRefreshCacheConnectionInfo(t *testing.T) {
 
 func TestLazyRefreshCacheForceRefresh(t *testing.T) {
 	u := testInstanceURI()
-	inst := mock.NewFakeInstance(u.project, u.region, u.cluster, u.name)
+	inst := mock.NewFakeInstance(u.Project(), u.Region(), u.Cluster(), u.Name())
 	client, url, cleanup := mock.HTTPClient(
 		mock.InstanceGetSuccess(inst, 2),
 		mock.CreateEphemeralSuccess(inst, 2),
@@ -107,3 +109,143 @@ func TestLazyRefreshCacheForceRefresh(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestLazyRefreshCacheRefreshesExpiredCertificate(t *testing.T) {
+	clk := newFakeClock(time.Now())
+	u := testInstanceURI()
+	inst := mock.NewFakeInstance(
+		u.Project(), u.Region(), u.Cluster(), u.Name(),
+		mock.WithCertExpiry(clk.Now().Add(time.Hour)),
+	)
+	// Expect one call for the initial refresh and one after the certificate
+	// nears expiration.
+	client, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 2),
+		mock.CreateEphemeralSuccess(inst, 2),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	ctx := context.Background()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx,
+		option.WithHTTPClient(client),
+		option.WithEndpoint(url),
+		option.WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	cache := NewLazyRefreshCache(
+		testInstanceURI(), nullLogger{}, c,
+		rsaKey, 30*time.Second, "",
+		false, withClock(clk),
+	)
+
+	if _, err := cache.ConnectionInfo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The certificate is still valid, so the cached info is used.
+	clk.Advance(50 * time.Minute)
+	if _, err := cache.ConnectionInfo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cache.RefreshStatus().LastRefresh, clk.Now().Add(-50*time.Minute); !got.Equal(want) {
+		t.Fatalf("last refresh: want = %v, got = %v", want, got)
+	}
+	// The certificate is within the refresh buffer of its expiration.
+	clk.Advance(7 * time.Minute)
+	if _, err := cache.ConnectionInfo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cache.RefreshStatus().LastRefresh, clk.Now(); !got.Equal(want) {
+		t.Fatalf("last refresh: want = %v, got = %v", want, got)
+	}
+}
+
+func TestLazyRefreshCacheRecordsFailedRefresh(t *testing.T) {
+	clk := newFakeClock(time.Now())
+	// The mock has no responses, so every refresh fails.
+	client, url, cleanup := mock.HTTPClient()
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	ctx := context.Background()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx,
+		option.WithHTTPClient(client),
+		option.WithEndpoint(url),
+		option.WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	cache := NewLazyRefreshCache(
+		testInstanceURI(), nullLogger{}, c,
+		rsaKey, 30*time.Second, "",
+		false, withClock(clk),
+	)
+
+	if _, err := cache.ConnectionInfo(ctx); err == nil {
+		t.Fatal("want refresh error, got nil")
+	}
+	s := cache.RefreshStatus()
+	if !s.LastRefresh.IsZero() {
+		t.Fatalf("last refresh: want zero, got = %v", s.LastRefresh)
+	}
+	if got, want := s.LastFailure, clk.Now(); !got.Equal(want) {
+		t.Fatalf("last failure: want = %v, got = %v", want, got)
+	}
+}
+
+func TestLazyRefreshCacheCloseStopsRefresh(t *testing.T) {
+	// The refresh sends two requests at once.
+	tr := &blockingTransport{started: make(chan struct{}, 2)}
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		context.Background(),
+		option.WithHTTPClient(&http.Client{Transport: tr}),
+		option.WithEndpoint("http://127.0.0.1"),
+		option.WithTokenSource(stubTokenSource{}),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+	defer c.Close()
+	cache := NewLazyRefreshCache(
+		testInstanceURI(), nullLogger{}, c,
+		rsaKey, 30*time.Second, "",
+		false,
+	)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := cache.ConnectionInfo(context.Background())
+		errCh <- err
+	}()
+	// Wait for the refresh to reach the Admin API, then close the cache.
+	<-tr.started
+	if err := cache.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, but got error: %v", err)
+	}
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("want the refresh in progress to fail, got nil")
+		}
+	default:
+		t.Fatal("want Close to wait for the refresh in progress")
+	}
+
+	// A closed cache doesn't call the Admin API.
+	before := len(tr.started)
+	if _, err := cache.ConnectionInfo(context.Background()); !errors.Is(err, ErrCacheClosed) {
+		t.Fatalf("want = %v, got = %v", ErrCacheClosed, err)
+	}
+	if n := len(tr.started) - before; n != 0 {
+		t.Fatalf("want no requests after Close, got = %v", n)
+	}
+}
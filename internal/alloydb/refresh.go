@@ -18,17 +18,20 @@ import (
 	"bytes"
 	"context"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
 	"cloud.google.com/go/alloydb/apiv1alpha/alloydbpb"
 	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
 	"cloud.google.com/go/alloydbconn/internal/tel"
 	"google.golang.org/protobuf/types/known/durationpb"
 )
@@ -53,7 +56,7 @@ type instanceInfo struct {
 // information about an AlloyDB instance that is used to create secure
 // connections.
 func fetchInstanceInfo(
-	ctx context.Context, cl *alloydbadmin.AlloyDBAdminClient, inst InstanceURI,
+	ctx context.Context, cl *alloydbadmin.AlloyDBAdminClient, inst instance.URI,
 ) (i instanceInfo, err error) {
 	var end tel.EndSpanFunc
 	ctx, end = tel.StartSpan(ctx, "cloud.google.com/go/alloydbconn/internal.FetchMetadata")
@@ -61,7 +64,7 @@ func fetchInstanceInfo(
 	req := &alloydbpb.GetConnectionInfoRequest{
 		Parent: fmt.Sprintf(
 			"projects/%s/locations/%s/clusters/%s/instances/%s",
-			inst.project, inst.region, inst.cluster, inst.name,
+			inst.Project(), inst.Region(), inst.Cluster(), inst.Name(),
 		),
 	}
 	resp, err := cl.GetConnectionInfo(ctx, req)
@@ -115,12 +118,14 @@ type clientCertificate struct {
 // fetchClientCertificate uses the AlloyDB Admin API's
 // generateClientCertificate method to create a signed TLS certificate that
 // authorized to connect via the AlloyDB instance's serverside proxy. The cert
-// is valid for one hour.
+// is requested to be valid for certDuration, though the server may issue a
+// certificate with a shorter lifetime.
 func fetchClientCertificate(
 	ctx context.Context,
 	cl *alloydbadmin.AlloyDBAdminClient,
-	inst InstanceURI,
+	inst instance.URI,
 	key *rsa.PrivateKey,
+	certDuration time.Duration,
 	disableMetadataExchange bool,
 ) (cc *clientCertificate, err error) {
 	var end tel.EndSpanFunc
@@ -135,10 +140,10 @@ func fetchClientCertificate(
 	}
 	req := &alloydbpb.GenerateClientCertificateRequest{
 		Parent: fmt.Sprintf(
-			"projects/%s/locations/%s/clusters/%s", inst.project, inst.region, inst.cluster,
+			"projects/%s/locations/%s/clusters/%s", inst.Project(), inst.Region(), inst.Cluster(),
 		),
 		PublicKey:           buf.String(),
-		CertDuration:        durationpb.New(time.Second * 3600),
+		CertDuration:        durationpb.New(certDuration),
 		UseMetadataExchange: !disableMetadataExchange,
 	}
 	resp, err := cl.GenerateClientCertificate(ctx, req)
@@ -162,11 +167,18 @@ func fetchClientCertificate(
 }
 
 func newClientCertificate(
-	inst InstanceURI,
+	inst instance.URI,
 	keyPEM []byte,
 	chain []string,
 	caCertRaw string,
 ) (cc *clientCertificate, err error) {
+	if len(chain) == 0 {
+		return nil, errtype.NewRefreshError(
+			"create ephemeral cert failed",
+			inst.String(),
+			errors.New("certificate chain is empty"),
+		)
+	}
 	certPEMBlock := []byte(strings.Join(chain, "\n"))
 	cert, err := tls.X509KeyPair(certPEMBlock, keyPEM)
 	if err != nil {
@@ -222,25 +234,118 @@ func newClientCertificate(
 	}, nil
 }
 
+// An Option configures how a connection info cache retrieves connection info.
+type Option func(*adminAPIClient)
+
+// WithConnectionInfoClient configures the cache to retrieve instance metadata
+// with the provided client instead of the default client.
+func WithConnectionInfoClient(c *alloydbadmin.AlloyDBAdminClient) Option {
+	return func(a *adminAPIClient) {
+		a.infoClient = c
+	}
+}
+
+// WithExpectedCACertFingerprint configures the cache to reject connection info
+// whose CA certificate does not have the provided SHA-256 fingerprint.
+func WithExpectedCACertFingerprint(fp []byte) Option {
+	return func(a *adminAPIClient) {
+		a.caFingerprint = fp
+	}
+}
+
+// WithExpectedInstanceUID configures the cache to reject connection info for
+// an instance whose UID does not match uid.
+func WithExpectedInstanceUID(uid string) Option {
+	return func(a *adminAPIClient) {
+		a.expectedUID = uid
+	}
+}
+
+// WithRefreshAheadWindow configures a RefreshAheadCache to refresh the
+// connection info when window remains before the client certificate expires.
+// It has no effect on other caches.
+func WithRefreshAheadWindow(window time.Duration) Option {
+	return func(a *adminAPIClient) {
+		a.refreshAheadWindow = window
+	}
+}
+
+// WithCertDuration configures the cache to request client certificates valid
+// for d instead of ClientCertDuration.
+func WithCertDuration(d time.Duration) Option {
+	return func(a *adminAPIClient) {
+		a.certDuration = d
+	}
+}
+
+// WithCertificateClient configures the cache to generate client certificates
+// with the provided client instead of the default client.
+func WithCertificateClient(c *alloydbadmin.AlloyDBAdminClient) Option {
+	return func(a *adminAPIClient) {
+		a.certClient = c
+	}
+}
+
+// WithMaxRefreshFailures configures a RefreshAheadCache to stop refreshing
+// after n consecutive failed refreshes. The cache then returns the last
+// refresh error from ConnectionInfo and calls onStop with the error in its own
+// goroutine, so that the owner of the cache can close and discard it.
+func WithMaxRefreshFailures(n int, onStop func(error)) Option {
+	return func(a *adminAPIClient) {
+		a.maxRefreshFailures = n
+		a.onRefreshStop = onStop
+	}
+}
+
+// WithRefreshNotifier configures the cache to call f with the result of each
+// refresh once the cache's state reflects it. f is called while the cache
+// holds its lock and must not block.
+func WithRefreshNotifier(f func(error)) Option {
+	return func(a *adminAPIClient) {
+		a.onRefresh = f
+	}
+}
+
+// WithSynchronousTelemetry configures the cache to record metrics before
+// returning from a refresh instead of in a separate goroutine, so that no
+// work continues after a refresh completes.
+func WithSynchronousTelemetry() Option {
+	return func(a *adminAPIClient) {
+		a.syncTelemetry = true
+	}
+}
+
 func newAdminAPIClient(
 	client *alloydbadmin.AlloyDBAdminClient,
 	key *rsa.PrivateKey,
 	dialerID string,
 	disableMetadataExchange bool,
+	opts ...Option,
 ) adminAPIClient {
-	return adminAPIClient{
-		client:                  client,
+	a := adminAPIClient{
+		infoClient:              client,
+		certClient:              client,
 		key:                     key,
 		dialerID:                dialerID,
 		disableMetadataExchange: disableMetadataExchange,
+		certDuration:            ClientCertDuration,
+		clock:                   realClock{},
+	}
+	for _, opt := range opts {
+		opt(&a)
 	}
+	return a
 }
 
 // adminAPIClient manages the AlloyDB Admin API access to instance metadata and
 // to ephemeral certificates.
 type adminAPIClient struct {
-	// client provides access to the AlloyDB Admin API
-	client *alloydbadmin.AlloyDBAdminClient
+	// infoClient provides access to the AlloyDB Admin API for retrieving
+	// instance metadata.
+	infoClient *alloydbadmin.AlloyDBAdminClient
+	// certClient provides access to the AlloyDB Admin API for generating
+	// client certificates.
+	certClient *alloydbadmin.AlloyDBAdminClient
 	// key is used to request client certificates
 	key *rsa.PrivateKey
 	// dialerID is the unique ID of the associated dialer.
@@ -248,19 +353,76 @@ type adminAPIClient struct {
 	// disableMetadataExchange is a temporary addition to ease the migration to
 	// when the metadata exchange is required.
 	disableMetadataExchange bool
+	// certDuration is the lifetime requested for client certificates.
+	certDuration time.Duration
+	// caFingerprint is the expected SHA-256 fingerprint of the CA
+	// certificate. When empty, any CA certificate is accepted.
+	caFingerprint []byte
+	// expectedUID is the expected UID of the instance. When empty, any UID is
+	// accepted.
+	expectedUID string
+	// refreshAheadWindow is how long before the client certificate expires a
+	// RefreshAheadCache refreshes the connection info. When zero, or not
+	// shorter than the certificate's lifetime, the cache refreshes the larger
+	// of refreshBuffer and 1/15 of the lifetime before expiration.
+	refreshAheadWindow time.Duration
+	// clock is the caches' source of time.
+	clock clock
+	// syncTelemetry reports whether metrics are recorded in the calling
+	// goroutine.
+	syncTelemetry bool
+	// maxRefreshFailures is the number of consecutive failed refreshes after
+	// which a RefreshAheadCache stops refreshing. When zero, the cache
+	// retries indefinitely.
+	maxRefreshFailures int
+	// onRefreshStop is called when a RefreshAheadCache stops refreshing.
+	onRefreshStop func(error)
+	// onRefresh, if set, is called with the result of each refresh.
+	onRefresh func(error)
+}
+
+// notifyRefresh reports the result of a refresh to the configured notifier,
+// if any.
+func (c adminAPIClient) notifyRefresh(err error) {
+	if c.onRefresh != nil {
+		c.onRefresh(err)
+	}
+}
+
+// record runs f, which records metrics, in its own goroutine unless
+// synchronous telemetry is configured.
+func (c adminAPIClient) record(f func()) {
+	if c.syncTelemetry {
+		f()
+		return
+	}
+	go f()
 }
 
 // ConnectionInfo holds all the data necessary to connect to an instance.
 type ConnectionInfo struct {
-	Instance   InstanceURI
+	Instance   instance.URI
 	IPAddrs    map[string]string
 	ClientCert tls.Certificate
 	RootCAs    *x509.CertPool
 	Expiration time.Time
 }
 
+// RefreshStatus reports the state of a cache's refresh cycle.
+type RefreshStatus struct {
+	// LastRefresh is the time of the most recent successful refresh.
+	LastRefresh time.Time
+	// LastFailure is the time of the most recent failed refresh.
+	LastFailure time.Time
+	// Expiration is the expiration of the current client certificate.
+	Expiration time.Time
+	// NextRefresh is the time the next background refresh is scheduled to
+	// run. It is zero for caches that do not refresh in the background.
+	NextRefresh time.Time
+}
+
 func (c adminAPIClient) connectionInfo(
-	ctx context.Context, i InstanceURI,
+	ctx context.Context, i instance.URI,
 ) (res ConnectionInfo, err error) {
 
 	var refreshEnd tel.EndSpanFunc
@@ -268,20 +430,42 @@ func (c adminAPIClient) connectionInfo(
 		tel.AddInstanceName(i.String()),
 	)
 	defer func() {
-		go tel.RecordRefreshResult(
-			context.Background(), i.String(), c.dialerID, err,
-		)
+		c.record(func() {
+			tel.RecordRefreshResult(
+				context.Background(), i.String(), c.dialerID, err,
+			)
+		})
+		if err == nil {
+			expiry := res.Expiration.Sub(c.clock.Now())
+			c.record(func() {
+				tel.RecordCertExpiry(
+					context.Background(), i.String(), c.dialerID, expiry,
+				)
+			})
+		}
 		refreshEnd(err)
 	}()
 
+	// Cancel any fetch still in flight on return, e.g., after the other fetch
+	// failed, and wait for both to finish so that no goroutine outlives the
+	// refresh. The channels are buffered so neither send blocks.
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
 	type mdRes struct {
 		info instanceInfo
 		err  error
 	}
 	mdCh := make(chan mdRes, 1)
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		defer close(mdCh)
-		c, err := fetchInstanceInfo(ctx, c.client, i)
+		c, err := fetchInstanceInfo(ctx, c.infoClient, i)
 		mdCh <- mdRes{info: c, err: err}
 	}()
 
@@ -290,9 +474,13 @@ func (c adminAPIClient) connectionInfo(
 		err error
 	}
 	certCh := make(chan certRes, 1)
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		defer close(certCh)
-		cc, err := fetchClientCertificate(ctx, c.client, i, c.key, c.disableMetadataExchange)
+		cc, err := fetchClientCertificate(
+			ctx, c.certClient, i, c.key, c.certDuration, c.disableMetadataExchange,
+		)
 		certCh <- certRes{cc: cc, err: err}
 	}()
 
@@ -322,6 +510,26 @@ func (c adminAPIClient) connectionInfo(
 		return ConnectionInfo{}, fmt.Errorf("refresh failed: %w", ctx.Err())
 	}
 
+	if c.expectedUID != "" && info.uid != c.expectedUID {
+		return ConnectionInfo{}, errtype.NewConfigError(
+			fmt.Sprintf(
+				"instance UID does not match expected UID (want = %q, got = %q)",
+				c.expectedUID, info.uid,
+			),
+			i.String(),
+		)
+	}
+	if len(c.caFingerprint) > 0 {
+		got := sha256.Sum256(cc.caCert.Raw)
+		if !bytes.Equal(got[:], c.caFingerprint) {
+			return ConnectionInfo{}, errtype.NewRefreshError(
+				"CA certificate does not match expected fingerprint",
+				i.String(),
+				fmt.Errorf("want = %x, got = %x", c.caFingerprint, got),
+			)
+		}
+	}
+
 	caCerts := x509.NewCertPool()
 	caCerts.AddCert(cc.caCert)
 	ci := ConnectionInfo{
@@ -16,11 +16,18 @@ package alloydb
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
+	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
 	"cloud.google.com/go/alloydbconn/internal/mock"
 	"google.golang.org/api/option"
 )
@@ -33,7 +40,7 @@ func TestRefresh(t *testing.T) {
 	wantPSC := "x.y.alloydb.goog"
 	wantExpiry := time.Now().Add(time.Hour).UTC().Round(time.Second)
 	wantInstURI := "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
-	cn, err := ParseInstURI(wantInstURI)
+	cn, err := instance.ParseURI(wantInstURI)
 	if err != nil {
 		t.Fatalf("parseConnName(%s)failed : %v", cn, err)
 	}
@@ -99,7 +106,7 @@ func TestRefresh(t *testing.T) {
 
 func TestRefreshFailsFast(t *testing.T) {
 	wantInstURI := "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
-	cn, err := ParseInstURI(wantInstURI)
+	cn, err := instance.ParseURI(wantInstURI)
 	if err != nil {
 		t.Fatalf("parseConnName(%s)failed : %v", cn, err)
 	}
@@ -139,3 +146,272 @@ func TestRefreshFailsFast(t *testing.T) {
 		t.Fatalf("expected context.Canceled error, got = %v", err)
 	}
 }
+
+// blockingTransport fails the requests whose path contains failPath and
+// blocks all other requests until they are canceled.
+type blockingTransport struct {
+	failPath string
+	started  chan struct{}
+}
+
+func (b *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if b.failPath != "" && strings.Contains(req.URL.Path, b.failPath) {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body: io.NopCloser(strings.NewReader(
+				`{"error": {"code": 404, "message": "not found"}}`,
+			)),
+			Request: req,
+		}, nil
+	}
+	b.started <- struct{}{}
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestRefreshStopsFetchesOnReturn(t *testing.T) {
+	cn, err := instance.ParseURI(
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		desc string
+		// failPath is the path of the request that fails immediately.
+		failPath string
+		// blocked is the number of requests that block until canceled.
+		blocked int
+	}{
+		{
+			desc:    "context canceled mid-refresh",
+			blocked: 2,
+		},
+		{
+			desc:     "instance metadata fetch fails",
+			failPath: "connectionInfo",
+			blocked:  1,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			tr := &blockingTransport{
+				failPath: tc.failPath,
+				started:  make(chan struct{}, 2),
+			}
+			cl, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				context.Background(),
+				option.WithHTTPClient(&http.Client{Transport: tr}),
+				option.WithEndpoint("http://127.0.0.1"),
+			)
+			if err != nil {
+				t.Fatalf("admin API client error: %v", err)
+			}
+			defer cl.Close()
+			r := newAdminAPIClient(
+				cl, rsaKey, testDialerID, false, WithSynchronousTelemetry(),
+			)
+
+			before := runtime.NumGoroutine()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				for i := 0; i < tc.blocked; i++ {
+					<-tr.started
+				}
+				if tc.failPath == "" {
+					cancel()
+				}
+			}()
+			if _, err := r.connectionInfo(ctx, cn); err == nil {
+				t.Fatal("want refresh to fail, got no error")
+			}
+			// The goroutine above exits once the blocked requests
+			// started, which happens before connectionInfo returns.
+			deadline := time.Now().Add(time.Second)
+			for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if after := runtime.NumGoroutine(); after > before {
+				t.Fatalf("want at most %v goroutines after refresh, got = %v", before, after)
+			}
+		})
+	}
+}
+
+func TestRefreshWithEmptyCertificateChain(t *testing.T) {
+	cn, err := instance.ParseURI(
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralEmptyChain(inst, 1),
+	)
+	defer func() {
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	cl, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		context.Background(),
+		option.WithHTTPClient(mc),
+		option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("admin API client error: %v", err)
+	}
+	r := newAdminAPIClient(cl, rsaKey, testDialerID, false)
+
+	_, err = r.connectionInfo(context.Background(), cn)
+	var rErr *errtype.RefreshError
+	if !errors.As(err, &rErr) {
+		t.Fatalf("want = %T, got = %v", rErr, err)
+	}
+	if !strings.Contains(err.Error(), "certificate chain is empty") {
+		t.Fatalf("want error to describe the empty chain, got = %v", err)
+	}
+}
+
+func TestRefreshWithExpectedCACertFingerprint(t *testing.T) {
+	cn, err := instance.ParseURI(
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	chain, err := inst.GeneratePEMCertificateChain(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := parseCert(chain[len(chain)-1]) // CA cert is last in chain
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := sha256.Sum256(ca.Raw)
+	mismatch := sha256.Sum256([]byte("not the CA"))
+
+	tcs := []struct {
+		desc    string
+		fp      []byte
+		wantErr bool
+	}{
+		{desc: "matching fingerprint", fp: match[:]},
+		{desc: "mismatched fingerprint", fp: mismatch[:], wantErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			defer func() {
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			cl, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				context.Background(),
+				option.WithHTTPClient(mc),
+				option.WithEndpoint(url),
+			)
+			if err != nil {
+				t.Fatalf("admin API client error: %v", err)
+			}
+			r := newAdminAPIClient(
+				cl, rsaKey, testDialerID, false,
+				WithExpectedCACertFingerprint(tc.fp),
+			)
+
+			_, err = r.connectionInfo(context.Background(), cn)
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatalf("want no error, got = %v", err)
+				}
+				return
+			}
+			var wantErr *errtype.RefreshError
+			if !errors.As(err, &wantErr) {
+				t.Fatalf("want = %T, got = %v", wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRefreshWithExpectedInstanceUID(t *testing.T) {
+	cn, err := instance.ParseURI(
+		"projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithUID("11111111-1111-1111-1111-111111111111"),
+	)
+
+	tcs := []struct {
+		desc    string
+		uid     string
+		wantErr bool
+	}{
+		{desc: "no expected UID"},
+		{desc: "matching UID", uid: "11111111-1111-1111-1111-111111111111"},
+		{
+			desc:    "mismatched UID",
+			uid:     "22222222-2222-2222-2222-222222222222",
+			wantErr: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			defer func() {
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			cl, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				context.Background(),
+				option.WithHTTPClient(mc),
+				option.WithEndpoint(url),
+			)
+			if err != nil {
+				t.Fatalf("admin API client error: %v", err)
+			}
+			r := newAdminAPIClient(
+				cl, rsaKey, testDialerID, false,
+				WithExpectedInstanceUID(tc.uid),
+			)
+
+			_, err = r.connectionInfo(context.Background(), cn)
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatalf("want no error, got = %v", err)
+				}
+				return
+			}
+			var wantErr *errtype.ConfigError
+			if !errors.As(err, &wantErr) {
+				t.Fatalf("want = %T, got = %v", wantErr, err)
+			}
+			for _, uid := range []string{tc.uid, "11111111-1111-1111-1111-111111111111"} {
+				if !strings.Contains(err.Error(), uid) {
+					t.Fatalf("want error to contain %q, got = %v", uid, err)
+				}
+			}
+		})
+	}
+}
@@ -18,10 +18,13 @@ import (
 	"context"
 	"crypto/x509"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/fs"
 
 	"cloud.google.com/go/alloydbconn/debug"
 	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
 )
 
 type staticPSCConfig struct {
@@ -76,6 +79,58 @@ func (s *staticData) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MergeStaticConnectionInfo reads the static connection info files in fsys
+// that match pattern and merges them into a single static connection info
+// document. It reports an error if no file matches, if an instance is defined
+// in more than one file, or if the files hold different key pairs.
+func MergeStaticConnectionInfo(fsys fs.FS, pattern string) ([]byte, error) {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no static connection info files match %q", pattern)
+	}
+	var (
+		merged = map[string]json.RawMessage{}
+		// source records the file that defined each key of merged.
+		source = map[string]string{}
+	)
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %v", name, err)
+		}
+		for k, v := range doc {
+			prev, ok := source[k]
+			switch {
+			case !ok:
+				merged[k], source[k] = v, name
+			case k == "publicKey" || k == "privateKey":
+				var a, b string
+				if err := json.Unmarshal(merged[k], &a); err != nil {
+					return nil, fmt.Errorf("failed to parse %v in %v: %v", k, prev, err)
+				}
+				if err := json.Unmarshal(v, &b); err != nil {
+					return nil, fmt.Errorf("failed to parse %v in %v: %v", k, name, err)
+				}
+				if a != b {
+					return nil, fmt.Errorf("%v in %v conflicts with %v", k, name, prev)
+				}
+			default:
+				return nil, fmt.Errorf(
+					"instance %v is defined in both %v and %v", k, prev, name,
+				)
+			}
+		}
+	}
+	return json.Marshal(merged)
+}
+
 // StaticConnectionInfoCache provides connection info that is never refreshed.
 type StaticConnectionInfoCache struct {
 	logger debug.ContextLogger
@@ -85,7 +140,7 @@ type StaticConnectionInfoCache struct {
 // NewStaticConnectionInfoCache creates a connection info cache that will
 // always return the predefined connection info within the provided io.Reader
 func NewStaticConnectionInfoCache(
-	inst InstanceURI,
+	inst instance.URI,
 	l debug.ContextLogger,
 	r io.Reader,
 ) (*StaticConnectionInfoCache, error) {
@@ -126,6 +181,18 @@ func NewStaticConnectionInfoCache(
 	}, nil
 }
 
+// NewFixedConnectionInfoCache creates a connection info cache that will
+// always return the provided connection info.
+func NewFixedConnectionInfoCache(
+	l debug.ContextLogger,
+	info ConnectionInfo,
+) *StaticConnectionInfoCache {
+	return &StaticConnectionInfoCache{
+		logger: l,
+		info:   info,
+	}
+}
+
 // ConnectionInfo returns the connection info for the specified instance URI as
 // loaded from the provided io.Reader.
 func (c *StaticConnectionInfoCache) ConnectionInfo(
@@ -138,5 +205,11 @@ func (c *StaticConnectionInfoCache) ConnectionInfo(
 // information and does no refresh.
 func (*StaticConnectionInfoCache) ForceRefresh() {}
 
+// RefreshStatus reports the expiration of the static certificate. The static
+// cache never refreshes, so all other fields are zero.
+func (c *StaticConnectionInfoCache) RefreshStatus() RefreshStatus {
+	return RefreshStatus{Expiration: c.info.Expiration}
+}
+
 // Close is a no-op.
 func (*StaticConnectionInfoCache) Close() error { return nil }
@@ -73,6 +73,37 @@ func WithCertExpiry(expiry time.Time) Option {
 	}
 }
 
+// WithUID sets the UID of the instance.
+func WithUID(uid string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.uid = uid
+	}
+}
+
+// WithInstanceType sets the type of the instance (e.g., PRIMARY or
+// READ_POOL).
+func WithInstanceType(t string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.instanceType = t
+	}
+}
+
+// WithMetadataExchangeError configures the server proxy to reject every
+// metadata exchange with an ERROR response carrying msg.
+func WithMetadataExchangeError(msg string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.mdxError = msg
+	}
+}
+
+// WithOAuth2Token configures the server proxy to reject every metadata
+// exchange whose OAuth2 token is not tok with an ERROR response.
+func WithOAuth2Token(tok string) Option {
+	return func(f *FakeAlloyDBInstance) {
+		f.oauth2Token = tok
+	}
+}
+
 // FakeAlloyDBInstance represents the server side proxy.
 type FakeAlloyDBInstance struct {
 	project string
@@ -84,6 +115,14 @@ type FakeAlloyDBInstance struct {
 	uid        string
 	serverName string
 	certExpiry time.Time
+	// instanceType is the type of the instance (PRIMARY or READ_POOL).
+	instanceType string
+	// mdxError, if set, is the error the server proxy returns in response to
+	// every metadata exchange.
+	mdxError string
+	// oauth2Token, if set, is the only OAuth2 token the server proxy accepts
+	// in the metadata exchange.
+	oauth2Token string
 
 	rootCACert *x509.Certificate
 	rootKey    *rsa.PrivateKey
@@ -131,6 +170,8 @@ func NewFakeInstance(proj, reg, clust, name string, opts ...Option) FakeAlloyDBI
 		uid:        "00000000-0000-0000-0000-000000000000",
 		serverName: "00000000-0000-0000-0000-000000000000.server.alloydb",
 		certExpiry: time.Now().Add(24 * time.Hour),
+
+		instanceType: "PRIMARY",
 	}
 
 	for _, o := range opts {
@@ -195,6 +236,10 @@ func NewFakeInstance(proj, reg, clust, name string, opts ...Option) FakeAlloyDBI
 		BasicConstraintsValid: true,
 		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
 	}
+	// Include the PSC DNS name so that PSC connections verify the server.
+	if psc, ok := f.ipAddrs["PSC"]; ok && net.ParseIP(psc) == nil {
+		serverTemplate.DNSNames = []string{psc}
+	}
 	signedServer, err := x509.CreateCertificate(
 		rand.Reader, serverTemplate, rootCert, &serverKey.PublicKey, rootCAKey)
 	if err != nil {
@@ -257,10 +302,10 @@ func (f *FakeAlloyDBInstance) GeneratePEMCertificateChain(
 // on all interfaces, configured with TLS as specified by the
 // FakeAlloyDBInstance. Callers should invoke the returned function to clean up
 // all resources.
-func StartServerProxy(t *testing.T, inst FakeAlloyDBInstance) func() {
+func StartServerProxy(t testing.TB, inst FakeAlloyDBInstance) func() {
 	pool := x509.NewCertPool()
 	pool.AddCert(inst.rootCACert)
-	tryListen := func(t *testing.T, attempts int) net.Listener {
+	tryListen := func(t testing.TB, attempts int) net.Listener {
 		var (
 			ln  net.Listener
 			err error
@@ -300,9 +345,16 @@ func StartServerProxy(t *testing.T, inst FakeAlloyDBInstance) func() {
 				if err != nil {
 					return
 				}
-				if err := metadataExchange(conn); err != nil {
+				ok, err := metadataExchange(conn, inst)
+				if err != nil {
+					// The client may have been rejected, e.g., for an
+					// untrusted certificate. Keep serving other clients.
+					conn.Close()
+					continue
+				}
+				if !ok {
 					conn.Close()
-					return
+					continue
 				}
 
 				// Database protocol takes over from here.
@@ -328,7 +380,10 @@ func StartServerProxy(t *testing.T, inst FakeAlloyDBInstance) func() {
 //
 // The real server implementation will then validate the client has connection
 // permissions using the provided OAuth2 token based on the auth type. Here in
-// the test implementation, the server does nothing.
+// the test implementation, the server accepts any request, unless the
+// instance is configured with WithMetadataExchangeError or WithOAuth2Token,
+// in which case it responds with an ERROR and reports that the request was
+// not accepted.
 //
 //  3. Prepare a response and write the size of the response as a uint32 (4
 //     bytes)
@@ -336,38 +391,48 @@ func StartServerProxy(t *testing.T, inst FakeAlloyDBInstance) func() {
 // 4. Marshal the response to bytes and write those to the client as well.
 //
 // Subsequent interactions with the test server use the database protocol.
-func metadataExchange(conn net.Conn) error {
+func metadataExchange(conn net.Conn, inst FakeAlloyDBInstance) (bool, error) {
 	msgSize := make([]byte, 4)
 	n, err := conn.Read(msgSize)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if n != 4 {
-		return fmt.Errorf("read %d bytes, want = 4", n)
+		return false, fmt.Errorf("read %d bytes, want = 4", n)
 	}
 
 	size := binary.BigEndian.Uint32(msgSize)
 	buf := make([]byte, size)
 	n, err = conn.Read(buf)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if n != int(size) {
-		return fmt.Errorf("read %d bytes, want = %d", n, size)
+		return false, fmt.Errorf("read %d bytes, want = %d", n, size)
 	}
 
 	m := &connectorspb.MetadataExchangeRequest{}
 	err = proto.Unmarshal(buf, m)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	resp := &connectorspb.MetadataExchangeResponse{
 		ResponseCode: connectorspb.MetadataExchangeResponse_OK,
 	}
+	mdxErr := inst.mdxError
+	if mdxErr == "" && inst.oauth2Token != "" && m.GetOauth2Token() != inst.oauth2Token {
+		mdxErr = "invalid OAuth2 token"
+	}
+	if mdxErr != "" {
+		resp = &connectorspb.MetadataExchangeResponse{
+			ResponseCode: connectorspb.MetadataExchangeResponse_ERROR,
+			Error:        mdxErr,
+		}
+	}
 	data, err := proto.Marshal(resp)
 	if err != nil {
-		return err
+		return false, err
 	}
 	respSize := proto.Size(resp)
 	buf = make([]byte, 4)
@@ -376,10 +441,10 @@ func metadataExchange(conn net.Conn) error {
 	buf = append(buf, data...)
 	n, err = conn.Write(buf)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if n != len(buf) {
-		return fmt.Errorf("write %d bytes, want = %d", n, len(buf))
+		return false, fmt.Errorf("write %d bytes, want = %d", n, len(buf))
 	}
-	return nil
+	return mdxErr == "", nil
 }
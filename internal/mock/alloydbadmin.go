@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sync"
+	"time"
 
 	"cloud.google.com/go/alloydb/apiv1alpha/alloydbpb"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -93,9 +94,60 @@ func InstanceGetSuccess(i FakeAlloyDBInstance, ct int) *Request {
 	}
 }
 
+// ListInstancesSuccess returns a Request that responds to the
+// `instances.list` AlloyDB Admin API endpoint. All instances must belong to
+// the same cluster.
+func ListInstancesSuccess(insts []FakeAlloyDBInstance, ct int) *Request {
+	i := insts[0]
+	p := fmt.Sprintf("/v1alpha/projects/%s/locations/%s/clusters/%s/instances",
+		i.project, i.region, i.cluster)
+
+	var res []map[string]string
+	for _, inst := range insts {
+		res = append(res, map[string]string{
+			"name":         inst.String(),
+			"instanceType": inst.instanceType,
+			"uid":          inst.uid,
+		})
+	}
+	jsonString, err := json.Marshal(map[string]any{"instances": res})
+	if err != nil {
+		panic(err)
+	}
+	return &Request{
+		reqMethod: http.MethodGet,
+		reqPath:   p,
+		reqCt:     ct,
+		handle: func(resp http.ResponseWriter, _ *http.Request) {
+			resp.WriteHeader(http.StatusOK)
+			resp.Write(jsonString)
+		},
+	}
+}
+
 // CreateEphemeralSuccess returns a Request that responds to the
 // `generateClientCertificate` AlloyDB Admin API endpoint.
 func CreateEphemeralSuccess(i FakeAlloyDBInstance, ct int) *Request {
+	return createEphemeral(i, ct, nil)
+}
+
+// CreateEphemeralWithCertDuration returns a Request that responds to the
+// `generateClientCertificate` AlloyDB Admin API endpoint, but only when the
+// request asks for a certificate valid for d. Otherwise, the Request fails
+// with a 400 error.
+func CreateEphemeralWithCertDuration(i FakeAlloyDBInstance, ct int, d time.Duration) *Request {
+	return createEphemeral(i, ct, func(rreq *alloydbpb.GenerateClientCertificateRequest) error {
+		if got := rreq.GetCertDuration().AsDuration(); got != d {
+			return fmt.Errorf("cert duration = %v, want = %v", got, d)
+		}
+		return nil
+	})
+}
+
+func createEphemeral(
+	i FakeAlloyDBInstance, ct int,
+	check func(*alloydbpb.GenerateClientCertificateRequest) error,
+) *Request {
 	return &Request{
 		reqMethod: http.MethodPost,
 		reqPath: fmt.Sprintf(
@@ -116,6 +168,12 @@ func CreateEphemeralSuccess(i FakeAlloyDBInstance, ct int) *Request {
 				http.Error(resp, fmt.Errorf("invalid or unexpected json: %w", err).Error(), http.StatusBadRequest)
 				return
 			}
+			if check != nil {
+				if err := check(&rreq); err != nil {
+					http.Error(resp, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
 			bl, _ := pem.Decode([]byte(rreq.PublicKey))
 			if bl == nil {
 				http.Error(resp, fmt.Errorf("unable to decode CSR: %w", err).Error(), http.StatusBadRequest)
@@ -149,6 +207,53 @@ func CreateEphemeralSuccess(i FakeAlloyDBInstance, ct int) *Request {
 	}
 }
 
+// CreateEphemeralEmptyChain returns a Request that responds to the
+// `generateClientCertificate` AlloyDB Admin API endpoint with a malformed
+// response that has neither a certificate chain nor a CA certificate.
+func CreateEphemeralEmptyChain(i FakeAlloyDBInstance, ct int) *Request {
+	return &Request{
+		reqMethod: http.MethodPost,
+		reqPath: fmt.Sprintf(
+			"/v1alpha/projects/%s/locations/%s/clusters/%s:generateClientCertificate",
+			i.project, i.region, i.cluster),
+		reqCt: ct,
+		handle: func(resp http.ResponseWriter, _ *http.Request) {
+			rresp := alloydbpb.GenerateClientCertificateResponse{}
+			if err := json.NewEncoder(resp).Encode(&rresp); err != nil {
+				http.Error(resp, fmt.Errorf("unable to encode response: %w", err).Error(), http.StatusBadRequest)
+				return
+			}
+		},
+	}
+}
+
+// CreateEphemeralError returns a Request that responds to the
+// `generateClientCertificate` AlloyDB Admin API endpoint with an error of the
+// provided HTTP status code.
+func CreateEphemeralError(i FakeAlloyDBInstance, code, ct int) *Request {
+	body, err := json.Marshal(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": http.StatusText(code),
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &Request{
+		reqMethod: http.MethodPost,
+		reqPath: fmt.Sprintf(
+			"/v1alpha/projects/%s/locations/%s/clusters/%s:generateClientCertificate",
+			i.project, i.region, i.cluster),
+		reqCt: ct,
+		handle: func(resp http.ResponseWriter, _ *http.Request) {
+			resp.Header().Set("Content-Type", "application/json")
+			resp.WriteHeader(code)
+			resp.Write(body)
+		},
+	}
+}
+
 // HTTPClient returns an *http.Client, URL, and cleanup function. The http.Client is
 // configured to connect to test SSL Server at the returned URL. This server will
 // respond to HTTP requests defined, or return a 5xx server error for unexpected ones.
@@ -18,9 +18,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"cloud.google.com/go/alloydbconn/errtype"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
@@ -28,15 +31,26 @@ import (
 )
 
 var (
-	keyInstance, _  = tag.NewKey("alloydb_instance")
-	keyDialerID, _  = tag.NewKey("alloydb_dialer_id")
-	keyErrorCode, _ = tag.NewKey("alloydb_error_code")
+	keyInstance, _      = tag.NewKey("alloydb_instance")
+	keyDialerID, _      = tag.NewKey("alloydb_dialer_id")
+	keyErrorCode, _     = tag.NewKey("alloydb_error_code")
+	keyRefreshType, _   = tag.NewKey("alloydb_refresh_type")
+	keyRefreshStatus, _ = tag.NewKey("alloydb_refresh_status")
+	keyDatabase, _      = tag.NewKey("alloydb_database")
+	keyUser, _          = tag.NewKey("alloydb_user")
+	keyDialStatus, _    = tag.NewKey("alloydb_dial_status")
+	keyCacheHit, _      = tag.NewKey("alloydb_cache_hit")
 
 	mLatencyMS = stats.Int64(
 		"alloydbconn/latency",
 		"The latency in milliseconds per Dial",
 		stats.UnitMilliseconds,
 	)
+	mRefreshLatencyMS = stats.Int64(
+		"alloydbconn/refresh_latency",
+		"The latency in milliseconds per refresh operation",
+		stats.UnitMilliseconds,
+	)
 	mConnections = stats.Int64(
 		"alloydbconn/connection",
 		"A connect or disconnect event to an AlloyDB instance",
@@ -67,6 +81,11 @@ var (
 		"The bytes received from an AlloyDB instance",
 		stats.UnitDimensionless,
 	)
+	mCertExpiry = stats.Int64(
+		"alloydbconn/cert_expiry",
+		"The remaining validity in seconds of the client certificate",
+		stats.UnitSeconds,
+	)
 
 	latencyView = &view.View{
 		Name:        "alloydbconn/dial_latency",
@@ -74,21 +93,35 @@ var (
 		Description: "The distribution of dialer latencies (ms)",
 		// Latency in buckets, e.g., >=0ms, >=100ms, etc.
 		Aggregation: view.Distribution(0, 5, 25, 100, 250, 500, 1000, 2000, 5000, 30000),
-		TagKeys:     []tag.Key{keyInstance, keyDialerID},
+		TagKeys: []tag.Key{
+			keyInstance, keyDialerID, keyDatabase, keyUser, keyCacheHit,
+		},
+	}
+	refreshLatencyView = &view.View{
+		Name:        "alloydbconn/refresh_latency",
+		Measure:     mRefreshLatencyMS,
+		Description: "The distribution of refresh operation latencies (ms)",
+		// Latency in buckets, e.g., >=0ms, >=100ms, etc.
+		Aggregation: view.Distribution(0, 5, 25, 100, 250, 500, 1000, 2000, 5000, 30000),
+		TagKeys: []tag.Key{
+			keyInstance, keyDialerID, keyRefreshType, keyRefreshStatus,
+		},
 	}
 	connectionsView = &view.View{
 		Name:        "alloydbconn/open_connections",
 		Measure:     mConnections,
 		Description: "The current number of open AlloyDB connections",
 		Aggregation: view.LastValue(),
-		TagKeys:     []tag.Key{keyInstance, keyDialerID},
+		TagKeys:     []tag.Key{keyInstance, keyDialerID, keyDatabase, keyUser},
 	}
 	dialFailureView = &view.View{
 		Name:        "alloydbconn/dial_failure_count",
 		Measure:     mDialError,
 		Description: "The number of failed dial attempts",
 		Aggregation: view.Count(),
-		TagKeys:     []tag.Key{keyInstance, keyDialerID},
+		TagKeys: []tag.Key{
+			keyInstance, keyDialerID, keyDatabase, keyUser, keyDialStatus,
+		},
 	}
 	refreshCountView = &view.View{
 		Name:        "alloydbconn/refresh_success_count",
@@ -119,6 +152,14 @@ var (
 		TagKeys:     []tag.Key{keyInstance, keyDialerID},
 	}
 
+	certExpiryView = &view.View{
+		Name:        "alloydbconn/cert_remaining_seconds",
+		Measure:     mCertExpiry,
+		Description: "The remaining validity of the cached client certificate (s)",
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{keyInstance, keyDialerID},
+	}
+
 	registerOnce sync.Once
 	registerErr  error
 )
@@ -130,12 +171,14 @@ func InitMetrics() error {
 	registerOnce.Do(func() {
 		if rErr := view.Register(
 			latencyView,
+			refreshLatencyView,
 			connectionsView,
 			dialFailureView,
 			refreshCountView,
 			failedRefreshCountView,
 			bytesSentView,
 			bytesReceivedView,
+			certExpiryView,
 		); rErr != nil {
 			registerErr = fmt.Errorf("failed to initialize metrics: %v", rErr)
 		}
@@ -143,32 +186,113 @@ func InitMetrics() error {
 	return registerErr
 }
 
-// RecordDialLatency records a latency value for a call to dial.
-func RecordDialLatency(ctx context.Context, instance, dialerID string, latency int64) {
+// WithConnectionTags returns a context that tags the dial and connection
+// metrics recorded with it with the database and user of the connection.
+// Empty values are omitted.
+func WithConnectionTags(ctx context.Context, database, user string) context.Context {
+	var ms []tag.Mutator
+	if database != "" {
+		ms = append(ms, tag.Upsert(keyDatabase, database))
+	}
+	if user != "" {
+		ms = append(ms, tag.Upsert(keyUser, user))
+	}
+	if len(ms) == 0 {
+		return ctx
+	}
+	// The values are validated by the caller, so tag.New cannot fail.
+	ctx, _ = tag.New(ctx, ms...)
+	return ctx
+}
+
+// RecordDialLatency records a latency value for a call to dial, tagged with
+// whether the instance's connection info was already cached.
+func RecordDialLatency(
+	ctx context.Context, instance, dialerID string, latency int64, cacheHit bool,
+) {
 	// tag.New creates a new context and errors only if the new tag already
 	// exists in the provided context. Since we're adding tags within this
 	// package only, we can be confident that there were be no duplicate tags
 	// and so can ignore the error.
-	ctx, _ = tag.New(ctx, tag.Upsert(keyInstance, instance), tag.Upsert(keyDialerID, dialerID))
+	ctx, _ = tag.New(ctx,
+		tag.Upsert(keyInstance, instance),
+		tag.Upsert(keyDialerID, dialerID),
+		tag.Upsert(keyCacheHit, strconv.FormatBool(cacheHit)),
+	)
 	stats.Record(ctx, mLatencyMS.M(latency))
 }
 
+const (
+	// RefreshAheadType is the refresh type of refresh-ahead caches, which
+	// refresh in the background.
+	RefreshAheadType = "refresh_ahead"
+	// LazyType is the refresh type of lazy caches, which refresh when
+	// connection info is requested.
+	LazyType = "lazy"
+)
+
+// RecordRefreshLatency records a latency value for a refresh operation,
+// tagged with the type of cache that performed it and whether it succeeded.
+func RecordRefreshLatency(
+	ctx context.Context, instance, dialerID, refreshType string, latency int64, err error,
+) {
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	ctx, _ = tag.New(ctx,
+		tag.Upsert(keyInstance, instance),
+		tag.Upsert(keyDialerID, dialerID),
+		tag.Upsert(keyRefreshType, refreshType),
+		tag.Upsert(keyRefreshStatus, status),
+	)
+	stats.Record(ctx, mRefreshLatencyMS.M(latency))
+}
+
 // RecordOpenConnections records the number of open connections
 func RecordOpenConnections(ctx context.Context, num int64, dialerID, instance string) {
 	ctx, _ = tag.New(ctx, tag.Upsert(keyInstance, instance), tag.Upsert(keyDialerID, dialerID))
 	stats.Record(ctx, mConnections.M(num))
 }
 
-// RecordDialError reports a failed dial attempt. If err is nil, RecordDialError
-// is a no-op.
+const (
+	// DialMDXError is the dial status of dials that failed because the
+	// server rejected the metadata exchange.
+	DialMDXError = "metadata_exchange_error"
+	// DialUserError is the dial status of dials that failed because of the
+	// caller's configuration, e.g., an invalid option or a rejected instance.
+	DialUserError = "user_error"
+	// DialOtherError is the dial status of all other failed dials.
+	DialOtherError = "error"
+)
+
+// RecordDialError reports a failed dial attempt, tagged with its dial status.
+// If err is nil, RecordDialError is a no-op.
 func RecordDialError(ctx context.Context, instance, dialerID string, err error) {
 	if err == nil {
 		return
 	}
-	ctx, _ = tag.New(ctx, tag.Upsert(keyInstance, instance), tag.Upsert(keyDialerID, dialerID))
+	ctx, _ = tag.New(ctx,
+		tag.Upsert(keyInstance, instance),
+		tag.Upsert(keyDialerID, dialerID),
+		tag.Upsert(keyDialStatus, dialStatus(err)),
+	)
 	stats.Record(ctx, mDialError.M(1))
 }
 
+// dialStatus returns the dial status of a failed dial.
+func dialStatus(err error) string {
+	var mdxErr *errtype.MetadataExchangeError
+	if errors.As(err, &mdxErr) {
+		return DialMDXError
+	}
+	var cfgErr *errtype.ConfigError
+	if errors.As(err, &cfgErr) {
+		return DialUserError
+	}
+	return DialOtherError
+}
+
 // RecordRefreshResult reports the result of a refresh operation, either
 // successful or failed.
 func RecordRefreshResult(ctx context.Context, instance, dialerID string, err error) {
@@ -195,6 +319,13 @@ func RecordBytesReceived(ctx context.Context, num int64, instance, dialerID stri
 	stats.Record(ctx, mBytesReceived.M(num))
 }
 
+// RecordCertExpiry reports the remaining validity of the client certificate
+// used to connect to an AlloyDB instance.
+func RecordCertExpiry(ctx context.Context, instance, dialerID string, remaining time.Duration) {
+	ctx, _ = tag.New(ctx, tag.Upsert(keyInstance, instance), tag.Upsert(keyDialerID, dialerID))
+	stats.Record(ctx, mCertExpiry.M(int64(remaining.Seconds())))
+}
+
 // errorCode returns an error code as given from the AlloyDB Admin API, provided
 // the error wraps a googleapi.Error type. If multiple error codes are returned
 // from the API, then a comma-separated string of all codes is returned.
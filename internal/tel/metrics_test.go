@@ -15,10 +15,14 @@
 package tel
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
 
+	"cloud.google.com/go/alloydbconn/errtype"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 	"google.golang.org/api/googleapi"
 )
 
@@ -68,3 +72,156 @@ func TestErrorCodes(t *testing.T) {
 		})
 	}
 }
+
+func TestDialStatus(t *testing.T) {
+	tcs := []struct {
+		desc string
+		in   error
+		want string
+	}{
+		{
+			desc: "with a metadata exchange error",
+			in: fmt.Errorf("outer: %w", errtype.NewMetadataExchangeError(
+				"proj/reg/clust/inst", "ERROR", "permission denied",
+			)),
+			want: DialMDXError,
+		},
+		{
+			desc: "with a config error",
+			in:   errtype.NewConfigError("invalid option", "proj/reg/clust/inst"),
+			want: DialUserError,
+		},
+		{
+			desc: "with any other error",
+			in:   errtype.NewDialError("handshake failed", "proj/reg/clust/inst", nil),
+			want: DialOtherError,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := dialStatus(tc.in); got != tc.want {
+				t.Errorf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRecordRefreshLatency(t *testing.T) {
+	if err := InitMetrics(); err != nil {
+		t.Fatalf("want no error, got = %v", err)
+	}
+	RecordRefreshLatency(
+		context.Background(), "proj/reg/clust/inst", "dialer-id",
+		LazyType, 10, nil,
+	)
+	RecordRefreshLatency(
+		context.Background(), "proj/reg/clust/inst", "dialer-id",
+		RefreshAheadType, 20, errors.New("refresh failed"),
+	)
+
+	rows, err := view.RetrieveData("alloydbconn/refresh_latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		refreshType string
+		status      string
+		want        float64
+	}{
+		{refreshType: LazyType, status: "success", want: 10},
+		{refreshType: RefreshAheadType, status: "failure", want: 20},
+	}
+	for _, tc := range tcs {
+		wantTags := map[tag.Key]string{
+			keyInstance:      "proj/reg/clust/inst",
+			keyDialerID:      "dialer-id",
+			keyRefreshType:   tc.refreshType,
+			keyRefreshStatus: tc.status,
+		}
+		var found bool
+		for _, r := range rows {
+			if !hasTags(r.Tags, wantTags) {
+				continue
+			}
+			d, ok := r.Data.(*view.DistributionData)
+			if !ok {
+				t.Fatalf("want distribution data, got = %T", r.Data)
+			}
+			if d.Count != 1 || d.Mean != tc.want {
+				t.Fatalf(
+					"%v/%v: want count = 1 and mean = %v, got count = %v and mean = %v",
+					tc.refreshType, tc.status, tc.want, d.Count, d.Mean,
+				)
+			}
+			found = true
+		}
+		if !found {
+			t.Fatalf("want row with tags %v, got rows = %v", wantTags, rows)
+		}
+	}
+}
+
+func TestRecordDialLatency(t *testing.T) {
+	if err := InitMetrics(); err != nil {
+		t.Fatalf("want no error, got = %v", err)
+	}
+	RecordDialLatency(
+		context.Background(), "proj/reg/clust/inst", "dial-latency-dialer", 30, false,
+	)
+	RecordDialLatency(
+		context.Background(), "proj/reg/clust/inst", "dial-latency-dialer", 40, true,
+	)
+
+	rows, err := view.RetrieveData("alloydbconn/dial_latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		cacheHit string
+		want     float64
+	}{
+		{cacheHit: "false", want: 30},
+		{cacheHit: "true", want: 40},
+	}
+	for _, tc := range tcs {
+		wantTags := map[tag.Key]string{
+			keyInstance: "proj/reg/clust/inst",
+			keyDialerID: "dial-latency-dialer",
+			keyCacheHit: tc.cacheHit,
+		}
+		var found bool
+		for _, r := range rows {
+			if !hasTags(r.Tags, wantTags) {
+				continue
+			}
+			d, ok := r.Data.(*view.DistributionData)
+			if !ok {
+				t.Fatalf("want distribution data, got = %T", r.Data)
+			}
+			if d.Count != 1 || d.Mean != tc.want {
+				t.Fatalf(
+					"cache hit %v: want count = 1 and mean = %v, got count = %v and mean = %v",
+					tc.cacheHit, tc.want, d.Count, d.Mean,
+				)
+			}
+			found = true
+		}
+		if !found {
+			t.Fatalf("want row with tags %v, got rows = %v", wantTags, rows)
+		}
+	}
+}
+
+// hasTags reports whether tags has exactly the wanted keys and values.
+func hasTags(tags []tag.Tag, want map[tag.Key]string) bool {
+	if len(tags) != len(want) {
+		return false
+	}
+	for _, tg := range tags {
+		if v, ok := want[tg.Key]; !ok || v != tg.Value {
+			return false
+		}
+	}
+	return true
+}
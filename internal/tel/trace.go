@@ -48,6 +48,40 @@ func AddDialerID(dialerID string) Attribute {
 	return Attribute{key: "/alloydb/dialer_id", value: dialerID}
 }
 
+// AddProject creates an attribute with the project of an AlloyDB instance.
+func AddProject(project string) Attribute {
+	return Attribute{key: "/alloydb/project", value: project}
+}
+
+// AddRegion creates an attribute with the region of an AlloyDB instance.
+func AddRegion(region string) Attribute {
+	return Attribute{key: "/alloydb/region", value: region}
+}
+
+// AddCluster creates an attribute with the cluster of an AlloyDB instance.
+func AddCluster(cluster string) Attribute {
+	return Attribute{key: "/alloydb/cluster", value: cluster}
+}
+
+// AddInstanceID creates an attribute with the ID of an AlloyDB instance
+// within its cluster.
+func AddInstanceID(id string) Attribute {
+	return Attribute{key: "/alloydb/instance_id", value: id}
+}
+
+// AddSpanAttributes adds attributes to the span in ctx, if any.
+func AddSpanAttributes(ctx context.Context, attrs ...Attribute) {
+	span := trace.FromContext(ctx)
+	if span == nil {
+		return
+	}
+	as := make([]trace.Attribute, 0, len(attrs))
+	for _, a := range attrs {
+		as = append(as, a.traceAttr())
+	}
+	span.AddAttributes(as...)
+}
+
 // StartSpan begins a span with the provided name and returns a context and a
 // function to end the created span.
 func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, EndSpanFunc) {
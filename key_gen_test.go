@@ -15,8 +15,10 @@
 package alloydbconn
 
 import (
+	"crypto/rand"
 	"crypto/rsa"
 	"errors"
+	"sync/atomic"
 	"testing"
 )
 
@@ -158,3 +160,70 @@ func TestKeyGeneratorErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyPool(t *testing.T) {
+	var count atomic.Int32
+	g := newKeyPool(2, func() (*rsa.PrivateKey, error) {
+		count.Add(1)
+		return &rsa.PrivateKey{}, nil
+	})
+
+	var keys []*rsa.PrivateKey
+	for i := 0; i < 4; i++ {
+		k, err := g.rsaKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	if got := count.Load(); got != 2 {
+		t.Fatalf("want 2 generated keys, got = %v", got)
+	}
+	if keys[0] == keys[1] {
+		t.Fatal("want distinct keys from the pool")
+	}
+	// Keys are reused in turn once the pool is exhausted.
+	if keys[2] != keys[0] || keys[3] != keys[1] {
+		t.Fatalf("want keys to be reused in turn, got = %v", keys)
+	}
+}
+
+func TestKeyPoolErrors(t *testing.T) {
+	sentinel := errors.New("sentinel error")
+	g := newKeyPool(1, func() (*rsa.PrivateKey, error) {
+		return nil, sentinel
+	})
+	if _, err := g.rsaKey(); err != sentinel {
+		t.Fatalf("want = %v, got = %v", sentinel, err)
+	}
+}
+
+// BenchmarkFirstKey measures how long the first request for a key waits,
+// given that the Dialer was created some time before the first dial.
+func BenchmarkFirstKey(b *testing.B) {
+	genFunc := func() (*rsa.PrivateKey, error) {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+	b.Run("lazy key", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			g, err := newKeyGenerator(nil, true, genFunc)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := g.rsaKey(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("warm key pool", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			g := newKeyPool(1, genFunc)
+			<-g.pool[0].ready
+			b.StartTimer()
+			if _, err := g.rsaKey(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
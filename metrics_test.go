@@ -25,6 +25,7 @@ import (
 	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
 	"cloud.google.com/go/alloydbconn/internal/mock"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 	"google.golang.org/api/option"
 )
 
@@ -41,6 +42,7 @@ func (e *spyMetricsExporter) ExportView(vd *view.Data) {
 
 type metric struct {
 	name string
+	tags []tag.Tag
 	data view.AggregationData
 }
 
@@ -50,7 +52,7 @@ func (e *spyMetricsExporter) data() []metric {
 	var res []metric
 	for _, d := range e.viewData {
 		for _, r := range d.Rows {
-			res = append(res, metric{name: d.View.Name, data: r.Data})
+			res = append(res, metric{name: d.View.Name, tags: r.Tags, data: r.Data})
 		}
 	}
 	return res
@@ -83,6 +85,24 @@ func wantLastValueMetric(t *testing.T, wantName string, ms []metric, wantValue i
 	)
 }
 
+// wantPositiveLastValueMetric ensures the provided metrics include a metric
+// with the wanted name and a positive data point.
+func wantPositiveLastValueMetric(t *testing.T, wantName string, ms []metric) {
+	t.Helper()
+	gotNames := make(map[string]view.AggregationData)
+	for _, m := range ms {
+		gotNames[m.name] = m.data
+		d, ok := m.data.(*view.LastValueData)
+		if ok && m.name == wantName && d.Value > 0 {
+			return
+		}
+	}
+	t.Fatalf(
+		"want metric LastValueData{name = %q, value > 0}, got metrics = %v",
+		wantName, dump(t, gotNames),
+	)
+}
+
 // wantDistributionMetric ensures the provided metrics include a metric with
 // the wanted name and at least one data point.
 func wantDistributionMetric(t *testing.T, wantName string, ms []metric) {
@@ -165,7 +185,7 @@ func TestDialerWithMetrics(t *testing.T) {
 		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
 	}
 
-	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}))
+	d, err := NewDialer(ctx, WithTokenSource(stubTokenSource{}), WithRSAKey(testRSAKey))
 	if err != nil {
 		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
 	}
@@ -213,11 +233,305 @@ func TestDialerWithMetrics(t *testing.T) {
 	// success metrics
 	wantLastValueMetric(t, "alloydbconn/open_connections", spy.data(), 2)
 	wantDistributionMetric(t, "alloydbconn/dial_latency", spy.data())
+	wantDistributionMetric(t, "alloydbconn/refresh_latency", spy.data())
 	wantCountMetric(t, "alloydbconn/refresh_success_count", spy.data())
 	wantSumMetric(t, "alloydbconn/bytes_sent", spy.data())
 	wantSumMetric(t, "alloydbconn/bytes_received", spy.data())
+	wantPositiveLastValueMetric(t, "alloydbconn/cert_remaining_seconds", spy.data())
 
 	// failure metrics from dialing bogus instance
 	wantCountMetric(t, "alloydbconn/dial_failure_count", spy.data())
 	wantCountMetric(t, "alloydbconn/refresh_failure_count", spy.data())
 }
+
+func TestDialerWithDialerID(t *testing.T) {
+	spy := &spyMetricsExporter{}
+	view.RegisterExporter(spy)
+	defer view.UnregisterExporter(spy)
+	view.SetReportingPeriod(time.Millisecond)
+
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	const wantID = "my-dialer-id"
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithDialerID(wantID),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	conn, err := d.Dial(ctx, testInstanceURI)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	time.Sleep(100 * time.Millisecond) // allow exporter a chance to run
+
+	key, err := tag.NewKey("alloydb_dialer_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range spy.data() {
+		if m.name != "alloydbconn/dial_latency" {
+			continue
+		}
+		for _, tg := range m.tags {
+			if tg.Key == key && tg.Value == wantID {
+				return
+			}
+		}
+	}
+	t.Fatalf(
+		"want dial_latency metric with dialer ID %q, got metrics = %v",
+		wantID, dump(t, spy.data()),
+	)
+}
+
+func TestDialerDialLatencyRecordsCacheHit(t *testing.T) {
+	spy := &spyMetricsExporter{}
+	view.RegisterExporter(spy)
+	defer view.UnregisterExporter(spy)
+	view.SetReportingPeriod(time.Millisecond)
+
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	const wantID = "cache-hit-dialer"
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithDialerID(wantID),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	// The first dial retrieves the connection info and the second uses the
+	// cached info.
+	for i := 0; i < 2; i++ {
+		conn, err := d.Dial(ctx, testInstanceURI)
+		if err != nil {
+			t.Fatalf("expected Dial to succeed, but got error: %v", err)
+		}
+		conn.Close()
+	}
+
+	time.Sleep(100 * time.Millisecond) // allow exporter a chance to run
+
+	for _, cacheHit := range []string{"false", "true"} {
+		want := map[string]string{
+			"alloydb_dialer_id": wantID,
+			"alloydb_cache_hit": cacheHit,
+		}
+		found := false
+		for _, m := range spy.data() {
+			if m.name == "alloydbconn/dial_latency" && hasTagValues(m.tags, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf(
+				"want dial_latency metric with tags %v, got metrics = %v",
+				want, dump(t, spy.data()),
+			)
+		}
+	}
+}
+
+func TestDialerWithMetricTags(t *testing.T) {
+	spy := &spyMetricsExporter{}
+	view.RegisterExporter(spy)
+	defer view.UnregisterExporter(spy)
+	view.SetReportingPeriod(time.Millisecond)
+
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	const wantID = "metric-tags-dialer"
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithDialerID(wantID),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+
+	if _, err := d.Dial(ctx, testInstanceURI, WithMetricUser("")); err == nil {
+		t.Fatal("want Dial to fail with an empty metric user")
+	}
+	conn, err := d.Dial(ctx, testInstanceURI,
+		WithMetricDatabase("my-db"), WithMetricUser("my-user"),
+	)
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	time.Sleep(100 * time.Millisecond) // allow exporter a chance to run
+
+	want := map[string]string{
+		"alloydb_dialer_id": wantID,
+		"alloydb_database":  "my-db",
+		"alloydb_user":      "my-user",
+	}
+	for _, name := range []string{
+		"alloydbconn/dial_latency", "alloydbconn/open_connections",
+	} {
+		found := false
+		for _, m := range spy.data() {
+			if m.name == name && hasTagValues(m.tags, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf(
+				"want %v metric with tags %v, got metrics = %v",
+				name, want, dump(t, spy.data()),
+			)
+		}
+	}
+}
+
+func TestDialerMetadataExchangeErrorMetric(t *testing.T) {
+	spy := &spyMetricsExporter{}
+	view.RegisterExporter(spy)
+	defer view.UnregisterExporter(spy)
+	view.SetReportingPeriod(time.Millisecond)
+
+	ctx := context.Background()
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+		mock.WithMetadataExchangeError("permission denied"),
+	)
+	mc, url, cleanup := mock.HTTPClient(
+		mock.InstanceGetSuccess(inst, 1),
+		mock.CreateEphemeralSuccess(inst, 1),
+	)
+	stop := mock.StartServerProxy(t, inst)
+	defer func() {
+		stop()
+		if err := cleanup(); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}()
+	c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+		ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+	)
+	if err != nil {
+		t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+	}
+
+	const wantID = "mdx-error-dialer"
+	d, err := NewDialer(ctx,
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithDialerID(wantID),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	d.client = c
+	defer d.Close()
+
+	if _, err := d.Dial(ctx, testInstanceURI); err == nil {
+		t.Fatal("want Dial to fail when the metadata exchange is rejected")
+	}
+
+	time.Sleep(100 * time.Millisecond) // allow exporter a chance to run
+
+	want := map[string]string{
+		"alloydb_dialer_id":   wantID,
+		"alloydb_dial_status": "metadata_exchange_error",
+	}
+	for _, m := range spy.data() {
+		if m.name == "alloydbconn/dial_failure_count" && hasTagValues(m.tags, want) {
+			return
+		}
+	}
+	t.Fatalf(
+		"want dial_failure_count metric with tags %v, got metrics = %v",
+		want, dump(t, spy.data()),
+	)
+}
+
+// hasTagValues reports whether tags includes every key and value in want.
+func hasTagValues(tags []tag.Tag, want map[string]string) bool {
+	got := make(map[string]string, len(tags))
+	for _, tg := range tags {
+		got[tg.Key.Name()] = tg.Value
+	}
+	for k, v := range want {
+		if got[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -15,16 +15,26 @@
 package alloydbconn
 
 import (
+	"bytes"
 	"context"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
 	"cloud.google.com/go/alloydbconn/debug"
 	"cloud.google.com/go/alloydbconn/errtype"
+	"cloud.google.com/go/alloydbconn/instance"
 	"cloud.google.com/go/alloydbconn/internal/alloydb"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -45,31 +55,118 @@ type dialerConfig struct {
 	refreshTimeout time.Duration
 	tokenSource    oauth2.TokenSource
 	userAgents     []string
-	useIAMAuthN    bool
+	authType       AuthType
 	logger         debug.ContextLogger
 	lazyRefresh    bool
+	// backgroundDisabled prevents the Dialer from doing any work outside of
+	// calls to its methods.
+	backgroundDisabled bool
+	// refreshOnDialFailureDisabled prevents the Dialer from forcing a refresh
+	// when a connection attempt or TLS handshake fails.
+	refreshOnDialFailureDisabled bool
+	// keyPoolSize is the number of RSA keys generated in the background. When
+	// zero, a single key is shared by all instances.
+	keyPoolSize int
+
+	// credentials are the credentials loaded by WithCredentialsJSON or
+	// WithCredentialsFile, if any.
+	credentials *google.Credentials
+	// credentialsJSON is the JSON of credentials, if they provide the token
+	// source. It is used to reload the credentials with custom scopes.
+	credentialsJSON []byte
+	// scopes are the OAuth2 scopes requested for the API client's
+	// credentials. When empty, CloudPlatformScope is used.
+	scopes []string
 
 	// disableMetadataExchange is a temporary addition and will be removed in
 	// future versions.
 	disableMetadataExchange bool
 
 	staticConnInfo io.Reader
+	// cacheFactory creates connection info caches. See
+	// WithConnectionInfoCacheFactory.
+	cacheFactory ConnectionInfoCacheFactory
+	// maxRefreshFailures is the number of consecutive failed background
+	// refreshes after which an instance's cache is removed.
+	maxRefreshFailures int
+	// maxCachedInstances is the number of instances whose connection info is
+	// cached. When zero, the number is unbounded.
+	maxCachedInstances int
+	// refreshNotifier, if set, receives an event after each refresh.
+	refreshNotifier chan<- RefreshEvent
+
+	// adminClient is a caller-provided AlloyDB Admin API client. When set,
+	// the Dialer does not create its own.
+	adminClient *alloydbadmin.AlloyDBAdminClient
+	// regionalEndpoint routes the Admin API calls for each instance to the
+	// endpoint of the instance's region.
+	regionalEndpoint bool
+
+	// connectionInfoEndpoint and certificateEndpoint override the Admin API
+	// endpoint for the respective API calls.
+	connectionInfoEndpoint string
+	certificateEndpoint    string
+
+	// refreshOpts configures the connection info caches.
+	refreshOpts []alloydb.Option
+	// refreshAheadWindow is how long before a certificate expires it is
+	// refreshed. When zero, the caches' default is used.
+	refreshAheadWindow time.Duration
+	// certDuration is the lifetime requested for client certificates. When
+	// zero, alloydb.ClientCertDuration is used.
+	certDuration time.Duration
+
+	// onDial is called after each call to Dial.
+	onDial func(DialEvent)
+	// spanInstanceAttrs adds the parts of the instance URI to spans.
+	spanInstanceAttrs bool
+	// validateInstance, if set, rejects instances the dialer may not use.
+	validateInstance func(instance.URI) error
+	// beforeDial, if set, adjusts the dial configuration of each Dial.
+	beforeDial BeforeDialFunc
+	// additionalRootCAs are trusted in addition to the instance's CA.
+	additionalRootCAs *x509.CertPool
+
+	// pscResolver returns the host to dial for PSC connections.
+	pscResolver func(instance string) (string, error)
+
+	// dnsResolver looks up the instance URI of a domain name passed to Dial.
+	dnsResolver txtResolver
+
+	// negativeCacheTTL is how long a permanent refresh failure is cached.
+	negativeCacheTTL time.Duration
+
+	// bufferSize is the size of the buffers used for the metadata exchange.
+	bufferSize int
+	// metadataExchangeTimeout bounds each read and write of the metadata
+	// exchange.
+	metadataExchangeTimeout time.Duration
+	// tlsSessionCacheSize is the number of TLS sessions cached per instance.
+	tlsSessionCacheSize int
+
+	// dialerID identifies the dialer in telemetry. When empty, a random UUID
+	// is used.
+	dialerID string
+
 	// err tracks any dialer options that may have failed.
 	err error
 }
 
-// WithOptions turns a list of Option's into a single Option.
+// WithOptions turns a list of Option's into a single Option. Nil options are
+// ignored.
 func WithOptions(opts ...Option) Option {
 	return func(d *dialerConfig) {
 		for _, opt := range opts {
-			opt(d)
+			if opt != nil {
+				opt(d)
+			}
 		}
 	}
 }
 
-// WithCredentialsFile returns an Option that specifies a service account
-// or refresh token JSON credentials file to be used as the basis for
-// authentication.
+// WithCredentialsFile returns an Option that specifies a service account,
+// refresh token, or workload identity federation (external account) JSON
+// credentials file to be used as the basis for authentication.
 func WithCredentialsFile(filename string) Option {
 	return func(d *dialerConfig) {
 		b, err := os.ReadFile(filename)
@@ -82,8 +179,9 @@ func WithCredentialsFile(filename string) Option {
 	}
 }
 
-// WithCredentialsJSON returns an Option that specifies a service account
-// or refresh token JSON credentials to be used as the basis for authentication.
+// WithCredentialsJSON returns an Option that specifies a service account,
+// refresh token, or workload identity federation (external account) JSON
+// credentials to be used as the basis for authentication.
 func WithCredentialsJSON(b []byte) Option {
 	return func(d *dialerConfig) {
 		// TODO: Use AlloyDB-specfic scope
@@ -93,10 +191,66 @@ func WithCredentialsJSON(b []byte) Option {
 			return
 		}
 		d.tokenSource = c.TokenSource
+		d.credentials = c
+		d.credentialsJSON = b
 		d.adminOpts = append(d.adminOpts, apiopt.WithCredentials(c))
 	}
 }
 
+// WithScopes returns an Option that sets the OAuth2 scopes requested for the
+// credentials used to call the AlloyDB Admin API, replacing the default
+// CloudPlatformScope. The scopes apply to Application Default Credentials and
+// to credentials provided with WithCredentialsFile or WithCredentialsJSON.
+// They have no effect on a token source provided with WithTokenSource, which
+// determines its own scopes. At least one scope is required.
+func WithScopes(scopes ...string) Option {
+	return func(d *dialerConfig) {
+		if len(scopes) == 0 {
+			d.err = errtype.NewConfigError("at least one scope is required", "n/a")
+			return
+		}
+		d.scopes = scopes
+	}
+}
+
+// maxTagValueLength is the longest value permitted for a metric tag.
+const maxTagValueLength = 255
+
+// checkTagValue returns an error if v, the value of the metric tag described
+// by desc, is empty, too long, or contains characters other than printable
+// ASCII.
+func checkTagValue(desc, v string) error {
+	if v == "" || len(v) > maxTagValueLength {
+		return fmt.Errorf(
+			"%s must be between 1 and %d characters", desc, maxTagValueLength,
+		)
+	}
+	for _, r := range v {
+		if r < ' ' || r > '~' {
+			return fmt.Errorf(
+				"%s must contain only printable ASCII characters", desc,
+			)
+		}
+	}
+	return nil
+}
+
+// WithDialerID returns an Option that sets the ID used to identify the Dialer
+// in metrics and traces (the alloydb_dialer_id tag). By default, each Dialer
+// uses a random UUID. A fixed ID is useful to correlate telemetry from
+// multiple processes that share a logical client identity, or to make
+// telemetry reproducible in tests. The ID must be non-empty printable ASCII
+// of at most 255 characters.
+func WithDialerID(id string) Option {
+	return func(d *dialerConfig) {
+		if err := checkTagValue("dialer ID", id); err != nil {
+			d.err = errtype.NewConfigError(err.Error(), "n/a")
+			return
+		}
+		d.dialerID = id
+	}
+}
+
 // WithUserAgent returns an Option that sets the User-Agent.
 func WithUserAgent(ua string) Option {
 	return func(d *dialerConfig) {
@@ -117,6 +271,7 @@ func WithDefaultDialOptions(opts ...DialOption) Option {
 func WithTokenSource(s oauth2.TokenSource) Option {
 	return func(d *dialerConfig) {
 		d.tokenSource = s
+		d.credentialsJSON = nil
 		d.adminOpts = append(d.adminOpts, apiopt.WithTokenSource(s))
 	}
 }
@@ -129,8 +284,74 @@ func WithRSAKey(k *rsa.PrivateKey) Option {
 	}
 }
 
+// WithKeyPoolSize returns an Option that generates a pool of n RSA keys in the
+// background when the Dialer is created, instead of a single key. Each
+// instance the Dialer connects to is assigned the pool's next key, in turn,
+// for its client certificates. Generating a 2048-bit key takes tens to
+// hundreds of milliseconds, so a warm pool shortens the first dial to each
+// instance, at the cost of memory and CPU at startup.
+//
+// By default, all instances share one key. A shared key is generated once and
+// never rotated, so a compromised key affects connections to every instance
+// until the Dialer is recreated. A pool limits each key to the instances
+// assigned to it, but keys are still reused once every key in the pool has
+// been assigned. WithKeyPoolSize cannot be used with WithRSAKey.
+func WithKeyPoolSize(n int) Option {
+	return func(d *dialerConfig) {
+		if n <= 0 {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid key pool size %d, must be positive", n),
+				"n/a",
+			)
+			return
+		}
+		d.keyPoolSize = n
+	}
+}
+
+// WithRefreshAheadWindow returns an Option that sets how long before a client
+// certificate expires the dialer refreshes it in the background. By default,
+// the dialer refreshes a one hour certificate about 4 minutes before it
+// expires, and longer-lived certificates proportionally earlier.
+// The window must be positive and less than the certificate lifetime set
+// with WithCertDuration, which defaults to one hour. This option has no effect
+// with WithLazyRefresh.
+func WithRefreshAheadWindow(window time.Duration) Option {
+	return func(d *dialerConfig) {
+		if window <= 0 {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid refresh ahead window %v, must be positive", window),
+				"n/a",
+			)
+			return
+		}
+		d.refreshAheadWindow = window
+	}
+}
+
+// WithCertDuration returns an Option that sets the lifetime the dialer
+// requests for client certificates. Defaults to one hour. The duration must be
+// at least one hour and at most 24 hours, the longest lifetime the AlloyDB
+// Admin API accepts. The server may still issue a certificate with a shorter
+// lifetime; in that case refreshes are scheduled from the actual expiration.
+func WithCertDuration(dur time.Duration) Option {
+	return func(d *dialerConfig) {
+		if dur < alloydb.ClientCertDuration || dur > alloydb.MaxClientCertDuration {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf(
+					"invalid cert duration %v, must be between %v and %v",
+					dur, alloydb.ClientCertDuration, alloydb.MaxClientCertDuration,
+				),
+				"n/a",
+			)
+			return
+		}
+		d.certDuration = dur
+	}
+}
+
 // WithRefreshTimeout returns an Option that sets a timeout on refresh
-// operations. Defaults to 60s.
+// operations. Defaults to DefaultRefreshTimeout.
 func WithRefreshTimeout(t time.Duration) Option {
 	return func(d *dialerConfig) {
 		d.refreshTimeout = t
@@ -146,6 +367,20 @@ func WithHTTPClient(client *http.Client) Option {
 	}
 }
 
+// WithAdminClient returns an Option that configures the Dialer to use c for
+// all AlloyDB Admin API calls instead of creating its own client. This allows
+// a single client, and its HTTP connections, to be shared by several Dialers.
+// Options that configure the Admin API client, such as WithHTTPClient and
+// WithAdminAPIEndpoint, have no effect on c. The Dialer's credentials are
+// still used to authenticate to the instance during the metadata exchange.
+//
+// The caller owns c: closing the Dialer does not close c.
+func WithAdminClient(c *alloydbadmin.AlloyDBAdminClient) Option {
+	return func(d *dialerConfig) {
+		d.adminClient = c
+	}
+}
+
 // WithAdminAPIEndpoint configures the underlying AlloyDB Admin API client to
 // use the provided URL.
 func WithAdminAPIEndpoint(url string) Option {
@@ -154,6 +389,175 @@ func WithAdminAPIEndpoint(url string) Option {
 	}
 }
 
+// WithRegionalAdminEndpoint configures the dialer to send the AlloyDB Admin
+// API calls for each instance to the regional endpoint of the instance's
+// region (e.g., https://us-central1-alloydb.googleapis.com for an instance
+// in us-central1) instead of the global endpoint. This keeps API traffic
+// within the instance's region for data residency. The option overrides
+// WithAdminAPIEndpoint and cannot be used with WithAdminClient.
+func WithRegionalAdminEndpoint() Option {
+	return func(d *dialerConfig) {
+		d.regionalEndpoint = true
+	}
+}
+
+// WithConnectionInfoEndpoint configures the dialer to retrieve instance
+// connection info from the provided URL. All other AlloyDB Admin API calls
+// continue to use the default endpoint or the endpoint configured with
+// WithAdminAPIEndpoint. This option is generally unnecessary except for
+// testing.
+func WithConnectionInfoEndpoint(url string) Option {
+	return func(d *dialerConfig) {
+		d.connectionInfoEndpoint = url
+	}
+}
+
+// WithCertificateEndpoint configures the dialer to generate client
+// certificates using the provided URL. All other AlloyDB Admin API calls
+// continue to use the default endpoint or the endpoint configured with
+// WithAdminAPIEndpoint. This option is generally unnecessary except for
+// testing.
+func WithCertificateEndpoint(url string) Option {
+	return func(d *dialerConfig) {
+		d.certificateEndpoint = url
+	}
+}
+
+// WithExpectedCACertFingerprint configures the dialer to reject connection
+// info whose CA certificate does not match the provided SHA-256 fingerprint.
+// The fingerprint must be hex encoded and may include colon separators (as
+// printed by "openssl x509 -noout -fingerprint -sha256"). This option provides
+// defense in depth by ensuring the dialer only trusts a known CA, even if the
+// AlloyDB Admin API returns a different one. It has no effect on static
+// connection info.
+func WithExpectedCACertFingerprint(fingerprint string) Option {
+	return func(d *dialerConfig) {
+		fp, err := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+		if err != nil || len(fp) != sha256.Size {
+			d.err = errtype.NewConfigError(
+				"invalid CA certificate fingerprint, expected a hex encoded SHA-256 hash",
+				"n/a",
+			)
+			return
+		}
+		d.refreshOpts = append(d.refreshOpts, alloydb.WithExpectedCACertFingerprint(fp))
+	}
+}
+
+// WithExpectedInstanceUID configures the dialer to refuse connections to an
+// instance whose UID does not match uid. This guards against connecting to an
+// instance that was deleted and recreated with the same name. Because the UID
+// identifies a single instance, a dialer configured with this option should
+// only be used to connect to that instance. It has no effect on static
+// connection info.
+func WithExpectedInstanceUID(uid string) Option {
+	return func(d *dialerConfig) {
+		d.refreshOpts = append(d.refreshOpts, alloydb.WithExpectedInstanceUID(uid))
+	}
+}
+
+// WithMetadataExchangeBufferSize configures the size in bytes of the buffers
+// used for the metadata exchange. Buffers are pooled and shared across all
+// calls to Dial. Smaller buffers reduce memory use in constrained
+// environments. The size must be between 512 bytes and 16 KiB, which is also
+// the default.
+func WithMetadataExchangeBufferSize(size int) Option {
+	return func(d *dialerConfig) {
+		if size < minMessageSize || size > maxMessageSize {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf(
+					"invalid buffer size %d, must be between %d and %d bytes",
+					size, minMessageSize, maxMessageSize,
+				),
+				"n/a",
+			)
+			return
+		}
+		d.bufferSize = size
+	}
+}
+
+// WithMetadataExchangeTimeout configures how long Dial waits for each write
+// to and read from the server during the metadata exchange before failing.
+// Latency-sensitive services may prefer to fail quickly when the server is
+// unresponsive. The timeout must be positive and defaults to 30 seconds.
+func WithMetadataExchangeTimeout(t time.Duration) Option {
+	return func(d *dialerConfig) {
+		if t <= 0 {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid metadata exchange timeout %v, must be positive", t),
+				"n/a",
+			)
+			return
+		}
+		d.metadataExchangeTimeout = t
+	}
+}
+
+// WithTLSSessionCache enables TLS session resumption, which avoids a full TLS
+// handshake when a connection to an instance resumes an earlier session. This
+// reduces the cost of dialing for workloads with frequent connection churn.
+// Each instance has its own cache holding up to capacity sessions, and a
+// session is only resumed with the client certificate it was established
+// with, so sessions are discarded once the certificate is refreshed. By
+// default, session resumption is disabled.
+func WithTLSSessionCache(capacity int) Option {
+	return func(d *dialerConfig) {
+		if capacity <= 0 {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid TLS session cache capacity %d, must be positive", capacity),
+				"n/a",
+			)
+			return
+		}
+		d.tlsSessionCacheSize = capacity
+	}
+}
+
+// WithOnDial configures a callback that is invoked after each call to Dial,
+// whether it succeeds or fails, with a DialEvent describing the outcome. The
+// callback is invoked in its own goroutine, so it may run after Dial returns
+// and may run concurrently with other invocations. It should return quickly
+// and must not block, as each slow invocation holds a goroutine. With
+// WithBackgroundRefreshDisabled, Dial invokes the callback before returning.
+func WithOnDial(f func(DialEvent)) Option {
+	return func(d *dialerConfig) {
+		d.onDial = f
+	}
+}
+
+// WithSpanInstanceAttributes configures the dialer to add the project,
+// region, cluster, and instance ID of the instance to the spans of Dial and
+// Secure as the /alloydb/project, /alloydb/region, /alloydb/cluster, and
+// /alloydb/instance_id attributes, e.g., to filter traces by region or
+// cluster. By default, spans only carry the instance URI passed by the
+// caller.
+func WithSpanInstanceAttributes() Option {
+	return func(d *dialerConfig) {
+		d.spanInstanceAttrs = true
+	}
+}
+
+// WithNegativeCacheTTL configures the dialer to remember when an instance's
+// connection info cannot be retrieved because the instance does not exist or
+// the caller lacks permission to access it. For the provided duration,
+// subsequent calls to Dial for that instance fail immediately with the same
+// error instead of querying the AlloyDB Admin API again. Transient errors are
+// never cached. A zero duration disables the cache and a negative duration is
+// invalid. By default, failures are not cached.
+func WithNegativeCacheTTL(ttl time.Duration) Option {
+	return func(d *dialerConfig) {
+		if ttl < 0 {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid negative cache TTL %v, must not be negative", ttl),
+				"n/a",
+			)
+			return
+		}
+		d.negativeCacheTTL = ttl
+	}
+}
+
 // WithDialFunc configures the function used to connect to the address on the
 // named network. This option is generally unnecessary except for advanced
 // use-cases. The function is used for all invocations of Dial. To configure
@@ -164,13 +568,95 @@ func WithDialFunc(dial func(ctx context.Context, network, addr string) (net.Conn
 	}
 }
 
+// WithProxy configures the dialer to connect to instances through the proxy
+// at proxyURL. Supported schemes are socks5 and socks5h for SOCKS5 proxies
+// and http for HTTP CONNECT proxies, e.g., "socks5://localhost:1080". Any
+// credentials in the URL are used to authenticate to the proxy. The proxy is
+// used only for connections to instances; requests to the AlloyDB Admin API
+// use their own HTTP transport. WithProxy replaces any function configured
+// with WithDialFunc.
+func WithProxy(proxyURL string) Option {
+	return func(d *dialerConfig) {
+		f, err := newProxyDialFunc(proxyURL)
+		if err != nil {
+			d.err = errtype.NewConfigError(err.Error(), "n/a")
+			return
+		}
+		d.dialFunc = f
+	}
+}
+
+// WithPSCEndpointResolver configures the dialer to call resolve to find the
+// host to connect to when dialing an instance with WithPSC. The resolve
+// function is passed the instance URI given to Dial and returns a hostname or
+// IP address that reaches the instance's PSC endpoint, e.g., when the PSC DNS
+// name reported by the AlloyDB Admin API cannot be resolved from the client's
+// network. The server's certificate is still verified against the PSC DNS
+// name. An error from resolve fails the call to Dial.
+func WithPSCEndpointResolver(resolve func(instance string) (string, error)) Option {
+	return func(d *dialerConfig) {
+		d.pscResolver = resolve
+	}
+}
+
+// WithDNSResolver configures the dialer to accept a domain name in place of
+// an instance URI when dialing. The domain name must have a DNS TXT record
+// whose value is the instance URI, e.g.,
+//
+//	db.example.com. TXT "projects/my-project/locations/my-region/clusters/my-cluster/instances/my-instance"
+//
+// Dial connects to the domain name itself instead of the IP address reported
+// by the AlloyDB Admin API, so the name must resolve to an address that
+// reaches the instance's server-side proxy. The client certificate is still
+// retrieved from the AlloyDB Admin API for the instance URI in the TXT
+// record, and the server's certificate is still verified against the address
+// of the IP type in use. Fast failover does not apply to domain names.
+//
+// Values passed to Dial that are valid instance URIs are never looked up in
+// DNS. Note that the short-form "<project>.<region>.<cluster>.<instance>" is
+// not an instance URI and, with this option, is treated as a domain name.
+func WithDNSResolver() Option {
+	return func(d *dialerConfig) {
+		d.dnsResolver = net.DefaultResolver
+	}
+}
+
 // WithIAMAuthN enables automatic IAM Authentication. If no token source has
 // been configured (such as with WithTokenSource, WithCredentialsFile, etc),
 // the dialer will use the default token source as defined by
 // https://pkg.go.dev/golang.org/x/oauth2/google#FindDefaultCredentialsWithParams.
+// It is equivalent to WithAuthType(AuthTypeAutoIAM).
 func WithIAMAuthN() Option {
 	return func(d *dialerConfig) {
-		d.useIAMAuthN = true
+		d.authType = AuthTypeAutoIAM
+	}
+}
+
+// AuthType is the method the server uses to authenticate the database user of
+// connections made by the Dialer. See WithAuthType.
+type AuthType int
+
+const (
+	// AuthTypeDBNative authenticates database users with the database's
+	// built-in authentication, e.g., a password. This is the default.
+	AuthTypeDBNative AuthType = iota
+	// AuthTypeAutoIAM authenticates the IAM principal of the Dialer's OAuth2
+	// token as the database user and ignores any password.
+	AuthTypeAutoIAM
+)
+
+// WithAuthType returns an Option that sets how the server authenticates the
+// database user of each connection. Defaults to AuthTypeDBNative.
+// WithAuthType(AuthTypeAutoIAM) is equivalent to WithIAMAuthN.
+func WithAuthType(t AuthType) Option {
+	return func(d *dialerConfig) {
+		if _, ok := metadataExchangeAuthTypes[t]; !ok {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid auth type %d", t), "n/a",
+			)
+			return
+		}
+		d.authType = t
 	}
 }
 
@@ -215,12 +701,171 @@ func WithLazyRefresh() Option {
 	}
 }
 
+// WithBackgroundRefreshDisabled guarantees that the dialer does no work
+// between requests, for environments that throttle the CPU outside of a
+// request context. It implies WithLazyRefresh, so connection info is only
+// refreshed during a call to Dial, and in addition, it eliminates the
+// goroutines the dialer otherwise starts to:
+//
+//   - record metrics for refreshes, dials, connections, and bytes sent and
+//     received, and
+//   - invoke the callback configured with WithOnDial.
+//
+// Instead, metrics are recorded and the callback is invoked before the
+// corresponding call returns. Options that schedule work by design, such as
+// WithMaxConnectionLifetime or WithFastFailover, still do so.
+func WithBackgroundRefreshDisabled() Option {
+	return func(d *dialerConfig) {
+		d.backgroundDisabled = true
+	}
+}
+
+// WithRefreshOnDialFailureDisabled prevents the dialer from forcing a
+// refresh of an instance's connection info when a call to Dial fails to
+// connect to the instance or fails the TLS handshake. By default, the dialer
+// assumes stale connection info may have caused the failure and refreshes it
+// before returning the error. With this option, the error is still returned,
+// but the connection info is only refreshed on its usual schedule, leaving
+// callers to decide whether to refresh sooner, e.g., by closing and
+// recreating the Dialer.
+//
+// Refreshes triggered by an expired client certificate or by the server
+// rejecting the client certificate are unaffected.
+func WithRefreshOnDialFailureDisabled() Option {
+	return func(d *dialerConfig) {
+		d.refreshOnDialFailureDisabled = true
+	}
+}
+
+// WithMaxCachedInstances configures the dialer to cache the connection info
+// of at most n instances, e.g., for a long-running process that connects to
+// many distinct instances over time. When a call to Dial would add an
+// instance beyond the limit, the dialer closes and removes the cache of the
+// least recently dialed instance that has no open connections. If every
+// cached instance has open connections, nothing is removed and the limit is
+// exceeded until connections close. By default, the number of cached
+// instances is unbounded.
+func WithMaxCachedInstances(n int) Option {
+	return func(d *dialerConfig) {
+		if n <= 0 {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid max cached instances %d, must be positive", n),
+				"n/a",
+			)
+			return
+		}
+		d.maxCachedInstances = n
+	}
+}
+
+// WithMaxRefreshFailures configures the dialer to stop refreshing an
+// instance's connection info in the background after n consecutive refreshes
+// have failed, e.g., because the instance was deleted. The dialer then
+// removes the instance from its cache, so that a later call to Dial returns
+// the refresh error of a new attempt instead of retrying indefinitely in the
+// background. By default, failed refreshes are retried indefinitely. This
+// option has no effect with WithLazyRefresh, which never refreshes in the
+// background.
+func WithMaxRefreshFailures(n int) Option {
+	return func(d *dialerConfig) {
+		if n <= 0 {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid max refresh failures %d, must be positive", n),
+				"n/a",
+			)
+			return
+		}
+		d.maxRefreshFailures = n
+	}
+}
+
+// WithRefreshNotifier returns an Option that configures the dialer to send a
+// RefreshEvent on ch after each refresh of an instance's connection info,
+// whether it succeeded or failed. Sends never block: if ch is full, the event
+// is dropped. The option is primarily meant for tests and observability, e.g.,
+// to wait for a background refresh to complete instead of sleeping. It has no
+// effect with WithStaticConnectionInfo or WithConnectionInfoCacheFactory.
+func WithRefreshNotifier(ch chan<- RefreshEvent) Option {
+	return func(d *dialerConfig) {
+		d.refreshNotifier = ch
+	}
+}
+
+// WithConnectionInfoCacheFactory configures the dialer to create the cache of
+// each instance's connection info with f instead of using the built-in
+// caches, e.g., to share connection info across a fleet through an external
+// store. The dialer calls f once per instance, on the first connection to the
+// instance, and closes the cache when the dialer is closed. Options that
+// configure the built-in caches, such as WithLazyRefresh,
+// WithRefreshTimeout, and WithStaticConnectionInfo, have no effect.
+func WithConnectionInfoCacheFactory(f ConnectionInfoCacheFactory) Option {
+	return func(d *dialerConfig) {
+		d.cacheFactory = f
+	}
+}
+
+// BeforeDialFunc adjusts the configuration of a single Dial. See
+// WithBeforeDial.
+type BeforeDialFunc func(ctx context.Context, uri instance.URI, cfg *PublicDialConfig) error
+
+// PublicDialConfig holds the parts of a Dial's configuration that a
+// BeforeDialFunc may change.
+type PublicDialConfig struct {
+	// IPType is the IP type (PRIVATE, PUBLIC, or PSC) used to connect.
+	IPType string
+	// DialFunc, if set, is used to dial the instance in place of the
+	// dialer's dial function.
+	DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// WithBeforeDial configures the dialer to call f on each call to Dial after
+// the instance URI is parsed and the dial options are applied, but before any
+// connection info is retrieved. f may change the IP type or the dial function
+// of the call, e.g., based on a feature flag, without passing DialOptions at
+// every call site. When f returns an error, Dial fails with a ConfigError
+// describing it.
+func WithBeforeDial(f BeforeDialFunc) Option {
+	return func(d *dialerConfig) {
+		d.beforeDial = f
+	}
+}
+
+// WithInstanceValidator configures the dialer to call validate with the
+// instance URI passed to Dial, Secure, and ConnectionInfo before retrieving
+// any connection info, e.g., to restrict a multi-tenant platform to an
+// allow-list of projects or clusters in one place. DialCluster and
+// DialReadPool validate each instance they discover before dialing it. When
+// validate returns an error, the call fails with a ConfigError describing it.
+func WithInstanceValidator(validate func(uri instance.URI) error) Option {
+	return func(d *dialerConfig) {
+		d.validateInstance = validate
+	}
+}
+
+// WithAdditionalRootCAs configures the dialer to trust the root certificates
+// in pool, in addition to the instance's CA returned by the AlloyDB Admin
+// API, when verifying the certificate of an instance's server, e.g., for
+// clusters whose certificates chain to a customer-managed CA. The server's
+// certificate must still chain to one of the trusted roots and be valid for
+// the address used to connect. By default, only the instance's CA is trusted.
+//
+// This widens the trust placed in the server: a certificate issued by a CA in
+// pool is accepted for any instance whose address it is valid for, not only
+// for the instance whose CA the AlloyDB Admin API returned. Only add CAs that
+// issue certificates for trusted AlloyDB servers.
+func WithAdditionalRootCAs(pool *x509.CertPool) Option {
+	return func(d *dialerConfig) {
+		d.additionalRootCAs = pool
+	}
+}
+
 // WithStaticConnectionInfo specifies an io.Reader from which to read static
 // connection info. This is a *dev-only* option and should not be used in
 // production as it will result in failed connections after the client
 // certificate expires. It is also subject to breaking changes in the format.
-// NOTE: The static connection info is not refreshed by the dialer. The JSON
-// format supports multiple instances, regardless of cluster.
+// NOTE: The static connection info is not refreshed by the dialer, so it
+// takes precedence over WithLazyRefresh and WithBackgroundRefreshDisabled. The
+// JSON format supports multiple instances, regardless of cluster.
 //
 // The reader should hold JSON with the following format:
 //
@@ -245,6 +890,27 @@ func WithStaticConnectionInfo(r io.Reader) Option {
 	}
 }
 
+// WithStaticConnectionInfoFS is like WithStaticConnectionInfo, but reads the
+// static connection info from the files in fsys that match pattern, as
+// reported by fs.Glob, e.g., an embed.FS holding one file per instance. The
+// files are merged into a single document, so all of them must use the same
+// key pair, which need only be included in one file. NewDialer returns an
+// error if no file matches, if an instance is defined in more than one file,
+// or if the files hold different key pairs. Like WithStaticConnectionInfo,
+// this is a *dev-only* option.
+func WithStaticConnectionInfoFS(fsys fs.FS, pattern string) Option {
+	return func(d *dialerConfig) {
+		data, err := alloydb.MergeStaticConnectionInfo(fsys, pattern)
+		if err != nil {
+			d.err = errtype.NewConfigError(
+				fmt.Sprintf("invalid static connection info: %v", err), "n/a",
+			)
+			return
+		}
+		d.staticConnInfo = bytes.NewReader(data)
+	}
+}
+
 // WithOptOutOfAdvancedConnectionCheck disables the dataplane permission check.
 // It is intended only for clients who are running in an environment where the
 // workload's IP address is otherwise unknown and cannot be allow-listed in a
@@ -271,14 +937,171 @@ type dialCfg struct {
 	dialFunc     func(ctx context.Context, network, addr string) (net.Conn, error)
 	ipType       string
 	tcpKeepAlive time.Duration
+	// tcpLinger is the SO_LINGER value in seconds. When nil, the operating
+	// system's default is used.
+	tcpLinger *int
+	network   string
+	// failoverDelay is how long to wait for the preferred IP type to connect
+	// before racing a dial to a secondary IP type. Zero disables failover.
+	failoverDelay time.Duration
+	// maxConnLifetime is the maximum amount of time a connection may be
+	// used. Zero means connections have no maximum lifetime.
+	maxConnLifetime time.Duration
+	// idleTimeout is how long a connection may go without reading or writing
+	// any bytes before it is closed. Zero disables the idle timeout.
+	idleTimeout time.Duration
+	// certProbeTimeout is how long to wait for the server to reject the
+	// client certificate after connecting. Zero disables the probe.
+	certProbeTimeout time.Duration
+	// dialRetries is the number of times a failed dial is retried.
+	dialRetries int
+	// dialRetryBackoff is the delay before the first retry. The delay doubles
+	// with each subsequent retry.
+	dialRetryBackoff time.Duration
+	// connInfo, when set, is used for a single dial instead of the cached
+	// connection info.
+	connInfo *ConnectionInfo
+	// domainName is the domain name passed to Dial in place of an instance
+	// URI, if any. When set, it is dialed instead of the instance's address.
+	domainName string
+	// metricDatabase and metricUser tag the dial and connection metrics.
+	// When empty, the tags are omitted.
+	metricDatabase string
+	metricUser     string
+	// loginTokenSource, when set, provides the OAuth2 token sent in the
+	// metadata exchange in place of the Dialer's token source.
+	loginTokenSource oauth2.TokenSource
+	// serverName, when set, is used to verify the server's certificate in
+	// place of the address dialed.
+	serverName string
+
+	// selectedIPType is the IP type chosen by the list of options currently
+	// being applied. It detects conflicting IP type options within a list.
+	selectedIPType string
+	// err tracks any dial options that may have failed.
+	err error
 }
 
-// DialOptions turns a list of DialOption instances into an DialOption.
+// apply applies opts to cfg. The options in a single list may not select
+// different IP types, but they may override the IP type selected by a list
+// applied earlier, e.g., with WithDefaultDialOptions. Nil options are skipped.
+func (cfg *dialCfg) apply(opts []DialOption) {
+	cfg.selectedIPType = ""
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+}
+
+// setIPType sets the IP type used to connect and records an error if the list
+// of options being applied has already selected a different IP type.
+func (cfg *dialCfg) setIPType(ipType string) {
+	if cfg.selectedIPType != "" && cfg.selectedIPType != ipType && cfg.err == nil {
+		cfg.err = fmt.Errorf(
+			"conflicting IP type options: %v and %v", cfg.selectedIPType, ipType,
+		)
+	}
+	cfg.selectedIPType = ipType
+	cfg.ipType = ipType
+}
+
+// WithMetricDatabase tags the dial latency, dial failure, and open connection
+// metrics of the connection with the name of the database it connects to (the
+// alloydb_database tag), which helps attribute load in clusters with many
+// databases. Each distinct tag value creates a new time series, so only use
+// this option with a small, fixed set of database names. By default, the tag
+// is omitted. The name must be non-empty printable ASCII of at most 255
+// characters.
+func WithMetricDatabase(name string) DialOption {
+	return func(cfg *dialCfg) {
+		if err := checkTagValue("metric database", name); err != nil {
+			cfg.err = err
+			return
+		}
+		cfg.metricDatabase = name
+	}
+}
+
+// WithMetricUser tags the dial latency, dial failure, and open connection
+// metrics of the connection with the database user it connects as (the
+// alloydb_user tag). Each distinct tag value creates a new time series, so
+// only use this option with a small, fixed set of users. By default, the tag
+// is omitted. The user must be non-empty printable ASCII of at most 255
+// characters.
+func WithMetricUser(user string) DialOption {
+	return func(cfg *dialCfg) {
+		if err := checkTagValue("metric user", user); err != nil {
+			cfg.err = err
+			return
+		}
+		cfg.metricUser = user
+	}
+}
+
+// DialOptions turns a list of DialOption instances into an DialOption. Nil
+// options are ignored.
 func DialOptions(opts ...DialOption) DialOption {
 	return func(cfg *dialCfg) {
 		for _, opt := range opts {
-			opt(cfg)
+			if opt != nil {
+				opt(cfg)
+			}
+		}
+	}
+}
+
+type dialOptionsKey struct{}
+
+// ContextWithDialOptions returns a copy of ctx that carries the provided
+// DialOptions. When the returned context is passed to Dial, the options are
+// applied after any options configured with WithDefaultDialOptions and before
+// any options passed directly to Dial. In other words, options passed to Dial
+// take precedence over options on the context, which in turn take precedence
+// over the dialer's defaults. Calling ContextWithDialOptions on a context
+// that already carries DialOptions appends to the existing options.
+func ContextWithDialOptions(ctx context.Context, opts ...DialOption) context.Context {
+	prev := dialOptionsFromContext(ctx)
+	all := make([]DialOption, 0, len(prev)+len(opts))
+	all = append(append(all, prev...), opts...)
+	return context.WithValue(ctx, dialOptionsKey{}, all)
+}
+
+// dialOptionsFromContext returns the DialOptions stored on ctx, if any.
+func dialOptionsFromContext(ctx context.Context) []DialOption {
+	opts, _ := ctx.Value(dialOptionsKey{}).([]DialOption)
+	return opts
+}
+
+// WithDialCredentials configures the credentials used to log in to the
+// database for an individual call to Dial or Secure, e.g., to connect with an
+// end user's delegated credentials in a multi-tenant service. The OAuth2
+// token of c is sent to the instance in the metadata exchange in place of the
+// Dialer's token, which, with WithIAMAuthN, logs in as c's IAM principal.
+// The Admin API client is shared by all calls, so the credentials do not
+// affect the Admin API calls that retrieve the instance's connection info and
+// client certificate; those continue to use the Dialer's credentials.
+func WithDialCredentials(c *google.Credentials) DialOption {
+	return func(cfg *dialCfg) {
+		if c == nil || c.TokenSource == nil {
+			cfg.err = errors.New("dial credentials must provide a token source")
+			return
 		}
+		cfg.loginTokenSource = c.TokenSource
+	}
+}
+
+// WithServerNameOverride configures the name used to verify the server's
+// certificate in the TLS handshake, for advanced or testing use, e.g., with a
+// custom proxy whose certificate has a fixed SAN. By default, the certificate
+// must be valid for the IP address or PSC DNS name that is dialed.
+//
+// WARNING: This option breaks the correspondence between the address dialed
+// and the server's certificate. Any server presenting a certificate for name
+// that chains to the instance's CA is accepted, regardless of its address.
+func WithServerNameOverride(name string) DialOption {
+	return func(c *dialCfg) {
+		c.serverName = name
 	}
 }
 
@@ -292,18 +1115,147 @@ func WithOneOffDialFunc(dial func(ctx context.Context, network, addr string) (ne
 }
 
 // WithTCPKeepAlive returns a DialOption that specifies the tcp keep alive
-// period for the connection returned by Dial.
+// period for the connection returned by Dial. Defaults to
+// DefaultTCPKeepAlive.
 func WithTCPKeepAlive(d time.Duration) DialOption {
 	return func(cfg *dialCfg) {
 		cfg.tcpKeepAlive = d
 	}
 }
 
+// WithTCPLinger returns a DialOption that sets the SO_LINGER option, in
+// seconds, on the connection returned by Dial. When sec is zero, closing the
+// connection discards any unsent data and resets the connection. When sec is
+// positive, closing the connection blocks for up to sec seconds while unsent
+// data is flushed. A negative value is invalid. This option has no effect when
+// the dial function does not return a *net.TCPConn.
+func WithTCPLinger(sec int) DialOption {
+	return func(cfg *dialCfg) {
+		if sec < 0 {
+			cfg.err = fmt.Errorf("invalid TCP linger %d, must not be negative", sec)
+			return
+		}
+		cfg.tcpLinger = &sec
+	}
+}
+
+// WithDialRetries returns a DialOption that retries a failed Dial up to n
+// times when connecting to the instance or completing the TLS handshake
+// fails. Before each retry, Dial waits for backoff, doubling the wait with
+// each subsequent retry up to 30 seconds (or backoff, if longer), and uses
+// refreshed connection info. Errors that retrying cannot fix, such as an
+// invalid instance URI, a missing IP type, or a failure to retrieve connection
+// info, are returned immediately. Negative values of n or backoff are invalid.
+// By default, Dial does not retry.
+func WithDialRetries(n int, backoff time.Duration) DialOption {
+	return func(cfg *dialCfg) {
+		if n < 0 {
+			cfg.err = fmt.Errorf("invalid dial retries %d, must not be negative", n)
+			return
+		}
+		if backoff < 0 {
+			cfg.err = fmt.Errorf(
+				"invalid dial retry backoff %v, must not be negative", backoff,
+			)
+			return
+		}
+		cfg.dialRetries = n
+		cfg.dialRetryBackoff = backoff
+	}
+}
+
+// WithOneOffConnectionInfo returns a DialOption that connects using ci instead
+// of the Dialer's cached connection info. The Dialer neither calls the AlloyDB
+// Admin API nor caches ci, which makes the option useful when connection info
+// has already been obtained elsewhere and the latency of retrieving it is
+// prohibitive. The instance is identified by the URI passed to Dial, and
+// ci.Instance is ignored.
+//
+// ci must include the client certificate and its private key in ClientCert,
+// the instance's CA certificates in RootCAs, and the address of the IP type
+// being dialed in IPAddrs. The caller is responsible for providing a client
+// certificate that has not expired. Dial returns a ConfigError if the
+// certificate has expired and never refreshes it.
+func WithOneOffConnectionInfo(ci ConnectionInfo) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.connInfo = &ci
+	}
+}
+
+// WithNetworkType returns a DialOption that specifies the network passed to
+// the dial function when connecting to an instance. Valid values are "tcp"
+// (the default), "tcp4", and "tcp6". For example, "tcp4" may be used to avoid
+// IPv6 routes in environments where they are broken.
+func WithNetworkType(network string) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.network = network
+	}
+}
+
+// WithFastFailover returns a DialOption that races connections to an
+// instance's private and public IP addresses. The preferred IP type is dialed
+// first and, if it has not connected after delay (or if it fails), the other
+// IP type is dialed as well. Whichever connects first is used and the other
+// connection is closed. This is similar to the "Happy Eyeballs" algorithm
+// described in RFC 8305.
+//
+// Fast failover only applies to instances with both private and public IP
+// addresses and has no effect on PSC connections.
+func WithFastFailover(delay time.Duration) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.failoverDelay = delay
+	}
+}
+
+// WithMaxConnectionLifetime returns a DialOption that closes the connection
+// returned by Dial once d has elapsed. Reads and writes on a closed connection
+// fail, which signals connection pools to discard it and dial a replacement.
+// This is useful to periodically recycle connections regardless of the
+// connection pool in use. Note that any query in progress when the lifetime
+// elapses will fail. By default, connections have no maximum lifetime.
+func WithMaxConnectionLifetime(d time.Duration) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.maxConnLifetime = d
+	}
+}
+
+// WithIdleTimeout returns a DialOption that closes the connection returned by
+// Dial once no bytes have been read from or written to it for d. Unlike TCP
+// keep-alive, which only detects an unreachable peer, this detects
+// connections that have silently stopped carrying traffic, e.g., half-open
+// connections behind a stateful firewall. Reads and writes on the closed
+// connection fail, which signals connection pools to discard it. Note that a
+// query that runs for longer than d without returning any data will fail. By
+// default, connections have no idle timeout.
+func WithIdleTimeout(d time.Duration) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.idleTimeout = d
+	}
+}
+
+// WithClientCertProbe returns a DialOption that checks whether the server
+// accepted the client certificate before Dial returns. With TLS 1.3, the
+// server verifies the client certificate only after the client completes the
+// handshake, so a rejected certificate (e.g., one the server considers
+// expired) otherwise surfaces on the first read, which database drivers
+// perform outside of Dial. With this option, Dial reads from the connection
+// for up to timeout and, when the server rejects the certificate, refreshes
+// the instance's connection info and retries once. Rejections reported during
+// the metadata exchange are retried the same way. Because the probe succeeds
+// only once timeout elapses without a rejection, it adds timeout to the
+// latency of every Dial. By default, no probe is performed.
+func WithClientCertProbe(timeout time.Duration) DialOption {
+	return func(cfg *dialCfg) {
+		cfg.certProbeTimeout = timeout
+	}
+}
+
 // WithPublicIP returns a DialOption that specifies a public IP will be used to
-// connect.
+// connect. At most one of WithPublicIP, WithPrivateIP, and WithPSC may be
+// passed to Dial or WithDefaultDialOptions; combining them is a ConfigError.
 func WithPublicIP() DialOption {
 	return func(cfg *dialCfg) {
-		cfg.ipType = alloydb.PublicIP
+		cfg.setIPType(alloydb.PublicIP)
 	}
 }
 
@@ -311,7 +1263,7 @@ func WithPublicIP() DialOption {
 // used to connect.
 func WithPrivateIP() DialOption {
 	return func(cfg *dialCfg) {
-		cfg.ipType = alloydb.PrivateIP
+		cfg.setIPType(alloydb.PrivateIP)
 	}
 }
 
@@ -319,6 +1271,6 @@ func WithPrivateIP() DialOption {
 // connect.
 func WithPSC() DialOption {
 	return func(cfg *dialCfg) {
-		cfg.ipType = alloydb.PSC
+		cfg.setIPType(alloydb.PSC)
 	}
 }
@@ -0,0 +1,225 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	alloydbadmin "cloud.google.com/go/alloydb/apiv1alpha"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"google.golang.org/api/option"
+)
+
+// startSOCKS5Proxy starts a minimal SOCKS5 proxy that supports only the
+// CONNECT command without authentication. Each requested address is sent on
+// the returned channel.
+func startSOCKS5Proxy(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	addrs := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				addr, err := socks5Handshake(conn)
+				if err != nil {
+					return
+				}
+				addrs <- addr
+				// Reply with success and a zero bound address.
+				if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+					return
+				}
+				pipe(conn, addr)
+			}()
+		}
+	}()
+	return l.Addr().String(), addrs
+}
+
+// socks5Handshake reads the client greeting and CONNECT request and returns
+// the requested address.
+func socks5Handshake(conn net.Conn) (string, error) {
+	// Greeting: version, number of methods, methods.
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(conn, make([]byte, hdr[1])); err != nil {
+		return "", err
+	}
+	// Select "no authentication required".
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+	// Request: version, command, reserved, address type.
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return "", err
+	}
+	var host string
+	switch req[3] {
+	case 1: // IPv4
+		ip := make([]byte, 4)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3: // domain name
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return "", err
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		return "", io.ErrUnexpectedEOF
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// startHTTPConnectProxy starts a minimal HTTP proxy that supports only the
+// CONNECT method. Each requested address is sent on the returned channel.
+func startHTTPConnectProxy(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	addrs := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				addrs <- req.Host
+				if _, err := io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n"); err != nil {
+					return
+				}
+				pipe(conn, req.Host)
+			}()
+		}
+	}()
+	return l.Addr().String(), addrs
+}
+
+// pipe copies data between conn and a new connection to addr until either
+// side is closed.
+func pipe(conn net.Conn, addr string) {
+	backend, err := net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+	defer backend.Close()
+	go func() {
+		_, _ = io.Copy(backend, conn)
+		backend.Close()
+	}()
+	_, _ = io.Copy(conn, backend)
+}
+
+func TestDialerWithProxy(t *testing.T) {
+	tcs := []struct {
+		desc  string
+		start func(*testing.T) (string, <-chan string)
+		// scheme is the proxy URL scheme.
+		scheme string
+	}{
+		{desc: "SOCKS5 proxy", start: startSOCKS5Proxy, scheme: "socks5"},
+		{desc: "HTTP CONNECT proxy", start: startHTTPConnectProxy, scheme: "http"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			inst := mock.NewFakeInstance(
+				"my-project", "my-region", "my-cluster", "my-instance",
+			)
+			mc, url, cleanup := mock.HTTPClient(
+				mock.InstanceGetSuccess(inst, 1),
+				mock.CreateEphemeralSuccess(inst, 1),
+			)
+			stop := mock.StartServerProxy(t, inst)
+			defer func() {
+				stop()
+				if err := cleanup(); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}()
+			c, err := alloydbadmin.NewAlloyDBAdminRESTClient(
+				ctx, option.WithHTTPClient(mc), option.WithEndpoint(url),
+			)
+			if err != nil {
+				t.Fatalf("expected NewClient to succeed, but got error: %v", err)
+			}
+
+			proxyAddr, addrs := tc.start(t)
+			d, err := NewDialer(ctx,
+				WithTokenSource(stubTokenSource{}),
+				WithRSAKey(testRSAKey),
+				WithProxy(tc.scheme+"://"+proxyAddr),
+			)
+			if err != nil {
+				t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+			}
+			d.client = c
+			defer d.Close()
+
+			conn, err := d.Dial(ctx, testInstanceURI)
+			if err != nil {
+				t.Fatalf("expected Dial to succeed, but got error: %v", err)
+			}
+			defer conn.Close()
+			data, err := io.ReadAll(conn)
+			if err != nil {
+				t.Fatalf("expected ReadAll to succeed, got error %v", err)
+			}
+			if string(data) != "my-instance" {
+				t.Fatalf("expected known response from the server, but got %v", string(data))
+			}
+			want := net.JoinHostPort("127.0.0.1", DefaultServerProxyPort)
+			if got := <-addrs; got != want {
+				t.Fatalf("proxy address mismatch, want = %v, got = %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alloydbconn
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/alloydbconn/instance"
+	"cloud.google.com/go/alloydbconn/internal/mock"
+	"go.opencensus.io/trace"
+	"golang.org/x/time/rate"
+)
+
+type spyTraceExporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *spyTraceExporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+// span returns the first exported span with the provided name.
+func (e *spyTraceExporter) span(name string) (*trace.SpanData, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range e.spans {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+func TestDialerWithSpanInstanceAttributes(t *testing.T) {
+	inst := mock.NewFakeInstance(
+		"my-project", "my-region", "my-cluster", "my-instance",
+	)
+	stop := mock.StartServerProxy(t, inst)
+	t.Cleanup(stop)
+
+	spy := &spyTraceExporter{}
+	trace.RegisterExporter(spy)
+	defer trace.UnregisterExporter(spy)
+
+	d, err := NewDialer(context.Background(),
+		WithTokenSource(stubTokenSource{}),
+		WithRSAKey(testRSAKey),
+		WithSpanInstanceAttributes(),
+	)
+	if err != nil {
+		t.Fatalf("expected NewDialer to succeed, but got error: %v", err)
+	}
+	defer d.Close()
+	cn, _ := instance.ParseURI(testInstanceURI)
+	d.cache[cn] = monitoredCache{
+		openConns:      new(uint64),
+		connErrLimiter: rate.NewLimiter(rate.Every(time.Hour), 1),
+		connectionInfoCache: &spyConnectionInfoCache{
+			connectInfoCalls: []connectionInfoResp{{
+				info: oneOffConnectionInfo(
+					cn, oneOffConnectionInfoFor(t, inst, time.Now().Add(time.Hour)),
+				),
+			}},
+		},
+	}
+
+	// Sample the parent span so that the Dial span is sampled too, without
+	// changing the global trace config.
+	ctx, parent := trace.StartSpan(
+		context.Background(), "test", trace.WithSampler(trace.AlwaysSample()),
+	)
+	conn, err := d.Dial(ctx, testInstanceURI)
+	parent.End()
+	if err != nil {
+		t.Fatalf("expected Dial to succeed, but got error: %v", err)
+	}
+	defer conn.Close()
+
+	s, ok := spy.span("cloud.google.com/go/alloydbconn.Dial")
+	if !ok {
+		t.Fatal("want Dial span to be exported")
+	}
+	want := map[string]string{
+		"/alloydb/project":     "my-project",
+		"/alloydb/region":      "my-region",
+		"/alloydb/cluster":     "my-cluster",
+		"/alloydb/instance_id": "my-instance",
+	}
+	for k, v := range want {
+		if got := s.Attributes[k]; got != v {
+			t.Errorf("attribute %v: want = %q, got = %v", k, v, got)
+		}
+	}
+}